@@ -0,0 +1,125 @@
+// Package client is a Go client for the Poll, Voter and Votes APIs, the one
+// the APIs themselves use to call each other. A Client has a service for each
+// API:
+//
+//	c := client.New(client.Config{
+//		PollAPI:  "http://localhost:2080",
+//		VoterAPI: "http://localhost:1080",
+//		VotesAPI: "http://localhost:3080",
+//		Token:    "dev-admin-key",
+//	})
+//	poll, err := c.Polls.Get(ctx, "/polls/1")
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+//
+// The IDs are the paths the APIs use as IDs, e.g. /polls/1, /polls/1/options/2,
+// /voters/1 and /votes/1. Every call is made with the context it is given, and
+// an error response of an API is returned as an *Error.
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Config is where the APIs are and how they are called. An API that is not
+// called can be left out.
+type Config struct {
+	PollAPI  string
+	VoterAPI string
+	VotesAPI string
+	// HTTP makes the calls, e.g. one with timeouts and retries or one that
+	// signs the calls of a service. A plain resty.Client is used by default.
+	HTTP *resty.Client
+	// Token is sent as Authorization: Bearer <Token>, it is an API key or a
+	// JWT (see the readme). It is set on each request rather than on HTTP, so
+	// Clients sharing one HTTP can each have their own.
+	Token string
+}
+
+// Client calls the Poll, Voter and Votes APIs
+type Client struct {
+	Polls  *PollsService
+	Voters *VotersService
+	Votes  *VotesService
+}
+
+// New returns a Client for the APIs in config
+func New(config Config) *Client {
+
+	httpClient := config.HTTP
+	if httpClient == nil {
+		httpClient = resty.New()
+	}
+
+	return &Client{
+		Polls:  &PollsService{api: api{name: "Poll API", baseURL: trimBase(config.PollAPI), http: httpClient, token: config.Token}},
+		Voters: &VotersService{api: api{name: "Voter API", baseURL: trimBase(config.VoterAPI), http: httpClient, token: config.Token}},
+		Votes:  &VotesService{api: api{name: "Votes API", baseURL: trimBase(config.VotesAPI), http: httpClient, token: config.Token}},
+	}
+}
+
+func trimBase(baseURL string) string {
+	return strings.TrimRight(baseURL, "/")
+}
+
+// api makes the calls to one of the APIs
+type api struct {
+	name    string
+	baseURL string
+	http    *resty.Client
+	token   string
+}
+
+// do sends the request for path to the API, with body as JSON if it is not
+// nil, and decodes the response into result if it is not nil. A response
+// other than 200 OK is returned as an *Error, a call without a response as
+// the error of the HTTP client, wrapped.
+func (a api) do(ctx context.Context, method string, path string, body any, result any) error {
+
+	req := a.http.R().SetContext(ctx).SetError(&Error{})
+	if a.token != "" {
+		req.SetAuthToken(a.token)
+	}
+	if body != nil {
+		req.SetHeader("Content-Type", "application/json").SetBody(body)
+	}
+	if result != nil {
+		req.SetResult(result)
+	}
+
+	resp, err := req.Execute(method, a.baseURL+path)
+	if err != nil {
+		return fmt.Errorf("could not call the %v: %w", a.name, err)
+	}
+	if resp.StatusCode() == http.StatusOK {
+		return nil
+	}
+
+	apiErr, ok := resp.Error().(*Error)
+	if !ok || apiErr.Status == 0 {
+		// not the ErrorResponse of the API, e.g. from a proxy in front of it
+		apiErr = &Error{Message: strings.TrimSpace(resp.String())}
+	}
+	apiErr.Status = resp.StatusCode()
+	apiErr.Method = method
+	apiErr.Path = path
+	return apiErr
+}
+
+func (a api) get(ctx context.Context, path string, result any) error {
+	return a.do(ctx, http.MethodGet, path, nil, result)
+}
+
+// cascadeQuery is the query of a DELETE that also deletes the Votes
+func cascadeQuery(cascade bool) string {
+	if cascade {
+		return "?cascade=true"
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// fakeAPI serves handler and records the Authorization of every request
+type fakeAPI struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	auths []string
+}
+
+func newFakeAPI(t *testing.T, handler http.HandlerFunc) *fakeAPI {
+	t.Helper()
+	f := &fakeAPI{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.auths = append(f.auths, r.Header.Get("Authorization"))
+		f.mu.Unlock()
+		handler(w, r)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeAPI) authorizations() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.auths...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestErrors(t *testing.T) {
+
+	f := newFakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/polls/1":
+			writeJSON(w, http.StatusOK, Poll{PollID: "/polls/1", Status: PollStatusOpen})
+		case "/polls/2":
+			writeJSON(w, http.StatusNotFound, map[string]any{
+				"Status": 404, "Code": "NOT_FOUND", "Message": "Poll /polls/2 not found.", "RequestID": "req-1",
+			})
+		case "/polls/3":
+			writeJSON(w, http.StatusConflict, map[string]any{
+				"Status": 409, "Code": "CONFLICT", "Message": "Poll /polls/3 already exists.", "Field": "PollID",
+			})
+		default:
+			// not an ErrorResponse, e.g. from a proxy in front of the API
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		}
+	})
+	c := New(Config{PollAPI: f.URL + "/"})
+	ctx := context.Background()
+
+	poll, err := c.Polls.Get(ctx, "/polls/1")
+	if err != nil || poll.PollID != "/polls/1" {
+		t.Fatalf("Get() = %+v, %v, want /polls/1", poll, err)
+	}
+
+	tests := []struct {
+		name       string
+		pollID     string
+		wantStatus int
+		wantIs     error
+		want       Error
+	}{
+		{"not found", "/polls/2", http.StatusNotFound, ErrNotFound,
+			Error{Code: "NOT_FOUND", Message: "Poll /polls/2 not found.", RequestID: "req-1"}},
+		{"conflict", "/polls/3", http.StatusConflict, ErrConflict,
+			Error{Code: "CONFLICT", Message: "Poll /polls/3 already exists.", Field: "PollID"}},
+		{"not an error response", "/polls/4", http.StatusBadGateway, nil,
+			Error{Message: "bad gateway"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			err := c.Polls.Add(ctx, Poll{PollID: tt.pollID})
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Add() error = %v, want an *Error", err)
+			}
+			if apiErr.Status != tt.wantStatus || apiErr.Method != http.MethodPost || apiErr.Path != tt.pollID {
+				t.Errorf("Status, Method, Path = %v, %v, %v, want %v, POST, %v", apiErr.Status, apiErr.Method, apiErr.Path, tt.wantStatus, tt.pollID)
+			}
+			if apiErr.Code != tt.want.Code || apiErr.Message != tt.want.Message || apiErr.Field != tt.want.Field || apiErr.RequestID != tt.want.RequestID {
+				t.Errorf("Error = %+v, want %+v", apiErr, tt.want)
+			}
+			for _, kind := range []error{ErrNotFound, ErrConflict} {
+				if want := kind == tt.wantIs; errors.Is(err, kind) != want {
+					t.Errorf("errors.Is(err, %v) = %v, want %v", kind, !want, want)
+				}
+			}
+		})
+	}
+
+	t.Run("no response", func(t *testing.T) {
+		c := New(Config{PollAPI: "http://127.0.0.1:1"})
+		_, err := c.Polls.Get(ctx, "/polls/1")
+		var apiErr *Error
+		if err == nil || errors.As(err, &apiErr) || !strings.Contains(err.Error(), "Poll API") {
+			t.Errorf("Get() error = %v, want the error of the HTTP client", err)
+		}
+	})
+}
+
+// Clients sharing one resty.Client each send their own Token, and the
+// resty.Client is left as it was
+func TestToken(t *testing.T) {
+
+	f := newFakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []Voter{})
+	})
+	shared := resty.New()
+	admin := New(Config{VoterAPI: f.URL, HTTP: shared, Token: "admin-key"})
+	voter := New(Config{VoterAPI: f.URL, HTTP: shared, Token: "voter-key"})
+	anonymous := New(Config{VoterAPI: f.URL, HTTP: shared})
+
+	ctx := context.Background()
+	for _, c := range []*Client{admin, voter, anonymous, admin} {
+		if _, err := c.Voters.List(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"Bearer admin-key", "Bearer voter-key", "", "Bearer admin-key"}
+	got := f.authorizations()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Authorization = %q, want %q", got, want)
+	}
+	if shared.Token != "" {
+		t.Errorf("the shared resty.Client got the Token %q", shared.Token)
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The kinds of error responses worth telling apart, an *Error matches the
+// one of its Status with errors.Is
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+)
+
+// Error is an error response of one of the APIs, the fields are those of its
+// JSON body (see the Errors section of the readme) along with the request it
+// was the response to
+type Error struct {
+	Status    int
+	Code      string
+	Message   string
+	Field     string `json:",omitempty"`
+	RequestID string `json:",omitempty"`
+	Details   any    `json:",omitempty"`
+
+	Method string `json:"-"`
+	Path   string `json:"-"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v %v responded %v %v: %v", e.Method, e.Path, e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// Is matches ErrNotFound to a 404 and ErrConflict to a 409
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrConflict:
+		return e.Status == http.StatusConflict
+	}
+	return false
+}
+
+// HTTPStatus is the status the API responded with
+func (e *Error) HTTPStatus() int {
+	return e.Status
+}
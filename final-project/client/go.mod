@@ -0,0 +1,7 @@
+module drexel.edu/client
+
+go 1.20
+
+require github.com/go-resty/resty/v2 v2.7.0
+
+require golang.org/x/net v0.0.0-20211029224645-99673261e6eb // indirect
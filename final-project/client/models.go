@@ -0,0 +1,113 @@
+package client
+
+import "time"
+
+// The models of the APIs, as they are sent and received as JSON
+
+type Vote struct {
+	VoteID    string
+	VoterID   string
+	PollID    string
+	VoteValue string
+	// VoteValues are the selected PollOptionIDs of a multi Poll, or the
+	// ranked PollOptionIDs (most preferred first) of a ranked Poll
+	VoteValues []string `json:",omitempty"`
+}
+
+// VoteCount is the number of Votes cast in a Poll, or that select a
+// pollOption
+type VoteCount struct {
+	Count int
+}
+
+// Selections returns the PollOptionIDs the Vote selects, in order
+func (v Vote) Selections() []string {
+	if len(v.VoteValues) > 0 {
+		return v.VoteValues
+	}
+	if v.VoteValue != "" {
+		return []string{v.VoteValue}
+	}
+	return nil
+}
+
+type VoterPoll struct {
+	PollID   string
+	VoteDate time.Time
+}
+
+type Voter struct {
+	VoterID     string
+	FirstName   string `json:",omitempty"`
+	LastName    string `json:",omitempty"`
+	VoteHistory []VoterPoll
+}
+
+type PollOption struct {
+	PollOptionID   string
+	PollOptionText string
+}
+
+type Poll struct {
+	PollID       string
+	PollTitle    string `json:",omitempty"`
+	PollQuestion string `json:",omitempty"`
+	PollOptions  []PollOption
+	Status       string
+	OpensAt      *time.Time `json:",omitempty"`
+	ClosesAt     *time.Time `json:",omitempty"`
+	PollType     string
+	// MaxSelections is how many PollOptions a Vote can select in a multi Poll
+	MaxSelections int `json:",omitempty"`
+}
+
+const (
+	PollTypeSingle = "single"
+	PollTypeMulti  = "multi"
+	PollTypeRanked = "ranked"
+)
+
+const (
+	PollStatusDraft  = "draft"
+	PollStatusOpen   = "open"
+	PollStatusClosed = "closed"
+)
+
+// IsOpen reports whether Votes can be cast in the Poll at the time now. The
+// Poll API works out the Status when the Poll is fetched, ClosesAt is checked
+// again here in case the Poll closed since.
+func (p Poll) IsOpen(now time.Time) bool {
+	if p.Status != PollStatusOpen {
+		return false
+	}
+	return p.ClosesAt == nil || now.Before(*p.ClosesAt)
+}
+
+type PollOptionResult struct {
+	PollOptionID   string
+	PollOptionText string
+	Votes          int
+	Percentage     float64
+}
+
+// RunoffRound is one round of the instant-runoff count of a ranked Poll.
+// Each continuing PollOption is credited with the Votes that rank it highest
+// among the continuing PollOptions, Exhausted is the number of Votes that
+// rank none of them.
+type RunoffRound struct {
+	Round      int
+	Results    []PollOptionResult
+	Exhausted  int
+	Eliminated []string `json:",omitempty"`
+}
+
+type PollResults struct {
+	PollID       string
+	PollTitle    string `json:",omitempty"`
+	PollQuestion string `json:",omitempty"`
+	PollType     string
+	TotalVotes   int
+	Results      []PollOptionResult
+	Rounds       []RunoffRound `json:",omitempty"`
+	Winner       string        `json:",omitempty"`
+}
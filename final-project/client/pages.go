@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// Page is one page of a list, e.g. GET /polls?cursor=&limit=. Next is the
+// path of the following page and is empty on the last page.
+type Page[T any] struct {
+	Items []T
+	Next  string `json:",omitempty"`
+}
+
+// PageOptions selects a page, Cursor 0 is the first page and Cursor is
+// otherwise taken from a Next link. Without a Limit the API's default (100)
+// is used.
+type PageOptions struct {
+	Cursor uint64
+	Limit  int64
+}
+
+func (o PageOptions) query() string {
+	if o.Limit > 0 {
+		return fmt.Sprintf("?cursor=%d&limit=%d", o.Cursor, o.Limit)
+	}
+	return fmt.Sprintf("?cursor=%d", o.Cursor)
+}
+
+// Pager walks the pages of a list:
+//
+//	pager := c.Polls.Pages(50)
+//	for pager.Next(ctx) {
+//		for _, poll := range pager.Page().Items {
+//			...
+//		}
+//	}
+//	if err := pager.Err(); err != nil {
+//		...
+//	}
+//
+// A page can hold slightly more or fewer Items than the limit, and an item
+// added or deleted while paging may or may not be returned (see the Paging
+// section of the readme).
+type Pager[T any] struct {
+	api  api
+	next string
+	page Page[T]
+	err  error
+}
+
+func newPager[T any](a api, path string, opts PageOptions) *Pager[T] {
+	return &Pager[T]{api: a, next: path + opts.query()}
+}
+
+// Next fetches the next page, it returns false once there are no more pages
+// or a page could not be fetched (see Err)
+func (p *Pager[T]) Next(ctx context.Context) bool {
+
+	if p.next == "" || p.err != nil {
+		return false
+	}
+
+	var page Page[T]
+	if err := p.api.get(ctx, p.next, &page); err != nil {
+		p.err = err
+		return false
+	}
+	p.page = page
+	p.next = page.Next
+	return true
+}
+
+// Page is the page fetched by the last Next
+func (p *Pager[T]) Page() Page[T] {
+	return p.page
+}
+
+// Err is why Next returned false, nil if it was the last page
+func (p *Pager[T]) Err() error {
+	return p.err
+}
+
+// All fetches the remaining pages and returns all their Items
+func (p *Pager[T]) All(ctx context.Context) ([]T, error) {
+	items := []T{}
+	for p.Next(ctx) {
+		items = append(items, p.page.Items...)
+	}
+	return items, p.err
+}
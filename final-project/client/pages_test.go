@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// pagedVotes serves n Votes as GET /votes pages, the way the APIs page them,
+// and fails the page at cursor failAt if it is not 0
+func pagedVotes(t *testing.T, n int, failAt uint64) *fakeAPI {
+	return newFakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+
+		cursor, _ := strconv.ParseUint(r.URL.Query().Get("cursor"), 10, 64)
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			limit = 100
+		}
+		if failAt != 0 && cursor == failAt {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"Status": 503, "Code": "UNAVAILABLE", "Message": "Redis is down."})
+			return
+		}
+
+		page := Page[Vote]{Items: []Vote{}}
+		for i := int(cursor); i < n && i < int(cursor)+limit; i++ {
+			page.Items = append(page.Items, Vote{VoteID: fmt.Sprintf("/votes/%v", i+1)})
+		}
+		if next := int(cursor) + limit; next < n {
+			page.Next = fmt.Sprintf("/votes?cursor=%v&limit=%v", next, limit)
+		}
+		writeJSON(w, http.StatusOK, page)
+	})
+}
+
+func voteIDs(votes []Vote) []string {
+	ids := []string{}
+	for _, vote := range votes {
+		ids = append(ids, vote.VoteID)
+	}
+	return ids
+}
+
+func TestPage(t *testing.T) {
+
+	f := pagedVotes(t, 5, 0)
+	c := New(Config{VotesAPI: f.URL})
+
+	page, err := c.Votes.Page(context.Background(), PageOptions{Cursor: 2, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/votes/3", "/votes/4"}; !reflect.DeepEqual(voteIDs(page.Items), want) {
+		t.Errorf("Items = %v, want %v", voteIDs(page.Items), want)
+	}
+	if page.Next != "/votes?cursor=4&limit=2" {
+		t.Errorf("Next = %q, want the page at cursor 4", page.Next)
+	}
+
+	// without a Limit the API's default is used
+	page, err = c.Votes.Page(context.Background(), PageOptions{})
+	if err != nil || len(page.Items) != 5 || page.Next != "" {
+		t.Errorf("Page() = %v items, Next %q, %v, want every Vote on the last page", len(page.Items), page.Next, err)
+	}
+}
+
+func TestPager(t *testing.T) {
+
+	f := pagedVotes(t, 7, 0)
+	c := New(Config{VotesAPI: f.URL})
+	ctx := context.Background()
+
+	pager := c.Votes.Pages(3)
+	sizes := []int{}
+	for pager.Next(ctx) {
+		sizes = append(sizes, len(pager.Page().Items))
+	}
+	if pager.Err() != nil {
+		t.Fatal(pager.Err())
+	}
+	if want := []int{3, 3, 1}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("page sizes = %v, want %v", sizes, want)
+	}
+	if pager.Next(ctx) {
+		t.Error("Next() = true after the last page")
+	}
+
+	votes, err := c.Votes.Pages(3).All(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/votes/1", "/votes/2", "/votes/3", "/votes/4", "/votes/5", "/votes/6", "/votes/7"}; !reflect.DeepEqual(voteIDs(votes), want) {
+		t.Errorf("All() = %v, want %v", voteIDs(votes), want)
+	}
+
+	empty := New(Config{VotesAPI: pagedVotes(t, 0, 0).URL})
+	if votes, err := empty.Votes.Pages(3).All(ctx); err != nil || len(votes) != 0 {
+		t.Errorf("All() of no Votes = %v, %v, want none", votes, err)
+	}
+}
+
+// A page that can't be fetched ends the paging with its error, All returns
+// the Items fetched before it
+func TestPagerError(t *testing.T) {
+
+	f := pagedVotes(t, 7, 3)
+	c := New(Config{VotesAPI: f.URL})
+	ctx := context.Background()
+
+	votes, err := c.Votes.Pages(3).All(ctx)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusServiceUnavailable || apiErr.Path != "/votes?cursor=3&limit=3" {
+		t.Fatalf("All() error = %v, want the 503 of the second page", err)
+	}
+	if want := []string{"/votes/1", "/votes/2", "/votes/3"}; !reflect.DeepEqual(voteIDs(votes), want) {
+		t.Errorf("All() = %v, want %v", voteIDs(votes), want)
+	}
+
+	pager := c.Votes.Pages(3)
+	pager.Next(ctx)
+	if pager.Next(ctx) || pager.Next(ctx) {
+		t.Error("Next() = true after a page failed")
+	}
+	if !errors.As(pager.Err(), &apiErr) {
+		t.Errorf("Err() = %v, want the error of the page", pager.Err())
+	}
+}
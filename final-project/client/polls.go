@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// PollsService calls the Poll API
+type PollsService struct {
+	api api
+}
+
+// List returns every Poll
+func (s *PollsService) List(ctx context.Context) ([]Poll, error) {
+	polls := []Poll{}
+	err := s.api.get(ctx, "/polls", &polls)
+	return polls, err
+}
+
+// Page returns one page of the Polls
+func (s *PollsService) Page(ctx context.Context, opts PageOptions) (Page[Poll], error) {
+	var page Page[Poll]
+	err := s.api.get(ctx, "/polls"+opts.query(), &page)
+	return page, err
+}
+
+// Pages returns a Pager over the Polls, limit at a time
+func (s *PollsService) Pages(limit int64) *Pager[Poll] {
+	return newPager[Poll](s.api, "/polls", PageOptions{Limit: limit})
+}
+
+// Get returns the Poll pollID
+func (s *PollsService) Get(ctx context.Context, pollID string) (Poll, error) {
+	var poll Poll
+	err := s.api.get(ctx, pollID, &poll)
+	return poll, err
+}
+
+// Add adds the Poll, its PollOptions are added with AddOption
+func (s *PollsService) Add(ctx context.Context, poll Poll) error {
+	return s.api.do(ctx, http.MethodPost, poll.PollID, poll, nil)
+}
+
+// Update changes the PollTitle and/or PollQuestion of the Poll (those that
+// are set) and returns it
+func (s *PollsService) Update(ctx context.Context, poll Poll) (Poll, error) {
+	var updated Poll
+	err := s.api.do(ctx, http.MethodPut, poll.PollID, poll, &updated)
+	return updated, err
+}
+
+// Open opens the Poll pollID so that Votes can be cast in it
+func (s *PollsService) Open(ctx context.Context, pollID string) (Poll, error) {
+	var poll Poll
+	err := s.api.do(ctx, http.MethodPost, pollID+"/open", nil, &poll)
+	return poll, err
+}
+
+// Close closes the Poll pollID, no more Votes can be cast in it
+func (s *PollsService) Close(ctx context.Context, pollID string) (Poll, error) {
+	var poll Poll
+	err := s.api.do(ctx, http.MethodPost, pollID+"/close", nil, &poll)
+	return poll, err
+}
+
+// Delete deletes the Poll pollID. A Poll with Votes is only deleted with
+// cascade, which deletes its Votes too.
+func (s *PollsService) Delete(ctx context.Context, pollID string, cascade bool) error {
+	return s.api.do(ctx, http.MethodDelete, pollID+cascadeQuery(cascade), nil, nil)
+}
+
+// Options returns the PollOptions of the Poll pollID
+func (s *PollsService) Options(ctx context.Context, pollID string) ([]PollOption, error) {
+	options := []PollOption{}
+	err := s.api.get(ctx, pollID+"/options", &options)
+	return options, err
+}
+
+// GetOption returns the pollOption optionID, e.g. /polls/1/options/2
+func (s *PollsService) GetOption(ctx context.Context, optionID string) (PollOption, error) {
+	var option PollOption
+	err := s.api.get(ctx, optionID, &option)
+	return option, err
+}
+
+// AddOption adds the pollOption to its Poll
+func (s *PollsService) AddOption(ctx context.Context, option PollOption) error {
+	return s.api.do(ctx, http.MethodPost, option.PollOptionID, Poll{PollOptions: []PollOption{option}}, nil)
+}
+
+// UpdateOption changes the PollOptionText of the pollOption and returns it.
+// A pollOption with Votes is only changed with force.
+func (s *PollsService) UpdateOption(ctx context.Context, option PollOption, force bool) (PollOption, error) {
+	path := option.PollOptionID
+	if force {
+		path += "?force=true"
+	}
+	var updated PollOption
+	err := s.api.do(ctx, http.MethodPut, path, Poll{PollOptions: []PollOption{option}}, &updated)
+	return updated, err
+}
+
+// DeleteOption deletes the pollOption optionID. A pollOption with Votes is
+// only deleted with cascade, which deletes its Votes too.
+func (s *PollsService) DeleteOption(ctx context.Context, optionID string, cascade bool) error {
+	return s.api.do(ctx, http.MethodDelete, optionID+cascadeQuery(cascade), nil, nil)
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// VotersService calls the Voter API
+type VotersService struct {
+	api api
+}
+
+// List returns every Voter
+func (s *VotersService) List(ctx context.Context) ([]Voter, error) {
+	voters := []Voter{}
+	err := s.api.get(ctx, "/voters", &voters)
+	return voters, err
+}
+
+// Page returns one page of the Voters
+func (s *VotersService) Page(ctx context.Context, opts PageOptions) (Page[Voter], error) {
+	var page Page[Voter]
+	err := s.api.get(ctx, "/voters"+opts.query(), &page)
+	return page, err
+}
+
+// Pages returns a Pager over the Voters, limit at a time
+func (s *VotersService) Pages(limit int64) *Pager[Voter] {
+	return newPager[Voter](s.api, "/voters", PageOptions{Limit: limit})
+}
+
+// Get returns the Voter voterID
+func (s *VotersService) Get(ctx context.Context, voterID string) (Voter, error) {
+	var voter Voter
+	err := s.api.get(ctx, voterID, &voter)
+	return voter, err
+}
+
+// Add adds the Voter, with an empty VoteHistory
+func (s *VotersService) Add(ctx context.Context, voter Voter) error {
+	return s.api.do(ctx, http.MethodPost, voter.VoterID, voter, nil)
+}
+
+// Delete deletes the Voter voterID. A Voter that has cast Votes is only
+// deleted with cascade, which deletes their Votes too.
+func (s *VotersService) Delete(ctx context.Context, voterID string, cascade bool) error {
+	return s.api.do(ctx, http.MethodDelete, voterID+cascadeQuery(cascade), nil, nil)
+}
+
+// History returns the VoteHistory of the Voter voterID
+func (s *VotersService) History(ctx context.Context, voterID string) ([]VoterPoll, error) {
+	history := []VoterPoll{}
+	err := s.api.get(ctx, voterID+"/polls", &history)
+	return history, err
+}
+
+// GetHistory returns the voterPoll of the Voter voterID in the Poll pollID
+func (s *VotersService) GetHistory(ctx context.Context, voterID string, pollID string) (VoterPoll, error) {
+	var voterPoll VoterPoll
+	err := s.api.get(ctx, voterID+pollID, &voterPoll)
+	return voterPoll, err
+}
+
+// The VoteHistory is only written by the Votes API, so AddHistory,
+// UpdateHistory and DeleteHistory need a Client whose calls are signed by it.
+
+// AddHistory adds the voterPoll to the VoteHistory of the Voter voterID
+func (s *VotersService) AddHistory(ctx context.Context, voterID string, voterPoll VoterPoll) error {
+	return s.historyRequest(ctx, http.MethodPost, voterID, voterPoll)
+}
+
+// UpdateHistory changes the VoteDate of the voterPoll of the Voter voterID
+func (s *VotersService) UpdateHistory(ctx context.Context, voterID string, voterPoll VoterPoll) error {
+	return s.historyRequest(ctx, http.MethodPut, voterID, voterPoll)
+}
+
+// DeleteHistory removes the voterPoll of the Voter voterID in the Poll
+// pollID from their VoteHistory
+func (s *VotersService) DeleteHistory(ctx context.Context, voterID string, pollID string) error {
+	return s.api.do(ctx, http.MethodDelete, voterID+pollID, nil, nil)
+}
+
+func (s *VotersService) historyRequest(ctx context.Context, method string, voterID string, voterPoll VoterPoll) error {
+	return s.api.do(ctx, method, voterID+voterPoll.PollID, Voter{VoteHistory: []VoterPoll{voterPoll}}, nil)
+}
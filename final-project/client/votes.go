@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// VotesService calls the Votes API
+type VotesService struct {
+	api api
+}
+
+// List returns every Vote
+func (s *VotesService) List(ctx context.Context) ([]Vote, error) {
+	votes := []Vote{}
+	err := s.api.get(ctx, "/votes", &votes)
+	return votes, err
+}
+
+// Page returns one page of the Votes
+func (s *VotesService) Page(ctx context.Context, opts PageOptions) (Page[Vote], error) {
+	var page Page[Vote]
+	err := s.api.get(ctx, "/votes"+opts.query(), &page)
+	return page, err
+}
+
+// Pages returns a Pager over the Votes, limit at a time
+func (s *VotesService) Pages(limit int64) *Pager[Vote] {
+	return newPager[Vote](s.api, "/votes", PageOptions{Limit: limit})
+}
+
+// Get returns the Vote voteID
+func (s *VotesService) Get(ctx context.Context, voteID string) (Vote, error) {
+	var vote Vote
+	err := s.api.get(ctx, voteID, &vote)
+	return vote, err
+}
+
+// Cast casts the Vote, which also adds the Poll to the VoteHistory of its
+// Voter. A Voter casts one Vote per Poll, a second one is ErrConflict.
+func (s *VotesService) Cast(ctx context.Context, vote Vote) error {
+	return s.api.do(ctx, http.MethodPost, vote.VoteID, vote, nil)
+}
+
+// Change changes the selections of the Vote
+func (s *VotesService) Change(ctx context.Context, vote Vote) error {
+	return s.api.do(ctx, http.MethodPut, vote.VoteID, vote, nil)
+}
+
+// Delete deletes the Vote voteID, and its Poll from the VoteHistory of its
+// Voter
+func (s *VotesService) Delete(ctx context.Context, voteID string) error {
+	return s.api.do(ctx, http.MethodDelete, voteID, nil, nil)
+}
+
+// ForPoll returns the Votes cast in the Poll pollID
+func (s *VotesService) ForPoll(ctx context.Context, pollID string) ([]Vote, error) {
+	return s.list(ctx, "/votes"+pollID+"/votes")
+}
+
+// ForPollOption returns the Votes that select the pollOption optionID
+func (s *VotesService) ForPollOption(ctx context.Context, optionID string) ([]Vote, error) {
+	return s.list(ctx, "/votes"+optionID+"/votes")
+}
+
+// CountForPoll returns the number of Votes cast in the Poll pollID, without
+// fetching them
+func (s *VotesService) CountForPoll(ctx context.Context, pollID string) (int, error) {
+	return s.count(ctx, "/votes"+pollID+"/votes/count")
+}
+
+// CountForPollOption returns the number of Votes that select the pollOption
+// optionID, without fetching them
+func (s *VotesService) CountForPollOption(ctx context.Context, optionID string) (int, error) {
+	return s.count(ctx, "/votes"+optionID+"/votes/count")
+}
+
+// ForVoter returns the Votes cast by the Voter voterID
+func (s *VotesService) ForVoter(ctx context.Context, voterID string) ([]Vote, error) {
+	return s.list(ctx, "/votes"+voterID+"/votes")
+}
+
+// The Votes of a Poll, pollOption or Voter are only deleted by an admin, or
+// by the Poll API or Voter API when it deletes what they were cast for.
+
+// DeleteForPoll deletes the Votes cast in the Poll pollID
+func (s *VotesService) DeleteForPoll(ctx context.Context, pollID string) error {
+	return s.api.do(ctx, http.MethodDelete, "/votes"+pollID+"/votes", nil, nil)
+}
+
+// DeleteForPollOption deletes the Votes that select the pollOption optionID
+func (s *VotesService) DeleteForPollOption(ctx context.Context, optionID string) error {
+	return s.api.do(ctx, http.MethodDelete, "/votes"+optionID+"/votes", nil, nil)
+}
+
+// DeleteForVoter deletes the Votes cast by the Voter voterID
+func (s *VotesService) DeleteForVoter(ctx context.Context, voterID string) error {
+	return s.api.do(ctx, http.MethodDelete, "/votes"+voterID+"/votes", nil, nil)
+}
+
+// Results returns the PollResults of the Poll pollID
+func (s *VotesService) Results(ctx context.Context, pollID string) (PollResults, error) {
+	var results PollResults
+	err := s.api.get(ctx, "/votes"+pollID+"/results", &results)
+	return results, err
+}
+
+// Poll returns the Poll pollID as relayed by the Votes API from the Poll API
+func (s *VotesService) Poll(ctx context.Context, pollID string) (Poll, error) {
+	var poll Poll
+	err := s.api.get(ctx, "/votes"+pollID, &poll)
+	return poll, err
+}
+
+func (s *VotesService) count(ctx context.Context, path string) (int, error) {
+	var count VoteCount
+	err := s.api.get(ctx, path, &count)
+	return count.Count, err
+}
+
+func (s *VotesService) list(ctx context.Context, path string) ([]Vote, error) {
+	votes := []Vote{}
+	err := s.api.get(ctx, path, &votes)
+	return votes, err
+}
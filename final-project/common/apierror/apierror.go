@@ -0,0 +1,176 @@
+// Package apierror is the error envelope returned by every handler of the
+// Poll, Voter and Votes APIs, along with the kinds of errors that map to
+// each HTTP status.
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"drexel.edu/common/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// The kinds of errors. The sentinel errors of the PollList and VoterList
+// wrap one of these so the handlers can tell which status to respond with.
+var (
+	ErrValidation = errors.New("invalid request")
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("already exists")
+	ErrForbidden  = errors.New("forbidden")
+	// ErrUnauthorized is for requests without valid credentials, ErrForbidden
+	// for credentials that are not allowed to do what was asked
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrUpstream     = errors.New("upstream API failure")
+	// ErrUnavailable is for calls to another API that were not even tried,
+	// because its circuit breaker is open
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// The Codes returned in the ErrorResponse, one per kind of error
+const (
+	CodeValidation   = "VALIDATION_FAILED"
+	CodeNotFound     = "NOT_FOUND"
+	CodeConflict     = "CONFLICT"
+	CodeForbidden    = "FORBIDDEN"
+	CodeUnauthorized = "UNAUTHORIZED"
+	CodeUpstream     = "UPSTREAM_FAILURE"
+	CodeUnavailable  = "SERVICE_UNAVAILABLE"
+	CodeInternal     = "INTERNAL_ERROR"
+)
+
+// ErrorResponse is the JSON body of every error response
+type ErrorResponse struct {
+	Status    int
+	Code      string
+	Message   string
+	Field     string `json:",omitempty"`
+	RequestID string `json:",omitempty"`
+	Details   any    `json:",omitempty"`
+}
+
+// Error is an error with a message meant for the caller and, if the error
+// is about one of the fields of the request, the name of that field
+type Error struct {
+	Err     error
+	Field   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns an *Error that wraps err, which is usually one of the kinds
+// above or a sentinel error that wraps one of them
+func Wrap(err error, field string, format string, args ...any) *Error {
+	return &Error{Err: err, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+func Validation(field string, format string, args ...any) *Error {
+	return Wrap(ErrValidation, field, format, args...)
+}
+
+func NotFound(field string, format string, args ...any) *Error {
+	return Wrap(ErrNotFound, field, format, args...)
+}
+
+func Conflict(field string, format string, args ...any) *Error {
+	return Wrap(ErrConflict, field, format, args...)
+}
+
+func Forbidden(field string, format string, args ...any) *Error {
+	return Wrap(ErrForbidden, field, format, args...)
+}
+
+func Unauthorized(field string, format string, args ...any) *Error {
+	return Wrap(ErrUnauthorized, field, format, args...)
+}
+
+func Upstream(field string, format string, args ...any) *Error {
+	return Wrap(ErrUpstream, field, format, args...)
+}
+
+// Unreachable is the error for a call to another API that failed with err
+// before there was a response: ErrUnavailable if err is (the circuit breaker
+// of that API is open), ErrUpstream otherwise
+func Unreachable(err error, field string, format string, args ...any) *Error {
+	if errors.Is(err, ErrUnavailable) {
+		return Wrap(ErrUnavailable, field, format, args...)
+	}
+	return Upstream(field, format, args...)
+}
+
+// Call is the error for a call to another API (made with the client module)
+// that failed with err: ErrNotFound if the API responded 404, ErrUpstream if
+// it responded with another error, and Unreachable if it didn't respond
+func Call(err error, field string, format string, args ...any) *Error {
+	var responded interface{ HTTPStatus() int }
+	if !errors.As(err, &responded) {
+		return Unreachable(err, field, format, args...)
+	}
+	if responded.HTTPStatus() == http.StatusNotFound {
+		return NotFound(field, format, args...)
+	}
+	return Upstream(field, format, args...)
+}
+
+// Status returns the HTTP status and Code matching the kind of err, errors
+// of an unknown kind are internal errors
+func Status(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrValidation):
+		return http.StatusBadRequest, CodeValidation
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict, CodeConflict
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden, CodeForbidden
+	case errors.Is(err, ErrUnauthorized):
+		return http.StatusUnauthorized, CodeUnauthorized
+	case errors.Is(err, ErrUpstream):
+		return http.StatusBadGateway, CodeUpstream
+	case errors.Is(err, ErrUnavailable):
+		return http.StatusServiceUnavailable, CodeUnavailable
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
+
+// NewResponse builds the ErrorResponse for err
+func NewResponse(c *gin.Context, err error) ErrorResponse {
+	status, code := Status(err)
+	resp := ErrorResponse{
+		Status:    status,
+		Code:      code,
+		Message:   err.Error(),
+		RequestID: requestid.Get(c),
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		resp.Field = apiErr.Field
+	}
+
+	return resp
+}
+
+// Abort stops the request and responds with the ErrorResponse for err
+func Abort(c *gin.Context, err error) {
+	resp := NewResponse(c, err)
+	c.AbortWithStatusJSON(resp.Status, resp)
+}
+
+// AbortWithDetails is Abort with extra information about the error (e.g.
+// the outcome of a rollback) included in the response
+func AbortWithDetails(c *gin.Context, err error, details any) {
+	resp := NewResponse(c, err)
+	resp.Details = details
+	c.AbortWithStatusJSON(resp.Status, resp)
+}
@@ -0,0 +1,313 @@
+// Package audit keeps an append-only log of every change to the Polls,
+// Voters and Votes: who made it (the authenticated caller), with which
+// request, and the document before and after. The log is a redis stream
+// shared by the three APIs, or kept in memory next to the in-memory stores,
+// and entries are never changed or removed.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"drexel.edu/common/auth"
+	"drexel.edu/common/requestid"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// StreamKey is the redis stream the entries are appended to
+const StreamKey = "audit"
+
+// The Entities an Entry can be about
+const (
+	EntityPoll  = "poll"
+	EntityVoter = "voter"
+	EntityVote  = "vote"
+)
+
+// The Actions an Entry can record
+const (
+	ActionAdd    = "add"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionImport = "import"
+)
+
+// Entry is one change to a Poll, Voter or Vote. Before is left out for an
+// add, After for a delete.
+type Entry struct {
+	ID        string
+	Timestamp time.Time
+	Entity    string
+	EntityID  string
+	Action    string
+	Actor     string
+	ActorRole string
+	RequestID string          `json:",omitempty"`
+	Before    json.RawMessage `json:",omitempty"`
+	After     json.RawMessage `json:",omitempty"`
+}
+
+// Filter selects the entries returned by Log.Query. Entity is either one of
+// the Entities or the ID of a Poll, Voter or Vote ("/polls/1"), After is the
+// ID of the entry to continue after.
+type Filter struct {
+	Entity string
+	Since  time.Time
+	After  string
+	Limit  int64
+}
+
+// Log is where the entries are appended, Query returns them oldest first
+// along with the ID to continue after ("" when there are no more)
+type Log interface {
+	Append(ctx context.Context, entry Entry) (string, error)
+	Query(ctx context.Context, filter Filter) ([]Entry, string, error)
+}
+
+// Actor is who made a change and with which request
+type Actor struct {
+	Name      string
+	Role      string
+	RequestID string
+}
+
+// ActorFrom returns the Actor of the request, the Principal authenticated by
+// the auth middleware. Without one (authorization is disabled) the Actor is
+// anonymous.
+func ActorFrom(c *gin.Context) Actor {
+	actor := Actor{Name: "anonymous", RequestID: requestid.Get(c)}
+	if principal, ok := auth.PrincipalFrom(c); ok {
+		actor.Name = principal.Subject
+		actor.Role = principal.Role
+	}
+	return actor
+}
+
+// CommandActor returns the Actor of a command run from the command line,
+// e.g. "poll-api import"
+func CommandActor(command string) Actor {
+	return Actor{Name: command, Role: "command"}
+}
+
+// Record appends the change to the log. The change has already been made,
+// so an error is only logged rather than returned.
+func Record(ctx context.Context, l Log, actor Actor, entity string, entityID string, action string, before any, after any) {
+
+	entry := Entry{
+		Timestamp: time.Now().UTC(),
+		Entity:    entity,
+		EntityID:  entityID,
+		Action:    action,
+		Actor:     actor.Name,
+		ActorRole: actor.Role,
+		RequestID: actor.RequestID,
+	}
+
+	var err error
+	if entry.Before, err = marshal(before); err == nil {
+		entry.After, err = marshal(after)
+	}
+	if err == nil {
+		_, err = l.Append(ctx, entry)
+	}
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error recording the %v of %v %v in the audit log: ", action, entity, entityID), err)
+	}
+}
+
+func marshal(doc any) (json.RawMessage, error) {
+	if doc == nil {
+		return nil, nil
+	}
+	return json.Marshal(doc)
+}
+
+// matches reports whether the entry is selected by the filter, apart from
+// its After
+func (f Filter) matches(entry Entry) bool {
+	if f.Entity != "" && entry.Entity != f.Entity && entry.EntityID != f.Entity {
+		return false
+	}
+	return f.Since.IsZero() || !entry.Timestamp.Before(f.Since)
+}
+
+// The entry IDs are those of redis streams, <milliseconds>-<sequence>
+
+func parseID(id string) (ms uint64, seq uint64, err error) {
+	msS, seqS, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q is not an audit entry ID", id)
+	}
+	if ms, err = strconv.ParseUint(msS, 10, 64); err == nil {
+		seq, err = strconv.ParseUint(seqS, 10, 64)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q is not an audit entry ID", id)
+	}
+	return ms, seq, nil
+}
+
+// nextID returns the smallest ID after id
+func nextID(id string) (string, error) {
+	ms, seq, err := parseID(id)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", ms, seq+1), nil
+}
+
+//------------------------------------------------------------
+// REDIS
+//------------------------------------------------------------
+
+// Redis appends the entries to the redis stream StreamKey, each as JSON in
+// the field "entry"
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// Append adds the entry to the stream and returns its ID
+func (l *Redis) Append(ctx context.Context, entry Entry) (string, error) {
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	return l.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: StreamKey,
+		ID:     "*",
+		Values: map[string]interface{}{"entry": string(data)},
+	}).Result()
+}
+
+// Query reads the stream with XRANGE from filter.After or filter.Since, and
+// keeps reading until it has filter.Limit entries or reaches the end
+func (l *Redis) Query(ctx context.Context, filter Filter) ([]Entry, string, error) {
+
+	start := "-"
+	if filter.After != "" {
+		next, err := nextID(filter.After)
+		if err != nil {
+			return nil, "", err
+		}
+		start = next
+	} else if !filter.Since.IsZero() {
+		start = strconv.FormatInt(filter.Since.UnixMilli(), 10)
+	}
+
+	entries := make([]Entry, 0)
+	for {
+		msgs, err := l.client.XRangeN(ctx, StreamKey, start, "+", filter.Limit).Result()
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, msg := range msgs {
+			var entry Entry
+			data, _ := msg.Values["entry"].(string)
+			if err := json.Unmarshal([]byte(data), &entry); err != nil {
+				return nil, "", fmt.Errorf("reading audit entry %v: %w", msg.ID, err)
+			}
+			entry.ID = msg.ID
+
+			if filter.matches(entry) {
+				entries = append(entries, entry)
+				if int64(len(entries)) >= filter.Limit {
+					return entries, entry.ID, nil
+				}
+			}
+		}
+
+		if int64(len(msgs)) < filter.Limit {
+			return entries, "", nil
+		}
+		if start, err = nextID(msgs[len(msgs)-1].ID); err != nil {
+			return nil, "", err
+		}
+	}
+}
+
+//------------------------------------------------------------
+// MEMORY
+//------------------------------------------------------------
+
+// Memory keeps the entries in memory, they are lost when the process exits.
+// It is safe for concurrent use.
+type Memory struct {
+	mu      sync.RWMutex
+	entries []Entry
+	lastMs  uint64
+	lastSeq uint64
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Append adds the entry and returns its ID, which like a redis stream ID is
+// the time it was added in milliseconds and a sequence number
+func (l *Memory) Append(ctx context.Context, entry Entry) (string, error) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ms := uint64(entry.Timestamp.UnixMilli())
+	if ms <= l.lastMs {
+		ms = l.lastMs
+		l.lastSeq++
+	} else {
+		l.lastSeq = 0
+	}
+	l.lastMs = ms
+
+	entry.ID = fmt.Sprintf("%d-%d", ms, l.lastSeq)
+	l.entries = append(l.entries, entry)
+	return entry.ID, nil
+}
+
+// Query returns up to filter.Limit of the entries after filter.After that
+// match the filter
+func (l *Memory) Query(ctx context.Context, filter Filter) ([]Entry, string, error) {
+
+	var afterMs, afterSeq uint64
+	if filter.After != "" {
+		var err error
+		if afterMs, afterSeq, err = parseID(filter.After); err != nil {
+			return nil, "", err
+		}
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	entries := make([]Entry, 0)
+	for _, entry := range l.entries {
+		if filter.After != "" {
+			ms, seq, _ := parseID(entry.ID)
+			if ms < afterMs || (ms == afterMs && seq <= afterSeq) {
+				continue
+			}
+		}
+		if !filter.matches(entry) {
+			continue
+		}
+
+		if int64(len(entries)) >= filter.Limit {
+			return entries, entries[len(entries)-1].ID, nil
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, "", nil
+}
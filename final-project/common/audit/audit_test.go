@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"drexel.edu/common/auth"
+	"drexel.edu/common/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+type poll struct {
+	PollID    string
+	PollTitle string
+}
+
+// A change made through a route behind the auth middleware is recorded
+// with the caller and the request id, and the documents before and after it
+func TestRecordActor(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	l := NewMemory()
+	authn := auth.New(map[string]auth.Principal{"key": {Subject: "alice", Role: auth.RolePollManager}}, nil, nil, auth.NewMemoryNonces())
+
+	r := gin.New()
+	r.Use(requestid.Middleware())
+	r.PUT("/polls/1", authn.Require(auth.RolePollManager), func(c *gin.Context) {
+		Record(c.Request.Context(), l, ActorFrom(c), EntityPoll, "/polls/1", ActionUpdate, poll{"/polls/1", "Lunch"}, poll{"/polls/1", "Dinner"})
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/polls/1", nil)
+	req.Header.Set("Authorization", "Bearer key")
+	req.Header.Set(requestid.Header, "request-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries, _, err := l.Query(context.Background(), Filter{Limit: 10})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Query() = %v, %v, want the one entry", entries, err)
+	}
+	entry := entries[0]
+	if entry.Actor != "alice" || entry.ActorRole != auth.RolePollManager || entry.RequestID != "request-1" {
+		t.Errorf("recorded by %v (%v) with %q, want alice (%v) with request-1", entry.Actor, entry.ActorRole, entry.RequestID, auth.RolePollManager)
+	}
+	if string(entry.Before) != `{"PollID":"/polls/1","PollTitle":"Lunch"}` || string(entry.After) != `{"PollID":"/polls/1","PollTitle":"Dinner"}` {
+		t.Errorf("Before, After = %s, %s", entry.Before, entry.After)
+	}
+}
+
+// Entries appended within the same millisecond get increasing IDs, so that
+// a cursor never skips one
+func TestMemoryAppendIDs(t *testing.T) {
+
+	l := NewMemory()
+	now := time.Now()
+
+	var ids []string
+	for _, at := range []time.Time{now, now, now.Add(-time.Second), now.Add(time.Second)} {
+		id, err := l.Append(context.Background(), Entry{Timestamp: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	ms := now.UnixMilli()
+	want := []string{fmt.Sprintf("%d-0", ms), fmt.Sprintf("%d-1", ms), fmt.Sprintf("%d-2", ms), fmt.Sprintf("%d-0", ms+1000)}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("IDs = %v, want %v", ids, want)
+	}
+}
+
+// GET /admin/audit pages through the entries the query selects
+func TestHandler(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	l := NewMemory()
+	start := time.Date(2023, 8, 20, 18, 0, 0, 0, time.UTC)
+	changes := []Entry{
+		{Entity: EntityPoll, EntityID: "/polls/1", Action: ActionAdd},
+		{Entity: EntityVoter, EntityID: "/voters/1", Action: ActionAdd},
+		{Entity: EntityPoll, EntityID: "/polls/1", Action: ActionUpdate},
+		{Entity: EntityPoll, EntityID: "/polls/2", Action: ActionAdd},
+		{Entity: EntityVote, EntityID: "/votes/1", Action: ActionAdd},
+		{Entity: EntityPoll, EntityID: "/polls/1", Action: ActionDelete},
+	}
+	for i, entry := range changes {
+		entry.Timestamp = start.Add(time.Duration(i) * time.Minute)
+		if _, err := l.Append(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := gin.New()
+	r.GET("/admin/audit", Handler(l))
+
+	// describe lists the EntityID and Action of every entry on every page,
+	// following the next links
+	describe := func(t *testing.T, path string) []string {
+		t.Helper()
+		var changes []string
+		for path != "" {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET %v = %v: %v", path, w.Code, w.Body)
+			}
+			var page Page
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range page.Items {
+				changes = append(changes, entry.EntityID+" "+entry.Action)
+			}
+			path = page.Next
+		}
+		return changes
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"everything", "/admin/audit", []string{"/polls/1 add", "/voters/1 add", "/polls/1 update", "/polls/2 add", "/votes/1 add", "/polls/1 delete"}},
+		{"two at a time", "/admin/audit?limit=2", []string{"/polls/1 add", "/voters/1 add", "/polls/1 update", "/polls/2 add", "/votes/1 add", "/polls/1 delete"}},
+		{"the polls", "/admin/audit?entity=poll&limit=1", []string{"/polls/1 add", "/polls/1 update", "/polls/2 add", "/polls/1 delete"}},
+		{"one poll", "/admin/audit?entity=/polls/1", []string{"/polls/1 add", "/polls/1 update", "/polls/1 delete"}},
+		{"since", "/admin/audit?since=2023-08-20T18:03:00Z", []string{"/polls/2 add", "/votes/1 add", "/polls/1 delete"}},
+		{"the polls since", "/admin/audit?entity=poll&since=2023-08-20T18:02:00Z&limit=1", []string{"/polls/1 update", "/polls/2 add", "/polls/1 delete"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := describe(t, tt.path); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, path := range []string{
+		"/admin/audit?entity=pollOption",
+		"/admin/audit?since=yesterday",
+		"/admin/audit?cursor=3",
+		"/admin/audit?limit=0",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %v = %v, want 400", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/paging"
+	"drexel.edu/common/requestid"
+	"github.com/gin-gonic/gin"
+)
+
+// Page is one page of the audit log, Next is the link to the following page
+// and is left out on the last page
+type Page struct {
+	Items []Entry
+	Next  string `json:",omitempty"`
+}
+
+// Handler returns the implementation for GET /admin/audit (and the Votes
+// API's GET /votes/admin/audit)
+// returns a Page of the entries of the audit log, oldest first, optionally
+// only those of ?entity= (poll, voter, vote or the ID of one) made ?since=
+// an RFC 3339 time, ?limit= (100 by default, at most 1000) at a time
+func Handler(l Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		ctx := c.Request.Context()
+
+		filter, err := filterParams(c)
+		if err != nil {
+			requestid.Logger(ctx).Println("Error reading the audit log parameters: ", err)
+			apierror.Abort(c, err)
+			return
+		}
+
+		entries, next, err := l.Query(ctx, filter)
+		if err != nil {
+			requestid.Logger(ctx).Println("Error reading the audit log: ", err)
+			apierror.Abort(c, err)
+			return
+		}
+
+		page := Page{Items: entries}
+		if next != "" {
+			query := c.Request.URL.Query()
+			query.Set("cursor", next)
+			query.Set("limit", strconv.FormatInt(filter.Limit, 10))
+			page.Next = c.Request.URL.Path + "?" + query.Encode()
+		}
+
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// filterParams reads the ?entity=, ?since=, ?cursor= and ?limit= query
+// parameters
+func filterParams(c *gin.Context) (Filter, error) {
+
+	filter := Filter{Entity: c.Query("entity"), After: c.Query("cursor"), Limit: paging.DefaultLimit}
+
+	switch {
+	case filter.Entity == "", filter.Entity == EntityPoll, filter.Entity == EntityVoter, filter.Entity == EntityVote:
+	case strings.HasPrefix(filter.Entity, "/"):
+		// the ID of a Poll, Voter or Vote, a pollOption is part of its Poll
+	default:
+		return filter, apierror.Validation("entity", "entity must be %v, %v, %v or the ID of one, %q given.", EntityPoll, EntityVoter, EntityVote, filter.Entity)
+	}
+
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, apierror.Validation("since", "since must be an RFC 3339 time, e.g. 2023-08-20T18:30:00Z, %q given.", since)
+		}
+		filter.Since = t
+	}
+
+	if filter.After != "" {
+		if _, _, err := parseID(filter.After); err != nil {
+			return filter, apierror.Validation("cursor", "cursor must be a cursor returned in a next link, %q given.", filter.After)
+		}
+	}
+
+	if limitS, ok := c.GetQuery("limit"); ok {
+		limit, err := paging.ParseLimit(limitS)
+		if err != nil {
+			return filter, err
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,300 @@
+// Package auth authenticates the callers of the Poll, Voter and Votes APIs
+// and checks that they are allowed to call the mutating (POST, PUT and
+// DELETE) routes. A caller sends either an API key or an HMAC-signed (HS256)
+// JWT as a bearer token:
+//
+//	Authorization: Bearer <API key or JWT>
+//
+// Every caller has a subject and a role. The admin role is allowed on every
+// route, the other roles only on the routes they are listed for. A voter's
+// subject is their VoterID, so the Votes API can check that a voter only
+// casts their own Vote.
+//
+// The APIs call each other with requests signed by the calling API (see
+// service.go), rather than with a bearer token.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/requestid"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The roles a caller can have
+const (
+	RoleAdmin       = "admin"
+	RolePollManager = "poll-manager"
+	RoleVoter       = "voter"
+	// the role of an API calling another API with a signed request, its
+	// subject is the name of the calling API
+	RoleService = "service"
+)
+
+// The environment variables the Authenticator and the service client are
+// configured with, the same for all three APIs
+const (
+	// comma separated <key>:<role>[:<subject>], e.g.
+	// "k1:admin,k2:poll-manager,k3:voter:/voters/1"
+	EnvAPIKeys = "AUTH_API_KEYS"
+	// the HMAC secret JWTs are signed with
+	EnvJWTSecret = "AUTH_JWT_SECRET"
+	// set to true to let every request through, for local development
+	EnvDisabled = "AUTH_DISABLED"
+	// comma separated <service>:<secret>, the secrets the APIs sign their
+	// calls to each other with
+	EnvServiceSecrets = "AUTH_SERVICE_SECRETS"
+)
+
+// contextKey is where the Principal is kept in the gin.Context
+const contextKey = "auth.principal"
+
+// Principal is the authenticated caller
+type Principal struct {
+	Subject string
+	Role    string
+}
+
+// Authenticator checks the bearer tokens of the requests
+type Authenticator struct {
+	apiKeys   map[string]Principal
+	jwtSecret []byte
+	services  map[string][]byte
+	nonces    Nonces
+	disabled  bool
+}
+
+// claims are the claims of a JWT, sub is the Principal's Subject
+type claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// New returns an Authenticator that accepts the apiKeys, the JWTs signed with
+// jwtSecret and the requests signed by the other APIs with serviceSecrets,
+// each of which only once (see Nonces)
+func New(apiKeys map[string]Principal, jwtSecret []byte, serviceSecrets map[string][]byte, nonces Nonces) *Authenticator {
+	return &Authenticator{apiKeys: apiKeys, jwtSecret: jwtSecret, services: serviceSecrets, nonces: nonces}
+}
+
+// Disabled returns an Authenticator that lets every request through
+func Disabled() *Authenticator {
+	return &Authenticator{disabled: true}
+}
+
+// FromEnv returns the Authenticator configured by AUTH_API_KEYS,
+// AUTH_JWT_SECRET, AUTH_SERVICE_SECRETS and AUTH_DISABLED, which remembers
+// the nonces of the signed requests in nonces. With neither API keys nor a
+// JWT secret every request to a protected route is rejected, except for the
+// signed requests of the other APIs.
+func FromEnv(nonces Nonces) (*Authenticator, error) {
+
+	if disabled := os.Getenv(EnvDisabled); strings.EqualFold(disabled, "true") {
+		log.Println("WARNING: " + EnvDisabled + " is set, every request is allowed")
+		return Disabled(), nil
+	}
+
+	apiKeys, err := ParseAPIKeys(os.Getenv(EnvAPIKeys))
+	if err != nil {
+		return nil, err
+	}
+
+	serviceSecrets, err := ParseServiceSecrets(os.Getenv(EnvServiceSecrets))
+	if err != nil {
+		return nil, err
+	}
+
+	a := New(apiKeys, []byte(os.Getenv(EnvJWTSecret)), serviceSecrets, nonces)
+	if len(a.apiKeys) == 0 && len(a.jwtSecret) == 0 {
+		log.Println("WARNING: neither " + EnvAPIKeys + " nor " + EnvJWTSecret + " is set, every request to a protected route is rejected")
+	}
+
+	return a, nil
+}
+
+// ParseAPIKeys parses the comma separated <key>:<role>[:<subject>] list of
+// AUTH_API_KEYS. The subject of a key without one is the role.
+func ParseAPIKeys(s string) (map[string]Principal, error) {
+
+	apiKeys := make(map[string]Principal)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%v: %q is not <key>:<role>[:<subject>]", EnvAPIKeys, entry)
+		}
+		if !isRole(parts[1]) {
+			return nil, fmt.Errorf("%v: unknown role %q", EnvAPIKeys, parts[1])
+		}
+
+		principal := Principal{Subject: parts[1], Role: parts[1]}
+		if len(parts) == 3 {
+			principal.Subject = parts[2]
+		}
+		apiKeys[parts[0]] = principal
+	}
+
+	return apiKeys, nil
+}
+
+func isRole(role string) bool {
+	return role == RoleAdmin || role == RolePollManager || role == RoleVoter
+}
+
+// Require returns a middleware that only lets the request through if it
+// carries a valid bearer token of an admin or of one of roles. The
+// Principal can then be read with PrincipalFrom.
+func (a *Authenticator) Require(roles ...string) gin.HandlerFunc {
+	return a.require(func(principal Principal) bool {
+		return principal.HasRole(RoleAdmin) || principal.HasRole(roles...)
+	})
+}
+
+// RequireService returns a middleware that only lets the request through if
+// it is signed by one of services, not even an admin is allowed
+func (a *Authenticator) RequireService(services ...string) gin.HandlerFunc {
+	return a.require(func(principal Principal) bool {
+		return principal.isService(services...)
+	})
+}
+
+// RequireAdminOrService returns a middleware that only lets the request
+// through if it carries a valid bearer token of an admin or is signed by one
+// of services
+func (a *Authenticator) RequireAdminOrService(services ...string) gin.HandlerFunc {
+	return a.require(func(principal Principal) bool {
+		return principal.HasRole(RoleAdmin) || principal.isService(services...)
+	})
+}
+
+func (a *Authenticator) require(allowed func(Principal) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.disabled {
+			c.Next()
+			return
+		}
+
+		var principal Principal
+		var err error
+		if c.GetHeader(HeaderServiceSignature) != "" {
+			principal, err = a.verifyService(c.Request)
+		} else {
+			principal, err = a.authenticate(c.GetHeader("Authorization"))
+		}
+		if err != nil {
+			requestid.Logger(c.Request.Context()).Println(fmt.Sprintf("Rejected %v %v: ", c.Request.Method, c.Request.URL.Path), err)
+			apierror.Abort(c, err)
+			return
+		}
+
+		if !allowed(principal) {
+			apierror.Abort(c, apierror.Forbidden("Authorization", "%v %v is not allowed for %v.", c.Request.Method, c.FullPath(), principal))
+			return
+		}
+
+		c.Set(contextKey, principal)
+		c.Next()
+	}
+}
+
+// authenticate returns the Principal of the Authorization header
+func (a *Authenticator) authenticate(header string) (Principal, error) {
+
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, apierror.Unauthorized("Authorization", "A bearer token is required.")
+	}
+
+	if principal, ok := a.apiKeys[token]; ok {
+		return principal, nil
+	}
+
+	// API keys are looked up as is, anything else has to be a JWT
+	if len(a.jwtSecret) == 0 || strings.Count(token, ".") != 2 {
+		return Principal{}, apierror.Unauthorized("Authorization", "The bearer token is not a valid API key.")
+	}
+
+	var tokenClaims claims
+	_, err := jwt.ParseWithClaims(token, &tokenClaims, func(*jwt.Token) (any, error) {
+		return a.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return Principal{}, apierror.Unauthorized("Authorization", "The bearer token is not a valid JWT: %v", err)
+	}
+	if tokenClaims.Subject == "" || !isRole(tokenClaims.Role) {
+		return Principal{}, apierror.Unauthorized("Authorization", "The JWT needs a sub and a role (%v, %v or %v).", RoleAdmin, RolePollManager, RoleVoter)
+	}
+
+	return Principal{Subject: tokenClaims.Subject, Role: tokenClaims.Role}, nil
+}
+
+// NewJWT returns a JWT for the Principal signed with secret, which expires
+// after ttl
+func NewJWT(secret []byte, principal Principal, ttl time.Duration) (string, error) {
+
+	if !isRole(principal.Role) {
+		return "", errors.New("unknown role " + principal.Role)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Role: principal.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   principal.Subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+	return token.SignedString(secret)
+}
+
+func (p Principal) String() string {
+	if p.Role == RoleService {
+		return "the service " + p.Subject
+	}
+	return "the role " + p.Role
+}
+
+// isService reports whether the Principal is one of services
+func (p Principal) isService(services ...string) bool {
+	if p.Role != RoleService {
+		return false
+	}
+	for _, service := range services {
+		if p.Subject == service {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the Principal has one of roles
+func (p Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// PrincipalFrom returns the Principal that Require authenticated, it is
+// false when authentication is disabled
+func PrincipalFrom(c *gin.Context) (Principal, bool) {
+	principal, ok := c.Get(contextKey)
+	if !ok {
+		return Principal{}, false
+	}
+	return principal.(Principal), true
+}
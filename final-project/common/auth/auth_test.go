@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter returns a router with a route behind each of the auth
+// middlewares, each of which answers with the Principal it was called by
+func newTestRouter(a *Authenticator) *gin.Engine {
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+
+	whoami := func(c *gin.Context) {
+		principal, ok := PrincipalFrom(c)
+		if !ok {
+			c.String(http.StatusOK, "nobody")
+			return
+		}
+		c.String(http.StatusOK, principal.Subject)
+	}
+	r.POST("/polls", a.Require(RolePollManager), whoami)
+	r.PUT("/voters/1/polls/1", a.RequireService(ServiceVotesAPI), whoami)
+	r.DELETE("/votes/1", a.RequireAdminOrService(ServicePollAPI), whoami)
+
+	return r
+}
+
+func TestRequire(t *testing.T) {
+
+	jwtSecret := []byte("jwt-secret")
+	a := New(map[string]Principal{
+		"admin-key":   {Subject: "alice", Role: RoleAdmin},
+		"manager-key": {Subject: "bob", Role: RolePollManager},
+		"voter-key":   {Subject: "carol", Role: RoleVoter},
+	}, jwtSecret, map[string][]byte{
+		ServiceVotesAPI: []byte("votes-secret"),
+		ServicePollAPI:  []byte("poll-secret"),
+	}, NewMemoryNonces())
+	r := newTestRouter(a)
+
+	managerJWT, err := NewJWT(jwtSecret, Principal{Subject: "dave", Role: RolePollManager}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredJWT, err := NewJWT(jwtSecret, Principal{Subject: "dave", Role: RolePollManager}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherJWT, err := NewJWT([]byte("another-secret"), Principal{Subject: "dave", Role: RoleAdmin}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bearer := func(token string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+	signedBy := func(service, secret string) func(*http.Request) {
+		return func(r *http.Request) {
+			if err := SignRequest(r, service, []byte(secret)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		as         func(*http.Request)
+		wantStatus int
+		wantCaller string
+	}{
+		{"Require: no token", http.MethodPost, "/polls", func(*http.Request) {}, http.StatusUnauthorized, ""},
+		{"Require: not a bearer token", http.MethodPost, "/polls", func(r *http.Request) { r.Header.Set("Authorization", "manager-key") }, http.StatusUnauthorized, ""},
+		{"Require: unknown API key", http.MethodPost, "/polls", bearer("guess"), http.StatusUnauthorized, ""},
+		{"Require: the role", http.MethodPost, "/polls", bearer("manager-key"), http.StatusOK, "bob"},
+		{"Require: an admin", http.MethodPost, "/polls", bearer("admin-key"), http.StatusOK, "alice"},
+		{"Require: another role", http.MethodPost, "/polls", bearer("voter-key"), http.StatusForbidden, ""},
+		{"Require: a JWT of the role", http.MethodPost, "/polls", bearer(managerJWT), http.StatusOK, "dave"},
+		{"Require: an expired JWT", http.MethodPost, "/polls", bearer(expiredJWT), http.StatusUnauthorized, ""},
+		{"Require: a JWT signed with another secret", http.MethodPost, "/polls", bearer(otherJWT), http.StatusUnauthorized, ""},
+		{"Require: a service", http.MethodPost, "/polls", signedBy(ServiceVotesAPI, "votes-secret"), http.StatusForbidden, ""},
+
+		{"RequireService: the service", http.MethodPut, "/voters/1/polls/1", signedBy(ServiceVotesAPI, "votes-secret"), http.StatusOK, ServiceVotesAPI},
+		{"RequireService: another service", http.MethodPut, "/voters/1/polls/1", signedBy(ServicePollAPI, "poll-secret"), http.StatusForbidden, ""},
+		{"RequireService: an admin", http.MethodPut, "/voters/1/polls/1", bearer("admin-key"), http.StatusForbidden, ""},
+		{"RequireService: a wrong secret", http.MethodPut, "/voters/1/polls/1", signedBy(ServiceVotesAPI, "poll-secret"), http.StatusUnauthorized, ""},
+
+		{"RequireAdminOrService: an admin", http.MethodDelete, "/votes/1", bearer("admin-key"), http.StatusOK, "alice"},
+		{"RequireAdminOrService: the service", http.MethodDelete, "/votes/1", signedBy(ServicePollAPI, "poll-secret"), http.StatusOK, ServicePollAPI},
+		{"RequireAdminOrService: another service", http.MethodDelete, "/votes/1", signedBy(ServiceVotesAPI, "votes-secret"), http.StatusForbidden, ""},
+		{"RequireAdminOrService: a poll manager", http.MethodDelete, "/votes/1", bearer("manager-key"), http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			tt.as(req)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v: %v", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantCaller != "" && w.Body.String() != tt.wantCaller {
+				t.Errorf("called by %v, want %v", w.Body, tt.wantCaller)
+			}
+		})
+	}
+}
+
+// Without keys or a JWT secret nothing gets through, while a disabled
+// Authenticator lets everything through without a Principal
+func TestRequireUnconfiguredAndDisabled(t *testing.T) {
+
+	req := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/polls", nil)
+		r.Header.Set("Authorization", "Bearer admin-key")
+		return r
+	}
+
+	w := httptest.NewRecorder()
+	newTestRouter(New(nil, nil, nil, NewMemoryNonces())).ServeHTTP(w, req())
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unconfigured: status = %v, want 401", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	newTestRouter(Disabled()).ServeHTTP(w, req())
+	if w.Code != http.StatusOK || w.Body.String() != "nobody" {
+		t.Errorf("disabled: %v %v, want 200 without a Principal", w.Code, w.Body)
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+
+	keys, err := ParseAPIKeys(" admin-key:admin , manager-key:poll-manager:bob,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys["admin-key"] != (Principal{Subject: RoleAdmin, Role: RoleAdmin}) || keys["manager-key"] != (Principal{Subject: "bob", Role: RolePollManager}) || len(keys) != 2 {
+		t.Errorf("ParseAPIKeys() = %v", keys)
+	}
+
+	for _, s := range []string{"admin-key", ":admin", "key:root"} {
+		if _, err := ParseAPIKeys(s); err == nil {
+			t.Errorf("ParseAPIKeys(%q) accepted it", s)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisNonceKeyPrefix is the prefix of the redis keys the nonces of the
+// signed requests are remembered under
+const RedisNonceKeyPrefix = "nonce:"
+
+// Nonces remembers the nonces of the signed requests an API accepted, so that
+// a captured request can't be replayed while its timestamp is still within
+// MaxClockSkew
+type Nonces interface {
+	// Claim remembers nonce for ttl, and reports whether it wasn't
+	// remembered already
+	Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// redisNonces keeps each nonce as a redis key that expires after its ttl, so
+// that every instance of an API knows the nonces the others accepted
+type redisNonces struct {
+	client *redis.Client
+}
+
+// NewRedisNonces returns Nonces kept in redis
+func NewRedisNonces(client *redis.Client) Nonces {
+	return &redisNonces{client: client}
+}
+
+func (n *redisNonces) Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return n.client.SetNX(ctx, RedisNonceKeyPrefix+nonce, 1, ttl).Result()
+}
+
+// memoryNonces keeps the nonces in a map, along with when they expire
+type memoryNonces struct {
+	mu        sync.Mutex
+	expires   map[string]time.Time
+	nextPrune time.Time
+}
+
+// NewMemoryNonces returns Nonces kept in memory, for an API that keeps what
+// it has in memory
+func NewMemoryNonces() Nonces {
+	return &memoryNonces{expires: make(map[string]time.Time)}
+}
+
+func (n *memoryNonces) Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := time.Now()
+
+	// the expired nonces are dropped once a minute
+	if now.After(n.nextPrune) {
+		for nonce, expires := range n.expires {
+			if now.After(expires) {
+				delete(n.expires, nonce)
+			}
+		}
+		n.nextPrune = now.Add(time.Minute)
+	}
+
+	if expires, ok := n.expires[nonce]; ok && !now.After(expires) {
+		return false, nil
+	}
+	n.expires[nonce] = now.Add(ttl)
+	return true, nil
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/metrics"
+	"drexel.edu/common/resilience"
+	"drexel.edu/common/tracing"
+	"github.com/go-resty/resty/v2"
+)
+
+// When one API calls another it signs the request with its own secret from
+// AUTH_SERVICE_SECRETS, and the API that is called checks the signature with
+// the same secret. The signature is the hex HMAC-SHA256 of
+//
+//	<method>\n<request URI>\n<timestamp>\n<nonce>\n<hex SHA256 of the body>
+//
+// so neither the route nor the body can be changed, and it is only accepted
+// for MaxClockSkew around its timestamp, and only once: the nonce is random
+// and the API that is called remembers it (see Nonces). Every API needs its
+// own secret and the secrets of the APIs that call it, and no others, so
+// that it can't sign requests as the APIs it calls.
+
+// The names of the APIs, as used in AUTH_SERVICE_SECRETS
+const (
+	ServicePollAPI  = "poll-api"
+	ServiceVoterAPI = "voter-api"
+	ServiceVotesAPI = "votes-api"
+)
+
+// The headers of a signed request
+const (
+	HeaderServiceName      = "X-Service-Name"
+	HeaderServiceTimestamp = "X-Service-Timestamp"
+	HeaderServiceNonce     = "X-Service-Nonce"
+	HeaderServiceSignature = "X-Service-Signature"
+)
+
+// MaxClockSkew is how far the timestamp of a signed request can be from the
+// time it is received
+const MaxClockSkew = 5 * time.Minute
+
+// MaxSignedBodySize is the largest body of a signed request, in bytes. It is
+// read before the signature is checked, so it has to be limited.
+const MaxSignedBodySize = 1 << 20
+
+// maxNonceLength is the length of the longest nonce accepted, SignRequest
+// sends 32 hex digits
+const maxNonceLength = 64
+
+func isService(service string) bool {
+	return service == ServicePollAPI || service == ServiceVoterAPI || service == ServiceVotesAPI
+}
+
+// ParseServiceSecrets parses the comma separated <service>:<secret> list of
+// AUTH_SERVICE_SECRETS
+func ParseServiceSecrets(s string) (map[string][]byte, error) {
+
+	secrets := make(map[string][]byte)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		service, secret, ok := strings.Cut(entry, ":")
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("%v: %q is not <service>:<secret>", EnvServiceSecrets, entry)
+		}
+		if !isService(service) {
+			return nil, fmt.Errorf("%v: unknown service %q, it must be %v, %v or %v", EnvServiceSecrets, service, ServicePollAPI, ServiceVoterAPI, ServiceVotesAPI)
+		}
+		secrets[service] = []byte(secret)
+	}
+
+	return secrets, nil
+}
+
+// signature returns the hex HMAC-SHA256 of the request with secret
+func signature(secret []byte, method string, requestURI string, timestamp string, nonce string, body []byte) string {
+
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%v\n%v\n%v\n%v\n%v", method, requestURI, timestamp, nonce, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readBody reads the body of the request and puts it back, so that it can
+// still be sent or bound. A body of more than limit bytes is an
+// *http.MaxBytesError, unless limit is negative.
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	reader := r.Body
+	if limit >= 0 {
+		reader = http.MaxBytesReader(nil, r.Body, limit)
+	}
+	body, err := io.ReadAll(reader)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// newNonce returns a random nonce for a signed request
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SignRequest adds the headers of a request signed by service with secret
+func SignRequest(r *http.Request, service string, secret []byte) error {
+
+	body, err := readBody(r, -1)
+	if err != nil {
+		return err
+	}
+	nonce, err := newNonce()
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Set(HeaderServiceName, service)
+	r.Header.Set(HeaderServiceTimestamp, timestamp)
+	r.Header.Set(HeaderServiceNonce, nonce)
+	r.Header.Set(HeaderServiceSignature, signature(secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body))
+	return nil
+}
+
+// verifyService returns the Principal of a request signed by one of the APIs
+func (a *Authenticator) verifyService(r *http.Request) (Principal, error) {
+
+	service := r.Header.Get(HeaderServiceName)
+	secret, ok := a.services[service]
+	if !ok {
+		return Principal{}, apierror.Unauthorized(HeaderServiceName, "%q is not a known service.", service)
+	}
+
+	timestamp := r.Header.Get(HeaderServiceTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return Principal{}, apierror.Unauthorized(HeaderServiceTimestamp, "%q is not a unix timestamp.", timestamp)
+	}
+	signedAt := time.Unix(seconds, 0)
+	if skew := time.Since(signedAt); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return Principal{}, apierror.Unauthorized(HeaderServiceTimestamp, "The signed request is %v old, it must be at most %v.", skew.Round(time.Second), MaxClockSkew)
+	}
+
+	nonce := r.Header.Get(HeaderServiceNonce)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return Principal{}, apierror.Unauthorized(HeaderServiceNonce, "A signed request needs a nonce of at most %v characters.", maxNonceLength)
+	}
+
+	body, err := readBody(r, MaxSignedBodySize)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return Principal{}, apierror.Validation("", "The body of a signed request can be at most %v bytes.", MaxSignedBodySize)
+		}
+		return Principal{}, err
+	}
+
+	requestURI := r.RequestURI
+	if requestURI == "" {
+		requestURI = r.URL.RequestURI()
+	}
+
+	expected, _ := hex.DecodeString(signature(secret, r.Method, requestURI, timestamp, nonce, body))
+	actual, err := hex.DecodeString(r.Header.Get(HeaderServiceSignature))
+	if err != nil || !hmac.Equal(expected, actual) {
+		return Principal{}, apierror.Unauthorized(HeaderServiceSignature, "The request is not signed by %v.", service)
+	}
+
+	// the nonce is only remembered once the signature is checked, and until
+	// the timestamp is too old for the request to be accepted anyway
+	fresh, err := a.nonces.Claim(r.Context(), service+":"+nonce, time.Until(signedAt.Add(MaxClockSkew))+time.Second)
+	if err != nil {
+		return Principal{}, err
+	}
+	if !fresh {
+		return Principal{}, apierror.Unauthorized(HeaderServiceNonce, "The signed request was already received, it can't be replayed.")
+	}
+
+	return Principal{Subject: service, Role: RoleService}, nil
+}
+
+// signingTransport signs every request it sends as service
+type signingTransport struct {
+	service string
+	secret  []byte
+	next    http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	// a RoundTripper must not change the request it is given
+	signed := r.Clone(r.Context())
+	if err := SignRequest(signed, t.service, t.secret); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(signed)
+}
+
+// ServiceClient returns a resty.Client for the calls of service to the other
+// APIs, which signs every request with the secret of service in
+// AUTH_SERVICE_SECRETS. Without a secret the requests are sent unsigned, and
+// the other APIs reject the ones to protected routes. Every call is timed in
+// the metrics, traced and goes through the circuit breaker of the API it is
+// sent to (see the resilience package), so it has to be made with the
+// context of the request it is made for (resty's SetContext), whose deadline
+// it gets.
+func ServiceClient(service string) *resty.Client {
+
+	client := resilience.Configure(resty.New(), resilience.DefaultOptions)
+	client.SetTransport(tracing.Transport(metrics.Transport(resilience.Transport(client.GetClient().Transport, resilience.DefaultOptions))))
+
+	secrets, err := ParseServiceSecrets(os.Getenv(EnvServiceSecrets))
+	if err != nil {
+		log.Println("WARNING: the calls to the other APIs are not signed: ", err)
+		return client
+	}
+	secret, ok := secrets[service]
+	if !ok {
+		log.Println("WARNING: " + EnvServiceSecrets + " has no secret for " + service + ", the calls to the other APIs are not signed")
+		return client
+	}
+
+	return client.SetTransport(&signingTransport{
+		service: service,
+		secret:  secret,
+		next:    client.GetClient().Transport,
+	})
+}
@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newVoterAPIServer stands in for the Voter API, whose VoteHistory writes
+// are only accepted from the Votes API. It answers with the body it got.
+func newVoterAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	a := New(nil, nil, map[string][]byte{
+		ServiceVotesAPI: []byte("votes-secret"),
+		ServicePollAPI:  []byte("poll-secret"),
+	}, NewMemoryNonces())
+
+	r.PUT("/voters/:id/polls/:pollid", a.RequireService(ServiceVotesAPI), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", body)
+	})
+
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// tamperTransport changes a request after it was signed, on its way to the
+// server
+type tamperTransport func(r *http.Request)
+
+func (t tamperTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t(r)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func put(t *testing.T, transport http.RoundTripper, url string, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(respBody)
+}
+
+// The client of the Votes API signs its calls with its secret in
+// AUTH_SERVICE_SECRETS, and the body arrives unchanged
+func TestServiceClientSignsItsRequests(t *testing.T) {
+
+	server := newVoterAPIServer(t)
+	t.Setenv(EnvServiceSecrets, "votes-api:votes-secret")
+
+	resp, err := ServiceClient(ServiceVotesAPI).R().
+		SetBody(`{"PollID":"/polls/1"}`).
+		Put(server.URL + "/voters/1/polls/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != http.StatusOK || resp.String() != `{"PollID":"/polls/1"}` {
+		t.Errorf("PUT = %v %v, want 200 and the body", resp.StatusCode(), resp)
+	}
+}
+
+// Without its own secret a service sends its calls unsigned, and they are
+// rejected
+func TestServiceClientWithoutASecret(t *testing.T) {
+
+	server := newVoterAPIServer(t)
+	t.Setenv(EnvServiceSecrets, "poll-api:poll-secret")
+
+	resp, err := ServiceClient(ServiceVotesAPI).R().Put(server.URL + "/voters/1/polls/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != http.StatusUnauthorized {
+		t.Errorf("PUT = %v, want 401: %v", resp.StatusCode(), resp)
+	}
+}
+
+// A signed request that is changed on the way is rejected
+func TestSignedRequestChangedInTransit(t *testing.T) {
+
+	server := newVoterAPIServer(t)
+
+	tests := []struct {
+		name       string
+		service    string
+		secret     string
+		body       string
+		tamper     func(r *http.Request)
+		wantStatus int
+	}{
+		{"unchanged", ServiceVotesAPI, "votes-secret", `{"PollID":"/polls/1"}`, func(*http.Request) {}, http.StatusOK},
+		{"another body", ServiceVotesAPI, "votes-secret", `{"PollID":"/polls/1"}`, func(r *http.Request) {
+			r.Body = io.NopCloser(strings.NewReader(`{"PollID":"/polls/2"}`))
+		}, http.StatusUnauthorized},
+		{"another Voter", ServiceVotesAPI, "votes-secret", "", func(r *http.Request) {
+			r.URL.Path = "/voters/2/polls/1"
+		}, http.StatusUnauthorized},
+		{"signed too long ago", ServiceVotesAPI, "votes-secret", "", func(r *http.Request) {
+			r.Header.Set(HeaderServiceTimestamp, strconv.FormatInt(time.Now().Add(-2*MaxClockSkew).Unix(), 10))
+		}, http.StatusUnauthorized},
+		{"without its nonce", ServiceVotesAPI, "votes-secret", "", func(r *http.Request) {
+			r.Header.Del(HeaderServiceNonce)
+		}, http.StatusUnauthorized},
+		{"claiming to be another service", ServicePollAPI, "votes-secret", "", func(*http.Request) {}, http.StatusUnauthorized},
+		{"signed by a service that isn't allowed", ServicePollAPI, "poll-secret", "", func(*http.Request) {}, http.StatusForbidden},
+		{"a body over the limit", ServiceVotesAPI, "votes-secret", strings.Repeat("x", MaxSignedBodySize+1), func(*http.Request) {}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &signingTransport{service: tt.service, secret: []byte(tt.secret), next: tamperTransport(tt.tamper)}
+			if status, body := put(t, transport, server.URL+"/voters/1/polls/1", tt.body); status != tt.wantStatus {
+				t.Errorf("PUT = %v, want %v: %v", status, tt.wantStatus, body)
+			}
+		})
+	}
+}
+
+// A signed request sent again, e.g. by someone who saw it on the network, is
+// rejected
+func TestSignedRequestReplayed(t *testing.T) {
+
+	server := newVoterAPIServer(t)
+
+	var sent *http.Request
+	var sentBody []byte
+	record := tamperTransport(func(r *http.Request) {
+		sent = r.Clone(r.Context())
+		sentBody, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(sentBody))
+	})
+	transport := &signingTransport{service: ServiceVotesAPI, secret: []byte("votes-secret"), next: record}
+
+	if status, body := put(t, transport, server.URL+"/voters/1/polls/1", `{"PollID":"/polls/1"}`); status != http.StatusOK {
+		t.Fatalf("PUT = %v, want 200: %v", status, body)
+	}
+
+	replay, err := http.NewRequest(sent.Method, sent.URL.String(), bytes.NewReader(sentBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay.Header = sent.Header.Clone()
+	resp, err := http.DefaultClient.Do(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("replayed PUT = %v, want 401", resp.StatusCode)
+	}
+}
+
+func TestParseServiceSecrets(t *testing.T) {
+
+	secrets, err := ParseServiceSecrets("votes-api:a:b, poll-api:c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secrets[ServiceVotesAPI]) != "a:b" || string(secrets[ServicePollAPI]) != "c" || len(secrets) != 2 {
+		t.Errorf("ParseServiceSecrets() = %q", secrets)
+	}
+
+	for _, s := range []string{"votes-api", "votes-api:", "results-api:secret"} {
+		if _, err := ParseServiceSecrets(s); err == nil {
+			t.Errorf("ParseServiceSecrets(%q) accepted it", s)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package changes tells the Votes API that a Poll or a Voter changed, so that
+// it drops its cached copy. The Poll API and the Voter API publish the ID of
+// every Poll and Voter they add, update, delete or import on redis Pub/Sub,
+// on the channel changes:<entity> (e.g. changes:poll), and the Votes API
+// subscribes to them.
+package changes
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisChannelPrefix is the prefix of the redis Pub/Sub channel the Changes
+// to an entity (audit.EntityPoll or audit.EntityVoter) are published on
+const RedisChannelPrefix = "changes:"
+
+// Change is published whenever the Poll or Voter ID changes, Entity is
+// audit.EntityPoll or audit.EntityVoter
+type Change struct {
+	Entity string
+	ID     string
+}
+
+// Publisher is where an API publishes the Changes to what it keeps
+type Publisher interface {
+	Publish(ctx context.Context, entity string, id string) error
+}
+
+// redisPublisher publishes the ID of what changed on the channel of its
+// entity
+type redisPublisher struct {
+	client *redis.Client
+}
+
+// NewRedis returns a Publisher that publishes on redis Pub/Sub
+func NewRedis(client *redis.Client) Publisher {
+	return &redisPublisher{client: client}
+}
+
+func (p *redisPublisher) Publish(ctx context.Context, entity string, id string) error {
+	return p.client.Publish(ctx, RedisChannelPrefix+entity, id).Err()
+}
+
+// nonePublisher drops the Changes
+type nonePublisher struct{}
+
+// NewNone returns a Publisher that drops the Changes, for an API that keeps
+// what it has in memory, where the Votes API can't be told about it. The
+// Votes API's cached copies then expire on their own.
+func NewNone() Publisher {
+	return nonePublisher{}
+}
+
+func (nonePublisher) Publish(ctx context.Context, entity string, id string) error {
+	return nil
+}
+
+// Subscribe returns the Changes to the entities published from then on,
+// until ctx is done
+func Subscribe(ctx context.Context, client *redis.Client, entities ...string) (<-chan Change, error) {
+
+	channels := make([]string, len(entities))
+	for i, entity := range entities {
+		channels[i] = RedisChannelPrefix + entity
+	}
+
+	pubsub := client.Subscribe(ctx, channels...)
+
+	// wait for the subscription to be confirmed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	changes := make(chan Change, 64)
+	go func() {
+		defer close(changes)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				change := Change{Entity: msg.Channel[len(RedisChannelPrefix):], ID: msg.Payload}
+				select {
+				case changes <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
@@ -0,0 +1,496 @@
+// Package config loads the configuration of the Poll, Voter and Votes APIs.
+// Every setting has a default, which is overridden, in order of precedence
+// from lowest to highest, by
+//
+//  1. the config file given with -config (or CONFIG_FILE), YAML or TOML
+//     depending on its extension
+//  2. the environment variable of the setting, e.g. REDIS_URL
+//  3. the same environment variable prefixed with the API's own prefix,
+//     e.g. POLLAPI_REDIS_URL, VOTERAPI_REDIS_URL or VOTESAPI_REDIS_URL
+//  4. the command line flag of the setting, e.g. -c
+//
+// The configuration is validated before the API starts, and -print-config
+// prints it (without the redis password) and exits. The secrets of the auth
+// package and the tracing endpoint are only read from the environment.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/openapi"
+	"drexel.edu/common/requestid"
+	"github.com/gin-contrib/cors"
+	"github.com/go-redis/redis/v8"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of an API. The keys of the config file are
+// the yaml/toml names of the fields, e.g.
+//
+//	redis:
+//	  addr: cache:6379
+//	apis:
+//	  votes: http://votes-api:3080
+//	timeouts:
+//	  call: 5s
+type Config struct {
+	Host        string   `yaml:"host" toml:"host"`
+	Port        uint     `yaml:"port" toml:"port"`
+	Store       string   `yaml:"store" toml:"store"`
+	RestoreFile string   `yaml:"restore_file" toml:"restore_file"`
+	Redis       Redis    `yaml:"redis" toml:"redis"`
+	APIs        APIs     `yaml:"apis" toml:"apis"`
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"`
+	// OpenAPIValidation is how the requests and responses are validated
+	// against the OpenAPI document of the API: off, report or strict (see
+	// the openapi package)
+	OpenAPIValidation string   `yaml:"openapi_validation" toml:"openapi_validation"`
+	Timeouts          Timeouts `yaml:"timeouts" toml:"timeouts"`
+}
+
+// Redis is where the API keeps what it stores, unless Store is memory
+type Redis struct {
+	Addr     string `yaml:"addr" toml:"addr"`
+	Password string `yaml:"password" toml:"password"`
+	DB       int    `yaml:"db" toml:"db"`
+}
+
+// APIs are the base URLs of the other APIs, each API only uses some of them
+type APIs struct {
+	Voter string `yaml:"voter" toml:"voter"`
+	Poll  string `yaml:"poll" toml:"poll"`
+	Votes string `yaml:"votes" toml:"votes"`
+}
+
+// Timeouts are how long the API waits on the things it depends on
+type Timeouts struct {
+	// Call is how long a call to another API can take
+	Call Duration `yaml:"call" toml:"call"`
+	// Health is how long a dependency has to respond to its GET /readyz check
+	Health Duration `yaml:"health" toml:"health"`
+	// Redis is how long connecting to redis, and reading or writing to it,
+	// can take
+	Redis Duration `yaml:"redis" toml:"redis"`
+	// ShutdownDelay is how long the API keeps serving, with GET /readyz
+	// failing, once it is told to stop, and Shutdown is how long the requests
+	// in flight then have to finish (see the server package)
+	ShutdownDelay Duration `yaml:"shutdown_delay" toml:"shutdown_delay"`
+	Shutdown      Duration `yaml:"shutdown" toml:"shutdown"`
+}
+
+// Duration is a time.Duration written like 5s or 1m30s in the config file
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// service is what differs between the APIs
+type service struct {
+	envPrefix string
+	port      uint
+	// apis are the other APIs it calls
+	apis []string
+}
+
+var services = map[string]service{
+	auth.ServicePollAPI:  {envPrefix: "POLLAPI_", port: 2080, apis: []string{auth.ServiceVotesAPI}},
+	auth.ServiceVoterAPI: {envPrefix: "VOTERAPI_", port: 1080, apis: []string{auth.ServiceVotesAPI}},
+	auth.ServiceVotesAPI: {envPrefix: "VOTESAPI_", port: 3080, apis: []string{auth.ServiceVoterAPI, auth.ServicePollAPI}},
+}
+
+// Defaults returns the Config of the API service before the config file, the
+// environment and the flags are applied
+func Defaults(service string) Config {
+	return Config{
+		Host:  "0.0.0.0",
+		Port:  services[service].port,
+		Store: docstore.BackendRedis,
+		Redis: Redis{Addr: "0.0.0.0:6379"},
+		APIs: APIs{
+			Voter: "http://localhost:1080",
+			Poll:  "http://localhost:2080",
+			Votes: "http://localhost:3080",
+		},
+		CORSOrigins:       []string{"*"},
+		OpenAPIValidation: openapi.ValidationStrict,
+		Timeouts: Timeouts{
+			Call:   Duration(5 * time.Second),
+			Health: Duration(2 * time.Second),
+			Redis:  Duration(3 * time.Second),
+			// together under the 10s docker compose waits before it kills
+			ShutdownDelay: Duration(2 * time.Second),
+			Shutdown:      Duration(7 * time.Second),
+		},
+	}
+}
+
+//------------------------------------------------------------
+// SETTINGS
+//------------------------------------------------------------
+
+// setting is one setting that can be set from the environment and the
+// command line, env is the name of its environment variable without the
+// prefix of the API
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, value string) error
+	// api is the API whose URL this is, the setting only exists for the
+	// APIs that call it
+	api string
+}
+
+var settings = []setting{
+	{env: "HOST", flag: "h", usage: "Interface to listen on, 0.0.0.0 is all of them",
+		set: func(c *Config, v string) error { c.Host = v; return nil }},
+	{env: "PORT", flag: "p", usage: "Port to listen on",
+		set: func(c *Config, v string) error { return parseUint(&c.Port, v) }},
+	{env: "STORE", flag: "store", usage: "Where the data is kept, redis or memory",
+		set: func(c *Config, v string) error { c.Store = v; return nil }},
+	{env: "RESTORE_FILE", flag: "restore", usage: "Snapshot file (from export) to import at startup",
+		set: func(c *Config, v string) error { c.RestoreFile = v; return nil }},
+	{env: "REDIS_URL", flag: "c", usage: "Redis address, host:port",
+		set: func(c *Config, v string) error { c.Redis.Addr = v; return nil }},
+	{env: "REDIS_PASSWORD", flag: "redis-password", usage: "Redis password",
+		set: func(c *Config, v string) error { c.Redis.Password = v; return nil }},
+	{env: "REDIS_DB", flag: "redis-db", usage: "Redis database number",
+		set: func(c *Config, v string) error { return parseInt(&c.Redis.DB, v) }},
+	{env: "VOTER_API_URL", flag: "voterapi", usage: "Base URL of the Voter API", api: auth.ServiceVoterAPI,
+		set: func(c *Config, v string) error { c.APIs.Voter = v; return nil }},
+	{env: "POLL_API_URL", flag: "pollapi", usage: "Base URL of the Poll API", api: auth.ServicePollAPI,
+		set: func(c *Config, v string) error { c.APIs.Poll = v; return nil }},
+	{env: "VOTES_API_URL", flag: "votesapi", usage: "Base URL of the Votes API", api: auth.ServiceVotesAPI,
+		set: func(c *Config, v string) error { c.APIs.Votes = v; return nil }},
+	{env: "CORS_ORIGINS", flag: "cors-origins", usage: "Comma separated origins allowed to call the API from a browser, * for any",
+		set: func(c *Config, v string) error { c.CORSOrigins = splitList(v); return nil }},
+	{env: "OPENAPI_VALIDATION", flag: "openapi-validation", usage: "How requests and responses are validated against the OpenAPI document, off, report or strict",
+		set: func(c *Config, v string) error { c.OpenAPIValidation = v; return nil }},
+	{env: "CALL_TIMEOUT", flag: "call-timeout", usage: "How long a call to another API can take",
+		set: func(c *Config, v string) error { return c.Timeouts.Call.UnmarshalText([]byte(v)) }},
+	{env: "HEALTH_TIMEOUT", flag: "health-timeout", usage: "How long a dependency has to respond to its readiness check",
+		set: func(c *Config, v string) error { return c.Timeouts.Health.UnmarshalText([]byte(v)) }},
+	{env: "REDIS_TIMEOUT", flag: "redis-timeout", usage: "How long connecting, reading or writing to redis can take",
+		set: func(c *Config, v string) error { return c.Timeouts.Redis.UnmarshalText([]byte(v)) }},
+	{env: "SHUTDOWN_DELAY", flag: "shutdown-delay", usage: "How long to keep serving, unready, once told to stop",
+		set: func(c *Config, v string) error { return c.Timeouts.ShutdownDelay.UnmarshalText([]byte(v)) }},
+	{env: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout", usage: "How long the requests in flight have to finish when stopping",
+		set: func(c *Config, v string) error { return c.Timeouts.Shutdown.UnmarshalText([]byte(v)) }},
+}
+
+// legacyEnv are the names the Voter API and Votes API used to read the redis
+// address from, they are still read (before REDIS_URL)
+var legacyEnv = map[string]string{"REDIS_URL": "CACHE_URL"}
+
+func parseUint(dst *uint, value string) error {
+	n, err := strconv.ParseUint(value, 10, 0)
+	*dst = uint(n)
+	return err
+}
+
+func parseInt(dst *int, value string) error {
+	n, err := strconv.Atoi(value)
+	*dst = n
+	return err
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// settingsOf returns the settings of the API service
+func settingsOf(service string) []setting {
+	var own []setting
+	for _, s := range settings {
+		if s.api == "" || contains(services[service].apis, s.api) {
+			own = append(own, s)
+		}
+	}
+	return own
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+//------------------------------------------------------------
+// LOADING
+//------------------------------------------------------------
+
+// flagValue keeps the value given on the command line, which is only
+// applied once the config file and the environment have been
+type flagValue struct {
+	value string
+}
+
+func (f *flagValue) String() string     { return f.value }
+func (f *flagValue) Set(v string) error { f.value = v; return nil }
+
+// Load returns the Config of the API service from the config file, the
+// environment and the flags in os.Args, which are parsed with
+// flag.CommandLine so that flag.Args are the arguments after the flags (e.g.
+// a subcommand). With -print-config it prints the Config and exits.
+func Load(service string) (Config, error) {
+
+	if _, ok := services[service]; !ok {
+		return Config{}, fmt.Errorf("unknown service %q", service)
+	}
+	own := settingsOf(service)
+	prefix := services[service].envPrefix
+
+	configFile := flag.String("config", "", "Config file, YAML (.yaml, .yml) or TOML (.toml)")
+	printConfig := flag.Bool("print-config", false, "Print the configuration and exit")
+	values := make(map[string]*flagValue, len(own))
+	for _, s := range own {
+		values[s.flag] = &flagValue{}
+		flag.Var(values[s.flag], s.flag, fmt.Sprintf("%v (env %v, %v%v)", s.usage, s.env, prefix, s.env))
+	}
+	flag.Parse()
+
+	cfg := Defaults(service)
+
+	// 1. the config file
+	path := *configFile
+	if path == "" {
+		path = lookupEnv(prefix, "CONFIG_FILE")
+	}
+	if path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	// 2. and 3. the environment, the API's own variables last
+	var errs []error
+	for _, s := range own {
+		names := []string{s.env}
+		if legacy, ok := legacyEnv[s.env]; ok {
+			names = []string{legacy, s.env, prefix + legacy}
+		}
+		names = append(names, prefix+s.env)
+		for _, name := range names {
+			if value, ok := os.LookupEnv(name); ok && value != "" {
+				if err := s.set(&cfg, value); err != nil {
+					errs = append(errs, fmt.Errorf("%v=%q: %w", name, value, err))
+				}
+			}
+		}
+	}
+
+	// 4. the flags that were given
+	flag.Visit(func(f *flag.Flag) {
+		for _, s := range own {
+			if s.flag == f.Name {
+				if err := s.set(&cfg, values[s.flag].value); err != nil {
+					errs = append(errs, fmt.Errorf("-%v %q: %w", f.Name, values[s.flag].value, err))
+				}
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+
+	if err := cfg.Validate(service); err != nil {
+		return Config{}, err
+	}
+
+	if *printConfig {
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(cfg.Redacted()); err != nil {
+			return Config{}, err
+		}
+		os.Exit(0)
+	}
+
+	return cfg, nil
+}
+
+// lookupEnv returns the prefixed variable name if it is set, name otherwise
+func lookupEnv(prefix string, name string) string {
+	if value := os.Getenv(prefix + name); value != "" {
+		return value
+	}
+	return os.Getenv(name)
+}
+
+// readFile reads the YAML or TOML config file into cfg, a key that is not a
+// setting is an error
+func readFile(path string, cfg *Config) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(f)
+		decoder.KnownFields(true)
+		err = decoder.Decode(cfg)
+	case ".toml":
+		decoder := toml.NewDecoder(f)
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(cfg)
+	default:
+		return fmt.Errorf("config file %v: must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %v: %w", path, err)
+	}
+	return nil
+}
+
+//------------------------------------------------------------
+// VALIDATION
+//------------------------------------------------------------
+
+// Validate checks every setting the API service uses, and returns all the
+// problems at once
+func (c Config) Validate(service string) error {
+
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Port == 0 || c.Port > 65535 {
+		add("port %v must be between 1 and 65535", c.Port)
+	}
+	if _, err := docstore.ParseBackend(c.Store); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Store != docstore.BackendMemory {
+		if _, _, err := net.SplitHostPort(c.Redis.Addr); err != nil {
+			add("redis addr %q must be host:port", c.Redis.Addr)
+		}
+		if c.Redis.DB < 0 {
+			add("redis db %v must not be negative", c.Redis.DB)
+		}
+	}
+
+	urls := map[string]string{auth.ServiceVoterAPI: c.APIs.Voter, auth.ServicePollAPI: c.APIs.Poll, auth.ServiceVotesAPI: c.APIs.Votes}
+	for _, api := range services[service].apis {
+		u, err := url.Parse(urls[api])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("the URL of the %v %q must be http(s)://host[:port]", api, urls[api])
+		}
+	}
+
+	if len(c.CORSOrigins) == 0 {
+		add("cors_origins must have at least one origin, * for any")
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			add("cors origin %q must be scheme://host[:port] or *", origin)
+		}
+	}
+
+	switch c.OpenAPIValidation {
+	case openapi.ValidationOff, openapi.ValidationReport, openapi.ValidationStrict:
+	default:
+		add("openapi_validation %q must be %v, %v or %v", c.OpenAPIValidation, openapi.ValidationOff, openapi.ValidationReport, openapi.ValidationStrict)
+	}
+
+	if c.Timeouts.Call <= 0 {
+		add("the call timeout must be positive")
+	}
+	if c.Timeouts.Health <= 0 {
+		add("the health timeout must be positive")
+	}
+	if c.Timeouts.Redis <= 0 {
+		add("the redis timeout must be positive")
+	}
+	if c.Timeouts.ShutdownDelay < 0 {
+		add("the shutdown delay must not be negative")
+	}
+	if c.Timeouts.Shutdown <= 0 {
+		add("the shutdown timeout must be positive")
+	}
+
+	return errors.Join(errs...)
+}
+
+//------------------------------------------------------------
+// USE
+//------------------------------------------------------------
+
+// Redacted returns the Config with the redis password hidden, for printing
+func (c Config) Redacted() Config {
+	if c.Redis.Password != "" {
+		c.Redis.Password = "********"
+	}
+	return c
+}
+
+// Addr is the address the API listens on
+func (c Config) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.FormatUint(uint64(c.Port), 10))
+}
+
+// RedisOptions are the options of the redis client
+func (c Config) RedisOptions() *redis.Options {
+	timeout := time.Duration(c.Timeouts.Redis)
+	return &redis.Options{
+		Addr:         c.Redis.Addr,
+		Password:     c.Redis.Password,
+		DB:           c.Redis.DB,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+	}
+}
+
+// CORS is the configuration of the cors middleware, allowing the CORSOrigins.
+// Besides the default headers a browser may send the Authorization header of
+// the protected routes and an X-Request-ID, and read the X-Request-ID of the
+// response.
+func (c Config) CORS() cors.Config {
+	config := cors.DefaultConfig()
+	config.AddAllowHeaders("Authorization", requestid.Header)
+	config.AddExposeHeaders(requestid.Header)
+	if contains(c.CORSOrigins, "*") {
+		config.AllowAllOrigins = true
+	} else {
+		config.AllowOrigins = c.CORSOrigins
+	}
+	return config
+}
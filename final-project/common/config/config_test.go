@@ -0,0 +1,231 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/openapi"
+)
+
+// load runs Load for the API service as if it was started with args in an
+// environment where only the variables in env are set
+func load(t *testing.T, service string, env map[string]string, args ...string) (Config, error) {
+	t.Helper()
+
+	names := []string{"CONFIG_FILE", "CACHE_URL"}
+	for _, s := range settings {
+		names = append(names, s.env)
+	}
+	for _, name := range names {
+		t.Setenv(name, "")
+		for _, other := range services {
+			t.Setenv(other.envPrefix+name, "")
+		}
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+
+	// Load parses os.Args with flag.CommandLine
+	osArgs, commandLine := os.Args, flag.CommandLine
+	t.Cleanup(func() { os.Args, flag.CommandLine = osArgs, commandLine })
+	os.Args = append([]string{service}, args...)
+	flag.CommandLine = flag.NewFlagSet(service, flag.ContinueOnError)
+
+	return Load(service)
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// The redis address is given at every level, and each level is taken away
+// in turn, from the highest
+func TestLoadPrecedence(t *testing.T) {
+
+	file := writeFile(t, "poll-api.yaml", "redis:\n  addr: file:6379\n")
+	env := map[string]string{"REDIS_URL": "env:6379", "POLLAPI_REDIS_URL": "prefixed:6379"}
+	flags := []string{"-config", file, "-c", "flag:6379"}
+
+	steps := []struct {
+		remove string
+		want   string
+	}{
+		{"", "flag:6379"},
+		{"the flag", "prefixed:6379"},
+		{"the prefixed variable", "env:6379"},
+		{"the variable", "file:6379"},
+		{"the config file", "0.0.0.0:6379"},
+	}
+
+	for _, step := range steps {
+		switch step.remove {
+		case "the flag":
+			flags = flags[:2]
+		case "the prefixed variable":
+			delete(env, "POLLAPI_REDIS_URL")
+		case "the variable":
+			delete(env, "REDIS_URL")
+		case "the config file":
+			flags = nil
+		}
+
+		cfg, err := load(t, auth.ServicePollAPI, env, flags...)
+		if err != nil {
+			t.Fatalf("without %v: %v", step.remove, err)
+		}
+		if cfg.Redis.Addr != step.want {
+			t.Errorf("without %v: Redis.Addr = %v, want %v", step.remove, cfg.Redis.Addr, step.want)
+		}
+	}
+}
+
+// Each API reads the variables with its own prefix, and only the URLs of the
+// APIs it calls
+func TestLoadOwnSettings(t *testing.T) {
+
+	env := map[string]string{
+		"POLLAPI_PORT":           "9001",
+		"VOTESAPI_PORT":          "9003",
+		"POLL_API_URL":           "http://polls.test",
+		"VOTESAPI_VOTER_API_URL": "http://voters.test",
+	}
+
+	votes, err := load(t, auth.ServiceVotesAPI, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if votes.Port != 9003 || votes.APIs.Poll != "http://polls.test" || votes.APIs.Voter != "http://voters.test" {
+		t.Errorf("votes-api: Port %v, APIs %+v", votes.Port, votes.APIs)
+	}
+
+	poll, err := load(t, auth.ServicePollAPI, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if poll.Port != 9001 || poll.APIs.Poll != Defaults(auth.ServicePollAPI).APIs.Poll {
+		t.Errorf("poll-api: Port %v, APIs %+v", poll.Port, poll.APIs)
+	}
+
+	voter, err := load(t, auth.ServiceVoterAPI, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if voter.Port != 1080 || voter.OpenAPIValidation != openapi.ValidationStrict {
+		t.Errorf("voter-api: Port %v, OpenAPIValidation %v, want the defaults", voter.Port, voter.OpenAPIValidation)
+	}
+}
+
+// The Voter API and Votes API read CACHE_URL before there was REDIS_URL
+func TestLoadLegacyCacheURL(t *testing.T) {
+
+	cfg, err := load(t, auth.ServiceVoterAPI, map[string]string{"CACHE_URL": "legacy:6379"})
+	if err != nil || cfg.Redis.Addr != "legacy:6379" {
+		t.Errorf("with CACHE_URL: %v, %v", cfg.Redis.Addr, err)
+	}
+
+	cfg, err = load(t, auth.ServiceVoterAPI, map[string]string{"CACHE_URL": "legacy:6379", "REDIS_URL": "env:6379"})
+	if err != nil || cfg.Redis.Addr != "env:6379" {
+		t.Errorf("with CACHE_URL and REDIS_URL: %v, %v", cfg.Redis.Addr, err)
+	}
+}
+
+// A YAML and a TOML config file with the same settings load the same Config
+func TestLoadConfigFileFormats(t *testing.T) {
+
+	yamlFile := writeFile(t, "votes-api.yml", `
+port: 9003
+store: memory
+apis:
+  poll: http://polls.test
+cors_origins: [http://localhost:8080]
+timeouts:
+  call: 1s
+  shutdown: 30s
+`)
+	tomlFile := writeFile(t, "votes-api.toml", `
+port = 9003
+store = "memory"
+cors_origins = ["http://localhost:8080"]
+
+[apis]
+poll = "http://polls.test"
+
+[timeouts]
+call = "1s"
+shutdown = "30s"
+`)
+
+	fromYAML, err := load(t, auth.ServiceVotesAPI, nil, "-config", yamlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromTOML, err := load(t, auth.ServiceVotesAPI, map[string]string{"VOTESAPI_CONFIG_FILE": tomlFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromYAML, fromTOML) {
+		t.Errorf("YAML %+v, TOML %+v", fromYAML, fromTOML)
+	}
+	if fromYAML.Store != docstore.BackendMemory || fromYAML.Timeouts.Call != Duration(time.Second) || fromYAML.Timeouts.Shutdown != Duration(30*time.Second) {
+		t.Errorf("Store %v, Timeouts %+v", fromYAML.Store, fromYAML.Timeouts)
+	}
+}
+
+// Every problem is reported at once, before the API starts
+func TestLoadErrors(t *testing.T) {
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		// want are parts of the error
+		want []string
+	}{
+		{"a misspelled key", map[string]string{"CONFIG_FILE": "prot: 9001\n"}, nil, []string{"prot"}},
+		{"a config file of another format", map[string]string{"CONFIG_FILE": "{}"}, nil, []string{".yaml, .yml or .toml"}},
+		{"a port that isn't a number", map[string]string{"PORT": "http"}, nil, []string{`PORT="http"`}},
+		{"a port out of range", nil, []string{"-p", "70000"}, []string{"port 70000"}},
+		{"an unknown store", nil, []string{"-store", "disk"}, []string{"disk"}},
+		{"a redis address without a port", nil, []string{"-c", "cache"}, []string{`redis addr "cache"`}},
+		{"everything at once", map[string]string{"VOTES_API_URL": "votes-api:3080", "CALL_TIMEOUT": "-1s"}, []string{"-cors-origins", "localhost"},
+			[]string{"the URL of the votes-api", `cors origin "localhost"`, "the call timeout"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			env := tt.env
+			if content, ok := env["CONFIG_FILE"]; ok {
+				name := "poll-api.yaml"
+				if content == "{}" {
+					name = "poll-api.json"
+				}
+				env = map[string]string{"CONFIG_FILE": writeFile(t, name, content)}
+			}
+
+			cfg, err := load(t, auth.ServicePollAPI, env, tt.args...)
+			if err == nil {
+				t.Fatalf("Load() = %+v, want an error", cfg)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't mention %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package docstore keeps JSON documents (Polls, Voters and Votes) by their
+// ID, either in redis with the ReJSON module or in memory. The in-memory
+// store lets the APIs run (and be tested) without a redis-stack container,
+// its documents are lost when the process exits.
+//
+// Each API wraps a store in a repository interface of its own (PollStore,
+// VoterStore, VoteStore), which both Redis and Memory satisfy.
+package docstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"drexel.edu/common/metrics"
+	"drexel.edu/common/tracing"
+	"github.com/go-redis/redis/v8"
+	"github.com/nitishm/go-rejson/v4"
+)
+
+// The backends a store can be selected from with the -store flag
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory"
+)
+
+// ErrNotFound is returned when there is no document with the ID
+var ErrNotFound = errors.New("document not found")
+
+// Connect connects to redis with options (see config.Config.RedisOptions)
+// and returns the client along with a ReJSON helper associated with it. It
+// fails if redis is unreachable. The commands run by the client are timed in
+// the metrics and traced.
+func Connect(options *redis.Options) (*redis.Client, *rejson.Handler, error) {
+
+	client := redis.NewClient(options)
+	client.AddHook(metrics.RedisHook{})
+	client.AddHook(tracing.RedisHook{})
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		log.Println("Error connecting to redis" + err.Error())
+		return nil, nil, err
+	}
+
+	jsonHelper := rejson.NewReJSONHandler()
+	jsonHelper.SetGoRedisClient(client)
+
+	return client, jsonHelper, nil
+}
+
+//------------------------------------------------------------
+// REDIS
+//------------------------------------------------------------
+
+// Redis keeps each document as JSON under the key prefix+ID. Every command
+// is run with the context it is given, which is that of the request it is
+// run for.
+type Redis[T any] struct {
+	client *redis.Client
+	helper *rejson.Handler
+	prefix string
+}
+
+func NewRedis[T any](client *redis.Client, helper *rejson.Handler, prefix string) *Redis[T] {
+	return &Redis[T]{client: client, helper: helper, prefix: prefix}
+}
+
+func isRedisNilError(err error) bool {
+	return errors.Is(err, redis.Nil) || err.Error() == "redis: nil"
+}
+
+// Get returns the document with the ID id, or ErrNotFound
+func (s *Redis[T]) Get(ctx context.Context, id string) (T, error) {
+	return s.getKey(ctx, s.prefix+id)
+}
+
+func (s *Redis[T]) getKey(ctx context.Context, key string) (T, error) {
+
+	var doc T
+
+	//The second parameter "." means return the entire json structure
+	itemObject, err := s.helper.SetContext(ctx).JSONGet(key, ".")
+	if err != nil {
+		if isRedisNilError(err) {
+			return doc, ErrNotFound
+		}
+		return doc, err
+	}
+
+	//JSONGet returns an "any" object which holds the json as a byte array
+	if err := json.Unmarshal(itemObject.([]byte), &doc); err != nil {
+		return doc, err
+	}
+
+	return doc, nil
+}
+
+// Set adds the document with the ID id, or overwrites it if it exists
+func (s *Redis[T]) Set(ctx context.Context, id string, doc T) error {
+	_, err := s.helper.SetContext(ctx).JSONSet(s.prefix+id, ".", doc)
+	return err
+}
+
+// Delete removes the document with the ID id, and reports whether it existed
+func (s *Redis[T]) Delete(ctx context.Context, id string) (bool, error) {
+	numDeleted, err := s.client.Del(ctx, s.prefix+id).Result()
+	if err != nil {
+		return false, err
+	}
+	return numDeleted > 0, nil
+}
+
+// List returns a page of documents starting at cursor, along with the cursor
+// of the next page (0 when there are no more documents). It pages with SCAN
+// rather than KEYS, which blocks redis while it walks the whole keyspace, so
+// like SCAN a page can hold slightly more or fewer than limit documents.
+func (s *Redis[T]) List(ctx context.Context, cursor uint64, limit int64) ([]T, uint64, error) {
+
+	docs := make([]T, 0)
+
+	match := s.prefix + "*"
+	for {
+		ks, next, err := s.client.Scan(ctx, cursor, match, limit-int64(len(docs))).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, key := range ks {
+			doc, err := s.getKey(ctx, key)
+			if err != nil {
+				return nil, 0, fmt.Errorf("getting %v: %w", key, err)
+			}
+			docs = append(docs, doc)
+		}
+
+		cursor = next
+		if cursor == 0 || int64(len(docs)) >= limit {
+			return docs, cursor, nil
+		}
+	}
+}
+
+//------------------------------------------------------------
+// MEMORY
+//------------------------------------------------------------
+
+// Memory keeps each document as JSON in a map, so that like with redis the
+// caller never shares a document with the store. It is safe for concurrent
+// use.
+type Memory[T any] struct {
+	mu   sync.RWMutex
+	docs map[string][]byte
+}
+
+func NewMemory[T any]() *Memory[T] {
+	return &Memory[T]{docs: make(map[string][]byte)}
+}
+
+// Get returns the document with the ID id, or ErrNotFound
+func (s *Memory[T]) Get(ctx context.Context, id string) (T, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.get(id)
+}
+
+func (s *Memory[T]) get(id string) (T, error) {
+
+	var doc T
+	data, ok := s.docs[id]
+	if !ok {
+		return doc, ErrNotFound
+	}
+	err := json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// Set adds the document with the ID id, or overwrites it if it exists
+func (s *Memory[T]) Set(ctx context.Context, id string, doc T) error {
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.docs[id] = data
+	return nil
+}
+
+// Delete removes the document with the ID id, and reports whether it existed
+func (s *Memory[T]) Delete(ctx context.Context, id string) (bool, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.docs[id]
+	delete(s.docs, id)
+	return ok, nil
+}
+
+// List returns a page of documents starting at cursor, along with the cursor
+// of the next page (0 when there are no more documents). The documents are
+// ordered by ID and the cursor is the position of the next one.
+func (s *Memory[T]) List(ctx context.Context, cursor uint64, limit int64) ([]T, uint64, error) {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.docs))
+	for id := range s.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	docs := make([]T, 0)
+	for i := cursor; i < uint64(len(ids)); i++ {
+		if int64(len(docs)) >= limit {
+			return docs, i, nil
+		}
+		doc, err := s.get(ids[i])
+		if err != nil {
+			return nil, 0, fmt.Errorf("getting %v: %w", ids[i], err)
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, 0, nil
+}
+
+// ParseBackend checks that backend is one of the backends a store can be
+// selected from
+func ParseBackend(backend string) (string, error) {
+	switch b := strings.ToLower(backend); b {
+	case BackendRedis, BackendMemory:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown store %q, it must be %v or %v", backend, BackendRedis, BackendMemory)
+	}
+}
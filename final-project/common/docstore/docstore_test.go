@@ -0,0 +1,95 @@
+package docstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type doc struct {
+	ID    string
+	Tags  []string
+	Count int
+}
+
+func TestMemory(t *testing.T) {
+
+	ctx := context.Background()
+	s := NewMemory[doc]()
+
+	if _, err := s.Get(ctx, "/docs/1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() of a missing document error = %v, want %v", err, ErrNotFound)
+	}
+
+	original := doc{ID: "/docs/1", Tags: []string{"a"}, Count: 1}
+	if err := s.Set(ctx, "/docs/1", original); err != nil {
+		t.Fatal(err)
+	}
+
+	// the store keeps a copy, like redis keeps the JSON
+	original.Tags[0] = "changed"
+	got, err := s.Get(ctx, "/docs/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Tags[0] != "a" {
+		t.Errorf("the stored document changed along with the caller's: %+v", got)
+	}
+	got.Tags[0] = "changed"
+	if again, _ := s.Get(ctx, "/docs/1"); again.Tags[0] != "a" {
+		t.Errorf("the stored document changed along with a returned one: %+v", again)
+	}
+
+	if err := s.Set(ctx, "/docs/1", doc{ID: "/docs/1", Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.Get(ctx, "/docs/1"); got.Count != 2 {
+		t.Errorf("Set() didn't overwrite the document: %+v", got)
+	}
+
+	if deleted, err := s.Delete(ctx, "/docs/1"); !deleted || err != nil {
+		t.Errorf("Delete() = %v, %v, want true", deleted, err)
+	}
+	if deleted, err := s.Delete(ctx, "/docs/1"); deleted || err != nil {
+		t.Errorf("deleting again = %v, %v, want false", deleted, err)
+	}
+}
+
+// Paging through the documents returns each of them once, in ID order
+func TestMemoryList(t *testing.T) {
+
+	ctx := context.Background()
+	s := NewMemory[doc]()
+	for i := 1; i <= 7; i++ {
+		id := fmt.Sprintf("/docs/%v", i)
+		if err := s.Set(ctx, id, doc{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	pages := 0
+	var cursor uint64
+	for {
+		page, next, err := s.List(ctx, cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, d := range page {
+			ids = append(ids, d.ID)
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	if pages != 3 {
+		t.Errorf("%v pages of 3 for 7 documents, want 3", pages)
+	}
+	if fmt.Sprint(ids) != "[/docs/1 /docs/2 /docs/3 /docs/4 /docs/5 /docs/6 /docs/7]" {
+		t.Errorf("listed %v", ids)
+	}
+}
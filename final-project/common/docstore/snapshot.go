@@ -0,0 +1,198 @@
+package docstore
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"drexel.edu/common/audit"
+)
+
+// SnapshotVersion is the version of the snapshot format written by the
+// APIs. A snapshot with a newer version is rejected rather than half read.
+const SnapshotVersion = 1
+
+// The Kinds of documents a snapshot can hold
+const (
+	KindPolls  = "polls"
+	KindVoters = "voters"
+	KindVotes  = "votes"
+)
+
+// Snapshot is the versioned JSON dump of every document of one Kind, as
+// returned by GET /admin/export and accepted by POST /admin/import
+type Snapshot[T any] struct {
+	Version    int
+	Kind       string
+	ExportedAt time.Time
+	Items      []T
+}
+
+// ImportResult is returned by POST /admin/import
+type ImportResult struct {
+	Kind     string
+	Imported int
+}
+
+func NewSnapshot[T any](kind string, items []T) Snapshot[T] {
+	if items == nil {
+		items = make([]T, 0)
+	}
+	return Snapshot[T]{
+		Version:    SnapshotVersion,
+		Kind:       kind,
+		ExportedAt: time.Now().UTC(),
+		Items:      items,
+	}
+}
+
+// Check returns an error unless the snapshot holds documents of kind in a
+// version that can be imported
+func (s Snapshot[T]) Check(kind string) error {
+	if s.Kind != kind {
+		return fmt.Errorf("the snapshot holds %q, not %q", s.Kind, kind)
+	}
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return fmt.Errorf("snapshot version %v is not supported, it must be between 1 and %v", s.Version, SnapshotVersion)
+	}
+	return nil
+}
+
+// ReadSnapshotFile reads the snapshot of documents of kind from the file at
+// path
+func ReadSnapshotFile[T any](path string, kind string) (Snapshot[T], error) {
+
+	var snapshot Snapshot[T]
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("reading snapshot %v: %w", path, err)
+	}
+	if err := snapshot.Check(kind); err != nil {
+		return snapshot, fmt.Errorf("reading snapshot %v: %w", path, err)
+	}
+
+	return snapshot, nil
+}
+
+// WriteSnapshotFile writes the snapshot as indented JSON to the file at path,
+// or to stdout if path is empty or "-"
+func WriteSnapshotFile[T any](path string, snapshot Snapshot[T]) error {
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "" || path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Snapshots exports and imports every document of one Kind, the PollAPI,
+// VoterAPI and VotesAPI implement it
+type Snapshots[T any] interface {
+	Export(ctx context.Context) (Snapshot[T], error)
+	Import(ctx context.Context, snapshot Snapshot[T], actor audit.Actor) (int, error)
+	Empty(ctx context.Context) (bool, error)
+}
+
+// SnapshotCommands are the export and import commands and the -restore flag
+// of an API, e.g. "poll-api export -o polls.json"
+type SnapshotCommands[T any] struct {
+	// Service is the name of the API's command, e.g. "poll-api"
+	Service string
+	Kind    string
+	API     Snapshots[T]
+}
+
+// noun is how the documents are called in the log, e.g. "Polls"
+func (s SnapshotCommands[T]) noun() string {
+	return strings.ToUpper(s.Kind[:1]) + s.Kind[1:]
+}
+
+// Export writes a snapshot of every document to the file given with -o, or
+// to stdout
+func (s SnapshotCommands[T]) Export(args []string) error {
+
+	exportFlags := flag.NewFlagSet("export", flag.ContinueOnError)
+	outFile := exportFlags.String("o", "-", "File to write the snapshot to")
+	if err := exportFlags.Parse(args); err != nil {
+		return err
+	}
+
+	snapshot, err := s.API.Export(context.Background())
+	if err != nil {
+		return fmt.Errorf("exporting %v: %w", s.noun(), err)
+	}
+
+	if err := WriteSnapshotFile(*outFile, snapshot); err != nil {
+		return fmt.Errorf("writing the snapshot: %w", err)
+	}
+	return nil
+}
+
+// Import adds every document in the snapshot file given as the argument
+func (s SnapshotCommands[T]) Import(args []string) error {
+
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %v import <snapshot file>", s.Service)
+	}
+
+	snapshot, err := ReadSnapshotFile[T](args[0], s.Kind)
+	if err != nil {
+		return fmt.Errorf("reading the snapshot: %w", err)
+	}
+
+	imported, err := s.API.Import(context.Background(), snapshot, audit.CommandActor(s.Service+" import"))
+	if err != nil {
+		return fmt.Errorf("importing %v, %v were imported: %w", s.noun(), imported, err)
+	}
+
+	log.Printf("Imported %d %v from %v", imported, s.noun(), args[0])
+	return nil
+}
+
+// Restore adds every document in the snapshot file at path, as -restore
+// does before the API starts serving. It is only imported while there are no
+// documents at all, so that a restart doesn't overwrite what was written
+// since the snapshot was taken, and an error is only logged.
+func (s SnapshotCommands[T]) Restore(path string) {
+
+	ctx := context.Background()
+
+	empty, err := s.API.Empty(ctx)
+	if err != nil {
+		log.Printf("Error checking for %v before restoring the snapshot: %v", s.noun(), err)
+		return
+	}
+	if !empty {
+		log.Printf("Not restoring %v, there are %v already", path, s.noun())
+		return
+	}
+
+	snapshot, err := ReadSnapshotFile[T](path, s.Kind)
+	if err != nil {
+		log.Println("Error reading the snapshot: ", err)
+		return
+	}
+
+	imported, err := s.API.Import(ctx, snapshot, audit.CommandActor(s.Service+" restore"))
+	if err != nil {
+		log.Printf("Error restoring %v, %v were imported: %v", s.noun(), imported, err)
+		return
+	}
+
+	log.Printf("Restored %d %v from %v", imported, s.noun(), path)
+}
@@ -0,0 +1,134 @@
+package docstore
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"drexel.edu/common/audit"
+)
+
+// memorySnapshots exports and imports the documents of a Memory store, as
+// the APIs do with theirs
+type memorySnapshots struct {
+	store *Memory[doc]
+}
+
+func (m memorySnapshots) Export(ctx context.Context) (Snapshot[doc], error) {
+	docs, _, err := m.store.List(ctx, 0, 1000)
+	return NewSnapshot("docs", docs), err
+}
+
+func (m memorySnapshots) Import(ctx context.Context, snapshot Snapshot[doc], actor audit.Actor) (int, error) {
+	if err := snapshot.Check("docs"); err != nil {
+		return 0, err
+	}
+	for i, d := range snapshot.Items {
+		if err := m.store.Set(ctx, d.ID, d); err != nil {
+			return i, err
+		}
+	}
+	return len(snapshot.Items), nil
+}
+
+func (m memorySnapshots) Empty(ctx context.Context) (bool, error) {
+	docs, _, err := m.store.List(ctx, 0, 1)
+	return len(docs) == 0, err
+}
+
+func commandsFor(store *Memory[doc]) SnapshotCommands[doc] {
+	return SnapshotCommands[doc]{Service: "test-api", Kind: "docs", API: memorySnapshots{store}}
+}
+
+func listAll(t *testing.T, store *Memory[doc]) []doc {
+	t.Helper()
+	docs, _, err := store.List(context.Background(), 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docs
+}
+
+// What export writes, import and -restore read back unchanged
+func TestSnapshotCommandsRoundTrip(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "docs.json")
+
+	source := NewMemory[doc]()
+	for i := 1; i <= 3; i++ {
+		id := fmt.Sprintf("/docs/%v", i)
+		if err := source.Set(ctx, id, doc{ID: id, Tags: []string{"t"}, Count: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := commandsFor(source).Export([]string{"-o", path}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	imported := NewMemory[doc]()
+	if err := commandsFor(imported).Import([]string{path}); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	if got, want := listAll(t, imported), listAll(t, source); !reflect.DeepEqual(got, want) {
+		t.Errorf("imported %+v, want %+v", got, want)
+	}
+
+	restored := NewMemory[doc]()
+	commandsFor(restored).Restore(path)
+	if got, want := listAll(t, restored), listAll(t, source); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored %+v, want %+v", got, want)
+	}
+}
+
+// -restore leaves a store that has documents alone
+func TestSnapshotCommandsRestoreOnlyIntoAnEmptyStore(t *testing.T) {
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "docs.json")
+
+	if err := WriteSnapshotFile(path, NewSnapshot("docs", []doc{{ID: "/docs/1", Count: 1}})); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemory[doc]()
+	if err := store.Set(ctx, "/docs/1", doc{ID: "/docs/1", Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	commandsFor(store).Restore(path)
+
+	if got, _ := store.Get(ctx, "/docs/1"); got.Count != 2 {
+		t.Errorf("the snapshot overwrote the stored document: %+v", got)
+	}
+}
+
+func TestReadSnapshotFile(t *testing.T) {
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		snapshot Snapshot[doc]
+		wantErr  bool
+	}{
+		{"the current version", NewSnapshot("docs", []doc{{ID: "/docs/1"}}), false},
+		{"another kind", NewSnapshot(KindVotes, []doc{{ID: "/docs/1"}}), true},
+		{"a newer version", Snapshot[doc]{Version: SnapshotVersion + 1, Kind: "docs"}, true},
+		{"no version", Snapshot[doc]{Kind: "docs"}, true},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("%v.json", i))
+			if err := WriteSnapshotFile(path, tt.snapshot); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadSnapshotFile[doc](path, "docs")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReadSnapshotFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Package health serves the liveness and readiness probes of the Poll, Voter
+// and Votes APIs. GET /livez only says the process is up and which build it
+// is, GET /readyz also checks every dependency of the API (redis, and the
+// other APIs it calls) and responds 503 Service Unavailable if one of them is
+// down, so that compose or kubernetes stop sending it requests. It also
+// responds 503 once the API is shutting down (see SetDraining), before the
+// API stops accepting requests.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultTimeout is how long a dependency has to respond to its check
+const DefaultTimeout = 2 * time.Second
+
+// The Status of a Report and of each of its Checks
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// Check checks that one dependency of an API is up, Probe returns an error if
+// it is not
+type Check struct {
+	Name  string
+	Probe func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a Check, Error is why it failed
+type CheckResult struct {
+	Status   string
+	Duration string
+	Error    string `json:",omitempty"`
+}
+
+// Report is the body of GET /livez and GET /readyz. Checks and Circuits are
+// left out of GET /livez. Circuits is the state of the circuit breaker of
+// each API called so far, by its host, it doesn't affect the Status.
+type Report struct {
+	Status   string
+	Service  string
+	Version  string
+	Commit   string
+	Checks   map[string]CheckResult `json:",omitempty"`
+	Circuits map[string]string      `json:",omitempty"`
+}
+
+// Checker runs the Checks of an API
+type Checker struct {
+	service  string
+	version  string
+	commit   string
+	timeout  time.Duration
+	checks   []Check
+	circuits func() map[string]string
+	draining atomic.Bool
+}
+
+// New returns a Checker for the API service of the given version and commit.
+// Without a commit (one is set with -ldflags at build time) the VCS revision
+// go build stamped into the binary is reported, if any.
+func New(service string, version string, commit string, checks ...Check) *Checker {
+	if commit == "" {
+		commit = vcsRevision()
+	}
+	return &Checker{
+		service: service,
+		version: version,
+		commit:  commit,
+		timeout: DefaultTimeout,
+		checks:  checks,
+	}
+}
+
+// SetTimeout sets how long each dependency has to respond to its Check
+func (h *Checker) SetTimeout(timeout time.Duration) *Checker {
+	h.timeout = timeout
+	return h
+}
+
+// SetCircuits sets where the states of the circuit breakers reported by
+// GET /readyz come from, e.g. resilience.States
+func (h *Checker) SetCircuits(circuits func() map[string]string) *Checker {
+	h.circuits = circuits
+	return h
+}
+
+// SetDraining makes GET /readyz fail from then on, without running the
+// Checks, so that the API is taken out of rotation while it shuts down
+func (h *Checker) SetDraining() {
+	h.draining.Store(true)
+}
+
+func vcsRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// Ready runs every Check at once, each with the timeout, and reports whether
+// they all passed. While the API is draining it reports StatusDraining.
+func (h *Checker) Ready(ctx context.Context) Report {
+
+	report := h.report()
+	if h.draining.Load() {
+		report.Status = StatusDraining
+		return report
+	}
+	report.Checks = make(map[string]CheckResult, len(h.checks))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := h.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	if h.circuits != nil {
+		report.Circuits = h.circuits()
+	}
+
+	return report
+}
+
+func (h *Checker) run(ctx context.Context, check Check) CheckResult {
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Probe(ctx)
+	duration := time.Since(start).Round(time.Microsecond).String()
+
+	if err != nil {
+		return CheckResult{Status: StatusUnavailable, Duration: duration, Error: err.Error()}
+	}
+	return CheckResult{Status: StatusOK, Duration: duration}
+}
+
+func (h *Checker) report() Report {
+	return Report{Status: StatusOK, Service: h.service, Version: h.version, Commit: h.commit}
+}
+
+// Livez is the implementation for GET /livez, it responds 200 OK as long as
+// the process is up, without checking its dependencies
+func (h *Checker) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, h.report())
+}
+
+// Readyz is the implementation for GET /readyz, it responds 200 OK if every
+// Check passed and 503 Service Unavailable otherwise (or while draining),
+// with the Report either way
+func (h *Checker) Readyz(c *gin.Context) {
+	report := h.Ready(c.Request.Context())
+	if report.Status != StatusOK {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+//------------------------------------------------------------
+// CHECKS
+//------------------------------------------------------------
+
+// Redis checks that redis answers a PING
+func Redis(client *redis.Client) Check {
+	return Check{
+		Name: "redis",
+		Probe: func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		},
+	}
+}
+
+// API checks that the API service at baseURL is up, with its GET /livez. Its
+// readiness is not checked, since the Voter API and the Votes API depend on
+// each other and would otherwise never become ready.
+func API(service string, baseURL string) Check {
+	return Check{
+		Name: service,
+		Probe: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/livez", nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("GET %v/livez responded %v", baseURL, resp.Status)
+			}
+			return nil
+		},
+	}
+}
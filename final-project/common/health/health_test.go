@@ -0,0 +1,152 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func probe(t *testing.T, h *Checker, path string) (int, Report) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/livez", h.Livez)
+	r.GET("/readyz", h.Readyz)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, report
+}
+
+func up(name string) Check {
+	return Check{Name: name, Probe: func(context.Context) error { return nil }}
+}
+
+func down(name string) Check {
+	return Check{Name: name, Probe: func(context.Context) error { return errors.New("connection refused") }}
+}
+
+// hung waits for its context, like a dependency that doesn't answer
+func hung(name string) Check {
+	return Check{Name: name, Probe: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+}
+
+func TestReadyz(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		checks     []Check
+		wantStatus int
+		wantChecks map[string]string
+	}{
+		{"no dependencies", nil, http.StatusOK, map[string]string{}},
+		{"every dependency up", []Check{up("redis"), up("votes-api")}, http.StatusOK,
+			map[string]string{"redis": StatusOK, "votes-api": StatusOK}},
+		{"one dependency down", []Check{up("redis"), down("votes-api")}, http.StatusServiceUnavailable,
+			map[string]string{"redis": StatusOK, "votes-api": StatusUnavailable}},
+		{"one dependency not answering", []Check{hung("redis"), up("votes-api")}, http.StatusServiceUnavailable,
+			map[string]string{"redis": StatusUnavailable, "votes-api": StatusOK}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			h := New("voter-api", "1.2.3", "abc123", tt.checks...).SetTimeout(20 * time.Millisecond)
+			status, report := probe(t, h, "/readyz")
+
+			if status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", status, tt.wantStatus)
+			}
+			if report.Service != "voter-api" || report.Version != "1.2.3" || report.Commit != "abc123" {
+				t.Errorf("reported %v %v %v", report.Service, report.Version, report.Commit)
+			}
+			if len(report.Checks) != len(tt.wantChecks) {
+				t.Errorf("Checks = %+v, want %v", report.Checks, tt.wantChecks)
+			}
+			for name, want := range tt.wantChecks {
+				result := report.Checks[name]
+				if result.Status != want || (want == StatusOK) != (result.Error == "") {
+					t.Errorf("%v: %+v, want %v", name, result, want)
+				}
+			}
+		})
+	}
+}
+
+// GET /livez doesn't look at the dependencies
+func TestLivez(t *testing.T) {
+
+	h := New("voter-api", "1.2.3", "abc123", down("redis"))
+	status, report := probe(t, h, "/livez")
+
+	if status != http.StatusOK || report.Status != StatusOK || report.Checks != nil {
+		t.Errorf("GET /livez = %v %+v, want 200 without Checks", status, report)
+	}
+}
+
+// Once draining the API is unready without its dependencies being checked
+func TestReadyzWhileDraining(t *testing.T) {
+
+	checked := false
+	h := New("voter-api", "1.2.3", "", Check{Name: "redis", Probe: func(context.Context) error {
+		checked = true
+		return nil
+	}})
+	h.SetDraining()
+
+	status, report := probe(t, h, "/readyz")
+	if status != http.StatusServiceUnavailable || report.Status != StatusDraining {
+		t.Errorf("GET /readyz = %v %v, want 503 %v", status, report.Status, StatusDraining)
+	}
+	if checked {
+		t.Errorf("redis was checked while draining")
+	}
+	if status, _ := probe(t, h, "/livez"); status != http.StatusOK {
+		t.Errorf("GET /livez = %v while draining, want 200", status)
+	}
+}
+
+// The check of another API asks for its /livez, not its /readyz, since the
+// Voter API and the Votes API check each other
+func TestAPICheck(t *testing.T) {
+
+	var paths []string
+	status := http.StatusOK
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer api.Close()
+
+	check := API("votes-api", api.URL)
+	if err := check.Probe(context.Background()); err != nil {
+		t.Errorf("Probe() error = %v", err)
+	}
+	if len(paths) != 1 || paths[0] != "/livez" {
+		t.Errorf("requested %v, want [/livez]", paths)
+	}
+
+	status = http.StatusInternalServerError
+	if err := check.Probe(context.Background()); err == nil {
+		t.Errorf("Probe() of an API responding 500 passed")
+	}
+
+	api.Close()
+	if err := check.Probe(context.Background()); err == nil {
+		t.Errorf("Probe() of an API that is down passed")
+	}
+}
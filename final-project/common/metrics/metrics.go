@@ -0,0 +1,145 @@
+// Package metrics collects the Prometheus metrics of the Poll, Voter and
+// Votes APIs, which each serve them on GET /metrics: the requests they
+// handle, the redis commands they run and the calls they make to each other.
+// The Votes API adds its own counters, e.g. of the Votes cast per Poll.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle an HTTP request, by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	redisCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "Time taken by a redis command, by command.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"command"})
+
+	redisErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_errors_total",
+		Help: "Redis commands that failed, by command. A missing key is not an error.",
+	}, []string{"command"})
+
+	clientRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_client_request_duration_seconds",
+		Help:    "Time taken by a call to one of the other APIs, by host, method and status (error if there was no response).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"host", "method", "status"})
+)
+
+// Handler is the implementation for GET /metrics
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware counts and times every request. The route is the path
+// template (e.g. /polls/:id), so that every Poll doesn't get its own series.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		httpRequests.WithLabelValues(route, c.Request.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+//------------------------------------------------------------
+// REDIS
+//------------------------------------------------------------
+
+type redisStartKey struct{}
+
+// RedisHook times every command run by the redis client it is added to, and
+// counts the ones that fail
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	observeRedis(ctx, cmd)
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		observeRedis(ctx, cmd)
+	}
+	return nil
+}
+
+func observeRedis(ctx context.Context, cmd redis.Cmder) {
+
+	command := cmd.Name()
+	if start, ok := ctx.Value(redisStartKey{}).(time.Time); ok {
+		redisCommandDuration.WithLabelValues(command).Observe(time.Since(start).Seconds())
+	}
+
+	if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+		redisErrors.WithLabelValues(command).Inc()
+	}
+}
+
+//------------------------------------------------------------
+// CLIENT
+//------------------------------------------------------------
+
+// transport times every call it sends
+type transport struct {
+	next http.RoundTripper
+}
+
+// Transport returns an http.RoundTripper that times the calls sent through
+// next
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return &transport{next: next}
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(r)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	clientRequestDuration.WithLabelValues(r.URL.Host, r.Method, status).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
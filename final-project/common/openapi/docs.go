@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"net/http"
+
+	"drexel.edu/common/apierror"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+)
+
+// swaggerUI is the Swagger UI page of the document on GET /openapi.json. The
+// links are relative, so that the page also works behind a proxy that serves
+// the API under a path.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>Swagger UI</title>
+  <link rel="stylesheet" type="text/css" href="docs/swagger-ui.css">
+  <link rel="icon" type="image/png" href="docs/favicon-32x32.png" sizes="32x32">
+  <link rel="icon" type="image/png" href="docs/favicon-16x16.png" sizes="16x16">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="docs/swagger-ui-bundle.js" charset="UTF-8"></script>
+  <script src="docs/swagger-ui-standalone-preset.js" charset="UTF-8"></script>
+  <script>
+    window.onload = function () {
+      window.ui = SwaggerUIBundle({
+        url: "openapi.json",
+        dom_id: "#swagger-ui",
+        deepLinking: true,
+        presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+        layout: "StandaloneLayout"
+      });
+    };
+  </script>
+</body>
+</html>
+`
+
+// asset is one of the files the Swagger UI page loads
+type asset struct {
+	contentType string
+	data        []byte
+}
+
+var assets = map[string]asset{
+	"swagger-ui.css":                  {"text/css; charset=utf-8", swaggerFiles.FileSwaggerUICSS},
+	"swagger-ui-bundle.js":            {"application/javascript; charset=utf-8", swaggerFiles.FileSwaggerUIBundleJs},
+	"swagger-ui-standalone-preset.js": {"application/javascript; charset=utf-8", swaggerFiles.FileSwaggerUIStandalonePresetJs},
+	"favicon-32x32.png":               {"image/png", swaggerFiles.FileFavicon32x32Png},
+	"favicon-16x16.png":               {"image/png", swaggerFiles.FileFavicon16x16Png},
+}
+
+// SwaggerUI is the implementation for GET /docs and GET /docs/*file
+// returns the Swagger UI page, or one of the files it loads (which are
+// embedded in the binary, so the page works without internet access)
+func SwaggerUI(c *gin.Context) {
+
+	file := c.Param("file")
+	switch file {
+	case "":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUI))
+		return
+	case "/":
+		c.Redirect(http.StatusMovedPermanently, "../docs")
+		return
+	}
+
+	a, ok := assets[file[1:]]
+	if !ok {
+		apierror.Abort(c, apierror.NotFound("file", "%v is not a file of the Swagger UI.", file[1:]))
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, a.contentType, a.data)
+}
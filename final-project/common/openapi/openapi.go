@@ -0,0 +1,401 @@
+// Package openapi describes the routes of the Poll, Voter and Votes APIs in
+// an OpenAPI 3 document, which each API serves on GET /openapi.json along
+// with a Swagger UI page on GET /docs. The schemas are made from the Go types
+// the handlers bind and respond with, so they can't drift from them, and
+// Check makes sure there is an operation for every route registered in
+// main.go (and a route for every operation). Middleware validates the
+// requests and responses against the document.
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/health"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+// The security schemes of the document, see Auth
+const (
+	SecurityBearer  = "bearer"
+	SecurityService = "service"
+)
+
+// Auth is who can call an operation, the same as the auth middleware of its
+// route. An operation without an Auth is open to everyone.
+type Auth struct {
+	// User is whether an API key or JWT can be used, of an admin or of one of
+	// the Roles
+	User  bool
+	Roles []string
+	// Services are the APIs whose signed requests are allowed
+	Services []string
+}
+
+// Require is the Auth of a route with authn.Require(roles...)
+func Require(roles ...string) *Auth {
+	return &Auth{User: true, Roles: roles}
+}
+
+// RequireService is the Auth of a route with authn.RequireService(services...)
+func RequireService(services ...string) *Auth {
+	return &Auth{Services: services}
+}
+
+// RequireAdminOrService is the Auth of a route with
+// authn.RequireAdminOrService(services...)
+func RequireAdminOrService(services ...string) *Auth {
+	return &Auth{User: true, Services: services}
+}
+
+// describe says who can call the operation, for its description
+func (a *Auth) describe() string {
+
+	var who []string
+	if a.User {
+		user := "the API key or JWT of an " + auth.RoleAdmin
+		for _, role := range a.Roles {
+			user += " or a " + role
+		}
+		who = append(who, user)
+	}
+	if len(a.Services) > 0 {
+		who = append(who, fmt.Sprintf("a request signed by the %v", strings.Join(a.Services, " or ")))
+	}
+	return "Needs " + strings.Join(who, ", or ") + "."
+}
+
+// Parameter is a query parameter of an operation
+type Parameter struct {
+	Name        string
+	Description string
+	// Type is the JSON schema type of its value: string (the default),
+	// integer or boolean, or date-time for an RFC 3339 time
+	Type string
+}
+
+// The query parameters of the list endpoints, see the Paging section of the
+// readme
+var PageParams = []Parameter{
+	{Name: "cursor", Type: "integer", Description: "Where the page starts, from the Next link of the previous page, 0 (the default) is the first page."},
+	{Name: "limit", Type: "integer", Description: "How many items the page has, 100 by default and at most 1000."},
+}
+
+// The query parameters of GET /admin/audit, see audit.Handler
+var AuditParams = []Parameter{
+	{Name: "entity", Description: "Only the entries of poll, voter or vote, or of the Poll, Voter or Vote with this ID (e.g. /polls/1)."},
+	{Name: "since", Type: "date-time", Description: "Only the entries made since this RFC 3339 time."},
+	{Name: "cursor", Description: "The entry to continue after, from the Next link of the previous page."},
+	{Name: "limit", Type: "integer", Description: "How many entries the page has, 100 by default and at most 1000."},
+}
+
+// Operation describes a route
+type Operation struct {
+	// ID is the operationId, generated clients name their methods after it
+	ID          string
+	Summary     string
+	Description string
+	// Tag groups the operation with the others of the same resource
+	Tag   string
+	Auth  *Auth
+	Query []Parameter
+	// Body is a value of the type of the JSON request body, nil if there is
+	// none
+	Body any
+	// Response is a value of the type of the JSON body of a 200 response,
+	// nil if there is none. An error response is always an ErrorResponse.
+	Response any
+	// Responses are the JSON bodies of the responses other than 200 that are
+	// not an ErrorResponse, by status
+	Responses map[int]any
+	// ContentType is the type of the body of a 200 response that is not
+	// JSON, e.g. text/event-stream, its schema is a string and it isn't
+	// validated
+	ContentType string
+}
+
+// route is an operation of the document along with where it is in it
+type route struct {
+	path      string
+	pathItem  *openapi3.PathItem
+	operation *openapi3.Operation
+	// json is whether the 200 response is JSON, the others are not validated
+	json bool
+}
+
+// Document is the OpenAPI document of an API
+type Document struct {
+	doc *openapi3.T
+	// types are the Go types of the component schemas, by name
+	types map[string]reflect.Type
+	// routes are the operations by method and route, e.g. "GET /polls/:id"
+	routes map[string]*route
+	// errs are the problems found while adding the operations, see Check
+	errs []error
+
+	jsonOnce sync.Once
+	json     []byte
+	jsonErr  error
+}
+
+// New returns the Document of an API, with the operations of GET
+// /openapi.json and GET /docs, which every API serves
+func New(title string, version string, description string) *Document {
+
+	d := &Document{
+		doc: &openapi3.T{
+			OpenAPI:    "3.0.3",
+			Info:       &openapi3.Info{Title: title, Version: version, Description: description},
+			Paths:      openapi3.Paths{},
+			Components: &openapi3.Components{},
+		},
+		types:  map[string]reflect.Type{},
+		routes: map[string]*route{},
+	}
+	d.doc.Components.Schemas = openapi3.Schemas{}
+	d.doc.Components.SecuritySchemes = openapi3.SecuritySchemes{
+		SecurityBearer: &openapi3.SecuritySchemeRef{Value: &openapi3.SecurityScheme{
+			Type:        "http",
+			Scheme:      "bearer",
+			Description: "An API key, or a JWT signed with the AUTH_JWT_SECRET of the APIs (see the Authentication section of the readme).",
+		}},
+		SecurityService: &openapi3.SecuritySchemeRef{Value: &openapi3.SecurityScheme{
+			Type:        "apiKey",
+			In:          "header",
+			Name:        auth.HeaderServiceSignature,
+			Description: fmt.Sprintf("The HMAC signature of a request from another API, sent with %v and %v.", auth.HeaderServiceName, auth.HeaderServiceTimestamp),
+		}},
+	}
+
+	d.Add(http.MethodGet, "/openapi.json", Operation{
+		ID:       "getOpenAPI",
+		Summary:  "This OpenAPI document",
+		Tag:      "docs",
+		Response: map[string]any{},
+	})
+	d.Add(http.MethodGet, "/docs", Operation{
+		ID:          "getDocs",
+		Summary:     "The Swagger UI page of this OpenAPI document",
+		Tag:         "docs",
+		ContentType: "text/html",
+	})
+	d.Add(http.MethodGet, "/docs/*file", Operation{
+		ID:          "getDocsFile",
+		Summary:     "The scripts, styles and images of the Swagger UI page",
+		Tag:         "docs",
+		ContentType: "application/octet-stream",
+	})
+
+	return d
+}
+
+// AddHealth adds the operations of GET /livez, GET /readyz and GET /metrics,
+// which every API serves, and of the other paths the readiness probe is
+// served on (e.g. /polls/health)
+func (d *Document) AddHealth(readyzAliases ...string) {
+
+	d.Add(http.MethodGet, "/livez", Operation{
+		ID:          "livez",
+		Summary:     "Whether the API is up, and which build it is",
+		Tag:         "health",
+		Response:    health.Report{},
+		Description: "The Checks and Circuits are left out.",
+	})
+	for _, path := range append([]string{"/readyz"}, readyzAliases...) {
+		d.Add(http.MethodGet, path, Operation{
+			ID:          camelCase(path),
+			Summary:     "Whether the API and everything it depends on are up",
+			Tag:         "health",
+			Response:    health.Report{},
+			Responses:   map[int]any{http.StatusServiceUnavailable: health.Report{}},
+			Description: "Responds 503 Service Unavailable when a Check fails or the API is shutting down.",
+		})
+	}
+	d.Add(http.MethodGet, "/metrics", Operation{
+		ID:          "metrics",
+		Summary:     "The Prometheus metrics of the API",
+		Tag:         "health",
+		ContentType: "text/plain",
+	})
+}
+
+// Add adds the operation of the route, the path is the one given to gin,
+// e.g. /polls/:id
+func (d *Document) Add(method string, path string, op Operation) {
+
+	o := openapi3.NewOperation()
+	o.OperationID = op.ID
+	o.Summary = op.Summary
+	o.Description = op.Description
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+
+	if op.Auth != nil {
+		o.Description = strings.TrimSpace(op.Auth.describe() + " " + o.Description)
+		security := openapi3.NewSecurityRequirements()
+		if op.Auth.User {
+			security.With(openapi3.NewSecurityRequirement().Authenticate(SecurityBearer))
+		}
+		if len(op.Auth.Services) > 0 {
+			security.With(openapi3.NewSecurityRequirement().Authenticate(SecurityService))
+		}
+		o.Security = security
+	}
+
+	docPath, params := pathParams(path)
+	o.Parameters = params
+	for _, p := range op.Query {
+		param := openapi3.NewQueryParameter(p.Name).WithDescription(p.Description).WithSchema(paramSchema(p.Type))
+		o.Parameters = append(o.Parameters, &openapi3.ParameterRef{Value: param})
+	}
+
+	if op.Body != nil {
+		body := openapi3.NewRequestBody().WithRequired(true).WithJSONSchemaRef(d.bodySchema(op.Body))
+		o.RequestBody = &openapi3.RequestBodyRef{Value: body}
+	}
+
+	ok := openapi3.NewResponse().WithDescription("OK")
+	switch {
+	case op.ContentType != "":
+		ok.WithContent(openapi3.NewContentWithSchema(openapi3.NewStringSchema(), []string{op.ContentType}))
+	case op.Response != nil:
+		ok.WithJSONSchemaRef(d.bodySchema(op.Response))
+	}
+	errorResponse := openapi3.NewResponse().
+		WithDescription("An error, the Code says which kind").
+		WithJSONSchemaRef(d.schemaOf(reflect.TypeOf(apierror.ErrorResponse{})))
+	o.Responses = openapi3.Responses{
+		"200":     &openapi3.ResponseRef{Value: ok},
+		"default": &openapi3.ResponseRef{Value: errorResponse},
+	}
+	for status, body := range op.Responses {
+		response := openapi3.NewResponse().WithDescription(http.StatusText(status)).WithJSONSchemaRef(d.bodySchema(body))
+		o.Responses[strconv.Itoa(status)] = &openapi3.ResponseRef{Value: response}
+	}
+
+	key := method + " " + path
+	if _, ok := d.routes[key]; ok {
+		d.errs = append(d.errs, fmt.Errorf("openapi: %v is added twice", key))
+	}
+	d.doc.AddOperation(docPath, method, o)
+	d.routes[key] = &route{path: docPath, pathItem: d.doc.Paths[docPath], operation: o, json: op.ContentType == ""}
+}
+
+// bodySchema is the schema of a request or response body like value
+func (d *Document) bodySchema(value any) *openapi3.SchemaRef {
+	if values, ok := value.(oneOf); ok {
+		schema := &openapi3.Schema{}
+		for _, v := range values {
+			schema.OneOf = append(schema.OneOf, d.schemaOf(reflect.TypeOf(v)))
+		}
+		return openapi3.NewSchemaRef("", schema)
+	}
+	return d.schemaOf(reflect.TypeOf(value))
+}
+
+// pathParams returns the OpenAPI path of the gin path, e.g. /polls/{id} for
+// /polls/:id, and its path parameters. The parameters of the IDs (:id) are
+// numbers, a catch-all one (*file) is anything.
+func pathParams(path string) (string, openapi3.Parameters) {
+
+	var params openapi3.Parameters
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		schema := openapi3.NewStringSchema()
+		if segment[0] == ':' {
+			schema.WithPattern("^[0-9]+$")
+		}
+		params = append(params, &openapi3.ParameterRef{Value: openapi3.NewPathParameter(name).WithSchema(schema)})
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// camelCase is the path as an operationId, e.g. pollsHealth for /polls/health
+func camelCase(path string) string {
+	words := strings.FieldsFunc(path, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i := 1; i < len(words); i++ {
+		words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+	}
+	return strings.Join(words, "")
+}
+
+func paramSchema(paramType string) *openapi3.Schema {
+	switch paramType {
+	case "integer":
+		return openapi3.NewIntegerSchema()
+	case "boolean":
+		return openapi3.NewBoolSchema()
+	case "date-time":
+		return openapi3.NewDateTimeSchema()
+	default:
+		return openapi3.NewStringSchema()
+	}
+}
+
+// Check returns every difference between the routes registered with gin and
+// the operations of the Document, as well as the problems with the Document
+// itself, it is called once every route has been registered
+func (d *Document) Check(routes gin.RoutesInfo) error {
+
+	errs := append([]error{}, d.errs...)
+
+	registered := map[string]bool{}
+	for _, r := range routes {
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if _, ok := d.routes[key]; !ok {
+			errs = append(errs, fmt.Errorf("openapi: the route %v has no operation", key))
+		}
+	}
+
+	var missing []string
+	for key := range d.routes {
+		if !registered[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		errs = append(errs, fmt.Errorf("openapi: the operation of %v has no route", key))
+	}
+
+	if err := d.doc.Validate(context.Background()); err != nil {
+		errs = append(errs, fmt.Errorf("openapi: the document is invalid: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Handler returns the implementation for GET /openapi.json
+func (d *Document) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		d.jsonOnce.Do(func() {
+			d.json, d.jsonErr = json.Marshal(d.doc)
+		})
+		if d.jsonErr != nil {
+			apierror.Abort(c, d.jsonErr)
+			return
+		}
+
+		c.Data(http.StatusOK, "application/json; charset=utf-8", d.json)
+	}
+}
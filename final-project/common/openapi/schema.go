@@ -0,0 +1,173 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// oneOf is a body that is one of several types, see OneOf
+type oneOf []any
+
+// OneOf is the Response of an operation that responds with one of values'
+// types, e.g. a list or a Page of it depending on the query
+func OneOf(values ...any) any {
+	return oneOf(values)
+}
+
+// ItemOf is the zero value of the items of list, for a Body or Response of a
+// type that isn't exported, e.g. ItemOf(poll.Poll{}.PollOptions)
+func ItemOf[T any](list []T) (item T) {
+	return
+}
+
+// schemaOf returns the schema of the JSON encoding of a value of type t.
+// Structs are added to the components of the Document and referenced. Every
+// struct is strict: a property that is not one of its fields is invalid.
+// Slices, maps, pointers and interfaces are nullable, since that is how
+// encoding/json writes them when they are nil. No property is required,
+// since the APIs accept partial bodies (e.g. a Poll without PollOptions).
+func (d *Document) schemaOf(t reflect.Type) *openapi3.SchemaRef {
+
+	switch {
+	case t == timeType:
+		return openapi3.NewSchemaRef("", openapi3.NewDateTimeSchema())
+	case t == rawMessageType, t.Kind() == reflect.Interface:
+		return openapi3.NewSchemaRef("", &openapi3.Schema{Nullable: true})
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := d.schemaOf(t.Elem())
+		if elem.Ref != "" {
+			// a $ref can't be made nullable, so the pointer is one of it or null
+			return openapi3.NewSchemaRef("", &openapi3.Schema{Nullable: true, AllOf: openapi3.SchemaRefs{elem}})
+		}
+		schema := *elem.Value
+		schema.Nullable = true
+		return openapi3.NewSchemaRef("", &schema)
+	case reflect.Bool:
+		return openapi3.NewSchemaRef("", openapi3.NewBoolSchema())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return openapi3.NewSchemaRef("", openapi3.NewIntegerSchema())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return openapi3.NewSchemaRef("", openapi3.NewIntegerSchema().WithMin(0))
+	case reflect.Float32, reflect.Float64:
+		return openapi3.NewSchemaRef("", openapi3.NewFloat64Schema())
+	case reflect.String:
+		return openapi3.NewSchemaRef("", openapi3.NewStringSchema())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return openapi3.NewSchemaRef("", openapi3.NewBytesSchema())
+		}
+		schema := openapi3.NewArraySchema()
+		schema.Items = d.schemaOf(t.Elem())
+		schema.Nullable = t.Kind() == reflect.Slice
+		return openapi3.NewSchemaRef("", schema)
+	case reflect.Map:
+		schema := openapi3.NewObjectSchema()
+		schema.AdditionalProperties = openapi3.AdditionalProperties{Schema: d.schemaOf(t.Elem())}
+		schema.Nullable = true
+		return openapi3.NewSchemaRef("", schema)
+	case reflect.Struct:
+		return d.componentOf(t)
+	}
+
+	d.errs = append(d.errs, fmt.Errorf("openapi: %v can't be encoded as JSON", t))
+	return openapi3.NewSchemaRef("", &openapi3.Schema{})
+}
+
+// componentOf returns a reference to the component schema of the struct t,
+// adding it first if it is new. An anonymous struct is not a component.
+func (d *Document) componentOf(t reflect.Type) *openapi3.SchemaRef {
+
+	name := componentName(t)
+	if name == "" {
+		schema := openapi3.NewObjectSchema()
+		schema.Properties = openapi3.Schemas{}
+		d.addFields(schema, t)
+		schema.AdditionalProperties = openapi3.AdditionalProperties{Has: openapi3.BoolPtr(false)}
+		return openapi3.NewSchemaRef("", schema)
+	}
+	ref := "#/components/schemas/" + name
+	if other, ok := d.types[name]; ok {
+		if other != t {
+			d.errs = append(d.errs, fmt.Errorf("openapi: %v and %v are both named %v", other, t, name))
+		}
+		return openapi3.NewSchemaRef(ref, d.doc.Components.Schemas[name].Value)
+	}
+	d.types[name] = t
+
+	schema := openapi3.NewObjectSchema()
+	schema.Properties = openapi3.Schemas{}
+	// added before its fields, so that a struct that contains itself refers
+	// to the component instead of recursing
+	d.doc.Components.Schemas[name] = openapi3.NewSchemaRef("", schema)
+	d.addFields(schema, t)
+	schema.AdditionalProperties = openapi3.AdditionalProperties{Has: openapi3.BoolPtr(false)}
+
+	// the reference is written as a $ref, the schema is what is validated
+	return openapi3.NewSchemaRef(ref, schema)
+}
+
+// addFields adds the properties encoding/json writes for the fields of the
+// struct t, including those of its embedded structs
+func (d *Document) addFields(schema *openapi3.Schema, t reflect.Type) {
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			d.addFields(schema, field.Type)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := d.schemaOf(field.Type)
+		if strings.Contains(","+opts+",", ",string,") {
+			property = openapi3.NewSchemaRef("", openapi3.NewStringSchema())
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// componentName is the name of the struct t in the components, its Go name
+// without the package. An instance of a generic type is named after its type
+// arguments and then the type, e.g. PollPage for client.Page[client.Poll].
+func componentName(t reflect.Type) string {
+
+	name, args, generic := strings.Cut(t.Name(), "[")
+	if !generic {
+		return name
+	}
+
+	var prefix strings.Builder
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		arg = arg[strings.LastIndex(arg, ".")+1:]
+		for _, r := range arg {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				prefix.WriteRune(r)
+			}
+		}
+	}
+	return prefix.String() + name
+}
@@ -0,0 +1,256 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/requestid"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+// The validation modes of Middleware
+const (
+	// ValidationOff validates nothing
+	ValidationOff = "off"
+	// ValidationReport logs the requests and responses that don't match the
+	// Document, but handles and sends them as they are
+	ValidationReport = "report"
+	// ValidationStrict rejects a request that doesn't match the Document with
+	// 400 VALIDATION_FAILED before it reaches its handler, and replaces a
+	// response that doesn't with 500 INTERNAL_ERROR
+	ValidationStrict = "strict"
+)
+
+// ErrInvalidResponse is the error of a response that doesn't match the
+// Document, it is a bug of the API
+var ErrInvalidResponse = errors.New("response does not match the OpenAPI document")
+
+// Middleware validates the requests to the routes of the Document, and their
+// JSON responses, as mode says. Besides its schemas, a request is invalid if
+// it has a query parameter that isn't one of its operation's (e.g. a
+// misspelled ?cascade=true). The credentials are left to the auth middleware
+// of the route, and a request to a route that isn't in the Document (a 404) is
+// left alone.
+func (d *Document) Middleware(mode string) gin.HandlerFunc {
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		SkipSettingDefaults:   true,
+		IncludeResponseStatus: true,
+	}
+
+	return func(c *gin.Context) {
+
+		if mode == ValidationOff {
+			c.Next()
+			return
+		}
+		r, ok := d.routes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathValues(c.Params),
+			Route: &routers.Route{
+				Spec:      d.doc,
+				Path:      r.path,
+				PathItem:  r.pathItem,
+				Method:    c.Request.Method,
+				Operation: r.operation,
+			},
+			Options: options,
+		}
+		if err := validateRequest(ctx, input); err != nil {
+			requestid.Logger(ctx).Println("The request does not match the OpenAPI document: ", err)
+			if mode == ValidationStrict {
+				apierror.Abort(c, err)
+				return
+			}
+		}
+
+		if !r.json {
+			c.Next()
+			return
+		}
+
+		// the response is held back until it is validated
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, status: http.StatusOK, size: -1}
+		c.Writer = buffered
+		defer func() { c.Writer = original }()
+
+		c.Next()
+		c.Writer = original
+
+		err := openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 buffered.status,
+			Header:                 original.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(buffered.body.Bytes())),
+			Options:                options,
+		})
+		if err != nil {
+			requestid.Logger(ctx).Println(fmt.Sprintf("The %v response does not match the OpenAPI document: ", buffered.status), err)
+			if mode == ValidationStrict {
+				apierror.Abort(c, apierror.Wrap(ErrInvalidResponse, "", "The response does not match the OpenAPI document: %v", describe(err)))
+				return
+			}
+		}
+
+		buffered.send()
+	}
+}
+
+// validateRequest validates the request against its operation, the error is
+// an *apierror.Error
+func validateRequest(ctx context.Context, input *openapi3filter.RequestValidationInput) error {
+
+	parameters := input.Route.Operation.Parameters
+	var names []string
+	for name := range input.Request.URL.Query() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if parameters.GetByInAndName(openapi3.ParameterInQuery, name) == nil {
+			return apierror.Validation(name, "%v is not a query parameter of %v %v.", name, input.Route.Method, input.Route.Path)
+		}
+	}
+
+	err := openapi3filter.ValidateRequest(ctx, input)
+	if err == nil {
+		return nil
+	}
+
+	var requestErr *openapi3filter.RequestError
+	if errors.As(err, &requestErr) && requestErr.Parameter != nil {
+		p := requestErr.Parameter
+		return apierror.Validation(p.Name, "The %v parameter %v is invalid: %v", p.In, p.Name, describe(err))
+	}
+	return apierror.Validation(field(err), "The request body is invalid: %v", describe(err))
+}
+
+// describe is the reason for a validation error, with the property of the
+// body it is about
+func describe(err error) string {
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		if f := field(err); f != "" {
+			return fmt.Sprintf("%v: %v", f, schemaErr.Reason)
+		}
+		return schemaErr.Reason
+	}
+
+	var requestErr *openapi3filter.RequestError
+	if errors.As(err, &requestErr) {
+		return reason(requestErr.Reason, requestErr.Err)
+	}
+	var responseErr *openapi3filter.ResponseError
+	if errors.As(err, &responseErr) {
+		return reason(responseErr.Reason, responseErr.Err)
+	}
+	return err.Error()
+}
+
+func reason(reason string, err error) string {
+	switch {
+	case err == nil:
+		return reason
+	case reason == "":
+		return err.Error()
+	default:
+		return reason + ": " + err.Error()
+	}
+}
+
+// field is the property of the body that err is about, e.g.
+// PollOptions.0.PollOptionText, "" if it is about the whole body
+func field(err error) string {
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return strings.Join(schemaErr.JSONPointer(), ".")
+	}
+	return ""
+}
+
+// pathValues are the path parameters of the route, a catch-all one keeps its
+// leading / so that GET /docs/ has a value to redirect
+func pathValues(params gin.Params) map[string]string {
+	values := make(map[string]string, len(params))
+	for _, p := range params {
+		values[p.Key] = p.Value
+	}
+	return values
+}
+
+// bufferedWriter keeps the response of the handler, so that it can be
+// validated before it is sent
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	size   int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	if w.size < 0 {
+		w.size = 0
+	}
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.body.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.size
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.size >= 0
+}
+
+// Flush does nothing, the response is only sent once it is validated
+func (w *bufferedWriter) Flush() {}
+
+// send writes the response to the ResponseWriter it was held back from
+func (w *bufferedWriter) send() {
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	if w.body.Len() > 0 {
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
@@ -0,0 +1,227 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"drexel.edu/common/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+type thing struct {
+	ThingID string
+	Count   int
+}
+
+// newTestRouter serves the routes of a Document validated in mode, and
+// counts the requests that reach a handler. GET /things/9 responds with a
+// Count that isn't a number, like an API with a bug.
+func newTestRouter(mode string) (*gin.Engine, *int) {
+
+	d := New("Things API", "v1", "")
+	d.Add(http.MethodPost, "/things/:id", Operation{ID: "addThing", Body: thing{}, Response: thing{}})
+	d.Add(http.MethodGet, "/things/:id", Operation{ID: "getThing", Query: []Parameter{{Name: "verbose", Type: "boolean"}}, Response: thing{}})
+	d.Add(http.MethodGet, "/things/:id/events", Operation{ID: "thingEvents", ContentType: "text/event-stream"})
+
+	handled := 0
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(d.Middleware(mode))
+	r.POST("/things/:id", func(c *gin.Context) {
+		handled++
+		var t thing
+		if err := c.ShouldBindJSON(&t); err != nil {
+			apierror.Abort(c, apierror.Validation("", "%v", err))
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	})
+	r.GET("/things/:id", func(c *gin.Context) {
+		handled++
+		if c.Param("id") == "9" {
+			c.JSON(http.StatusOK, gin.H{"ThingID": "/things/9", "Count": "many"})
+			return
+		}
+		c.JSON(http.StatusOK, thing{ThingID: "/things/" + c.Param("id"), Count: 1})
+	})
+	r.GET("/things/:id/events", func(c *gin.Context) {
+		handled++
+		c.Data(http.StatusOK, "text/event-stream", []byte("data: not JSON\n\n"))
+	})
+	// a route that isn't in the Document
+	r.GET("/other", func(c *gin.Context) {
+		handled++
+		c.String(http.StatusTeapot, "left alone")
+	})
+	return r, &handled
+}
+
+func TestMiddleware(t *testing.T) {
+
+	const validBody = `{"ThingID": "/things/1", "Count": 2}`
+
+	type want struct {
+		status  int
+		code    string
+		field   string
+		handled bool
+	}
+	ok := want{status: http.StatusOK, handled: true}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		strict      want
+		// report is also what off does, report only logs
+		report    want
+		reportLog string
+	}{
+		{
+			name: "a valid request", method: http.MethodPost, path: "/things/1", contentType: "application/json", body: validBody,
+			strict: ok, report: ok,
+		},
+		{
+			name: "a property the struct doesn't have", method: http.MethodPost, path: "/things/1", contentType: "application/json",
+			body:      `{"ThingID": "/things/1", "Count": 2, "Colour": "red"}`,
+			strict:    want{status: http.StatusBadRequest, code: apierror.CodeValidation},
+			report:    ok,
+			reportLog: `property "Colour" is unsupported`,
+		},
+		{
+			name: "a value of the wrong type", method: http.MethodPost, path: "/things/1", contentType: "application/json",
+			body:      `{"ThingID": "/things/1", "Count": "two"}`,
+			strict:    want{status: http.StatusBadRequest, code: apierror.CodeValidation, field: "Count"},
+			report:    want{status: http.StatusBadRequest, code: apierror.CodeValidation, handled: true},
+			reportLog: "The request does not match",
+		},
+		{
+			name: "a body that isn't sent as JSON", method: http.MethodPost, path: "/things/1", contentType: "application/x-www-form-urlencoded", body: validBody,
+			strict:    want{status: http.StatusBadRequest, code: apierror.CodeValidation},
+			report:    ok,
+			reportLog: "The request does not match",
+		},
+		{
+			name: "an ID that isn't a number", method: http.MethodGet, path: "/things/abc",
+			strict:    want{status: http.StatusBadRequest, code: apierror.CodeValidation, field: "id"},
+			report:    ok,
+			reportLog: "The path parameter id is invalid",
+		},
+		{
+			name: "a query parameter the route doesn't take", method: http.MethodGet, path: "/things/1?verbsoe=true",
+			strict:    want{status: http.StatusBadRequest, code: apierror.CodeValidation, field: "verbsoe"},
+			report:    ok,
+			reportLog: "verbsoe is not a query parameter of GET /things/{id}",
+		},
+		{
+			name: "a query parameter of the wrong type", method: http.MethodGet, path: "/things/1?verbose=yes",
+			strict:    want{status: http.StatusBadRequest, code: apierror.CodeValidation, field: "verbose"},
+			report:    ok,
+			reportLog: "The query parameter verbose is invalid",
+		},
+		{
+			name: "a response that doesn't match", method: http.MethodGet, path: "/things/9",
+			strict:    want{status: http.StatusInternalServerError, code: apierror.CodeInternal, handled: true},
+			report:    ok,
+			reportLog: "The 200 response does not match",
+		},
+		{
+			name: "a response that isn't JSON", method: http.MethodGet, path: "/things/1/events",
+			strict: ok, report: ok,
+		},
+		{
+			name: "a route that isn't in the document", method: http.MethodGet, path: "/other?anything=1",
+			strict: want{status: http.StatusTeapot, handled: true}, report: want{status: http.StatusTeapot, handled: true},
+		},
+	}
+
+	for _, mode := range []string{ValidationStrict, ValidationReport, ValidationOff} {
+		for _, tt := range tests {
+			t.Run(mode+"/"+tt.name, func(t *testing.T) {
+
+				var logs bytes.Buffer
+				previous := log.Writer()
+				log.SetOutput(&logs)
+				defer log.SetOutput(previous)
+
+				r, handled := newTestRouter(mode)
+				req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+				if tt.contentType != "" {
+					req.Header.Set("Content-Type", tt.contentType)
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+
+				want := tt.report
+				if mode == ValidationStrict {
+					want = tt.strict
+				}
+				if w.Code != want.status {
+					t.Fatalf("status = %v, want %v: %v", w.Code, want.status, w.Body)
+				}
+				if (*handled == 1) != want.handled {
+					t.Errorf("the handler was called %v times, want it called: %v", *handled, want.handled)
+				}
+				if want.code != "" {
+					var resp apierror.ErrorResponse
+					if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+						t.Fatal(err)
+					}
+					if resp.Code != want.code || resp.Field != want.field {
+						t.Errorf("Code, Field = %v, %v, want %v, %v: %v", resp.Code, resp.Field, want.code, want.field, resp.Message)
+					}
+				}
+
+				switch {
+				case mode == ValidationReport && tt.reportLog != "":
+					if !strings.Contains(logs.String(), tt.reportLog) {
+						t.Errorf("logged %q, want %q", logs.String(), tt.reportLog)
+					}
+				case mode == ValidationOff && logs.Len() > 0:
+					t.Errorf("logged %q, want nothing logged", logs.String())
+				}
+			})
+		}
+	}
+}
+
+// The response reaches the client as the handler wrote it, once it is
+// validated
+func TestMiddlewareSendsTheResponse(t *testing.T) {
+
+	for _, mode := range []string{ValidationStrict, ValidationReport} {
+		t.Run(mode, func(t *testing.T) {
+
+			r, _ := newTestRouter(mode)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/things/9", nil))
+
+			if mode == ValidationReport {
+				if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"Count":"many"`) {
+					t.Errorf("response = %v %v, want the handler's", w.Code, w.Body)
+				}
+				return
+			}
+			if strings.Contains(w.Body.String(), "many") {
+				t.Errorf("the invalid response was sent: %v", w.Body)
+			}
+
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/things/1", nil))
+			var got thing
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got != (thing{ThingID: "/things/1", Count: 1}) || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+				t.Errorf("response = %+v (%v), want the handler's", got, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
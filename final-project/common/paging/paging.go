@@ -0,0 +1,68 @@
+// Package paging reads the ?cursor= and ?limit= query parameters of the list
+// endpoints of the Poll, Voter and Votes APIs, and builds the next link of
+// the pages they return. A cursor is the SCAN cursor redis returned for the
+// page before, 0 when there are no more pages.
+package paging
+
+import (
+	"fmt"
+	"strconv"
+
+	"drexel.edu/common/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Params reads the ?cursor= and ?limit= query parameters of a list endpoint.
+// paged is false when neither is given, in which case the endpoint returns
+// the whole list.
+func Params(c *gin.Context) (paged bool, cursor uint64, limit int64, err error) {
+
+	cursorS, hasCursor := c.GetQuery("cursor")
+	limitS, hasLimit := c.GetQuery("limit")
+	if !hasCursor && !hasLimit {
+		return false, 0, 0, nil
+	}
+
+	limit = DefaultLimit
+	if hasLimit {
+		if limit, err = ParseLimit(limitS); err != nil {
+			return true, 0, 0, err
+		}
+	}
+
+	if hasCursor && cursorS != "" {
+		cursor, err = strconv.ParseUint(cursorS, 10, 64)
+		if err != nil {
+			return true, 0, 0, apierror.Validation("cursor", "cursor must be a cursor returned in a next link, %q given.", cursorS)
+		}
+	}
+
+	return true, cursor, limit, nil
+}
+
+// ParseLimit reads a ?limit= query parameter, a limit over MaxLimit is
+// lowered to MaxLimit
+func ParseLimit(limitS string) (int64, error) {
+	limit, err := strconv.ParseInt(limitS, 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, apierror.Validation("limit", "limit must be a positive integer, %q given.", limitS)
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	return limit, nil
+}
+
+// NextLink returns the link to the page after the one that ended at cursor,
+// or "" if that was the last page
+func NextLink(path string, cursor uint64, limit int64) string {
+	if cursor == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s?cursor=%d&limit=%d", path, cursor, limit)
+}
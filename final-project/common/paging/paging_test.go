@@ -0,0 +1,65 @@
+package paging
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"drexel.edu/common/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParams(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query      string
+		wantPaged  bool
+		wantCursor uint64
+		wantLimit  int64
+		wantField  string
+	}{
+		{query: "", wantPaged: false},
+		{query: "?limit=10", wantPaged: true, wantLimit: 10},
+		{query: "?cursor=42", wantPaged: true, wantCursor: 42, wantLimit: DefaultLimit},
+		{query: "?cursor=&limit=5", wantPaged: true, wantLimit: 5},
+		{query: "?cursor=42&limit=5000", wantPaged: true, wantCursor: 42, wantLimit: MaxLimit},
+		{query: "?limit=0", wantField: "limit"},
+		{query: "?limit=ten", wantField: "limit"},
+		{query: "?cursor=-1", wantField: "cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/polls"+tt.query, nil)
+
+			paged, cursor, limit, err := Params(c)
+
+			if tt.wantField != "" {
+				var apiErr *apierror.Error
+				if !errors.Is(err, apierror.ErrValidation) || !errors.As(err, &apiErr) || apiErr.Field != tt.wantField {
+					t.Fatalf("Params() error = %v, want a validation error of %v", err, tt.wantField)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Params() error = %v", err)
+			}
+			if paged != tt.wantPaged || cursor != tt.wantCursor || limit != tt.wantLimit {
+				t.Errorf("Params() = %v, %v, %v, want %v, %v, %v", paged, cursor, limit, tt.wantPaged, tt.wantCursor, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	if next := NextLink("/voters", 0, 10); next != "" {
+		t.Errorf("NextLink() of the last page = %q, want none", next)
+	}
+	if next, want := NextLink("/voters", 17, 10), "/voters?cursor=17&limit=10"; next != want {
+		t.Errorf("NextLink() = %q, want %q", next, want)
+	}
+}
@@ -0,0 +1,98 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header is the HTTP header the request id is read from and written to
+const Header = "X-Request-ID"
+
+// contextKey is where the request id is kept in the gin.Context
+const contextKey = "requestid"
+
+// ctxKey is where the request id is kept in the context.Context of the
+// request, which is passed on to the calls to the other APIs
+type ctxKey struct{}
+
+// Middleware makes sure every request has a request id. The id sent by the
+// caller in the X-Request-ID header is kept, otherwise a new one is
+// generated. Either way it is echoed back in the response header, and kept
+// in the context of the request so that it is sent along with the calls to
+// the other APIs.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if id == "" {
+			id = New()
+		}
+		c.Set(contextKey, id)
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), id))
+		c.Header(Header, id)
+		c.Next()
+	}
+}
+
+// Get returns the request id of the request, or "" if the Middleware is not
+// in use
+func Get(c *gin.Context) string {
+	return c.GetString(contextKey)
+}
+
+// New returns a new random request id
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// NewContext returns a copy of ctx that carries the request id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request id carried by ctx, or ""
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Logger returns a logger that starts every line with the request id
+// carried by ctx, so that the lines logged while handling a request can be
+// found (in all three APIs) by its id
+func Logger(ctx context.Context) *log.Logger {
+	id := FromContext(ctx)
+	if id == "" {
+		return log.Default()
+	}
+	return log.New(log.Writer(), log.Prefix()+"request_id="+id+" ", log.Flags()|log.Lmsgprefix)
+}
+
+// LogFormatter is gin's access log line with the request id added, for
+// gin.LoggerWithFormatter
+func LogFormatter(param gin.LogFormatterParams) string {
+
+	id, _ := param.Keys[contextKey].(string)
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | request_id=%v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		param.Path,
+		id,
+		param.ErrorMessage,
+	)
+}
@@ -0,0 +1,219 @@
+// Package resilience keeps a slow or failing API from taking down the APIs
+// that call it. Every call gets a deadline, idempotent GETs are retried a few
+// times with jittered backoff, and each downstream API has a circuit breaker:
+// after enough failures in a row the calls to it fail fast (503 Service
+// Unavailable) for a while instead of piling up, then a single call is let
+// through to see whether it has recovered.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"drexel.edu/common/apierror"
+	"github.com/go-resty/resty/v2"
+)
+
+// Options are the timeouts, retries and circuit breaker thresholds of the
+// calls to the other APIs
+type Options struct {
+	// Timeout is how long a single attempt of a call can take, the deadline
+	// of the request the call is made for still applies
+	Timeout time.Duration
+	// Retries is how many times a failed GET is retried, waiting between
+	// RetryWait and RetryMaxWait (growing, with jitter) before each retry
+	Retries      int
+	RetryWait    time.Duration
+	RetryMaxWait time.Duration
+	// FailureThreshold is how many calls in a row have to fail to open the
+	// circuit of an API, it stays open for OpenDuration
+	FailureThreshold int
+	OpenDuration     time.Duration
+}
+
+// DefaultOptions are the Options of the calls to the other APIs
+var DefaultOptions = Options{
+	Timeout:          5 * time.Second,
+	Retries:          2,
+	RetryWait:        100 * time.Millisecond,
+	RetryMaxWait:     1 * time.Second,
+	FailureThreshold: 5,
+	OpenDuration:     30 * time.Second,
+}
+
+// ErrCircuitOpen is returned for a call to an API whose circuit is open. It
+// is an apierror.ErrUnavailable, so the caller responds 503.
+var ErrCircuitOpen = fmt.Errorf("circuit breaker is open, the API is %w", apierror.ErrUnavailable)
+
+// Configure sets the timeout and the retries of the calls made with client.
+// Only GETs are retried, on a 5xx response or when there was no response,
+// and never while the circuit is open.
+func Configure(client *resty.Client, opts Options) *resty.Client {
+	return client.
+		SetTimeout(opts.Timeout).
+		SetRetryCount(opts.Retries).
+		SetRetryWaitTime(opts.RetryWait).
+		SetRetryMaxWaitTime(opts.RetryMaxWait).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			if resp == nil || resp.Request.Method != http.MethodGet || errors.Is(err, ErrCircuitOpen) {
+				return false
+			}
+			return err != nil || resp.StatusCode() >= http.StatusInternalServerError
+		})
+}
+
+//------------------------------------------------------------
+// CIRCUIT BREAKER
+//------------------------------------------------------------
+
+// The states of a circuit, as reported by States
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// breaker is the circuit breaker of one API. It is closed while the calls
+// succeed, open once FailureThreshold calls in a row failed, and half-open
+// when OpenDuration has passed and one call is let through: if that call
+// succeeds the circuit closes, otherwise it opens again.
+type breaker struct {
+	mu       sync.Mutex
+	opts     Options
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// allow returns ErrCircuitOpen if the call must fail fast
+func (b *breaker) allow() error {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.opts.OpenDuration {
+			return ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		// only one call is let through to see if the API has recovered
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record records the outcome of a call that was allowed
+func (b *breaker) record(failed bool) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.opts.FailureThreshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// release lets another call through a half-open circuit, without recording
+// the outcome of the call that was allowed
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *breaker) currentState() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.opts.OpenDuration {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// breakers are the circuit breakers of this process, by the host of the API
+var breakers = struct {
+	sync.Mutex
+	byHost map[string]*breaker
+}{byHost: make(map[string]*breaker)}
+
+func breakerFor(host string, opts Options) *breaker {
+
+	breakers.Lock()
+	defer breakers.Unlock()
+
+	b, ok := breakers.byHost[host]
+	if !ok {
+		b = &breaker{opts: opts, state: StateClosed}
+		breakers.byHost[host] = b
+	}
+	return b
+}
+
+// States returns the state of the circuit of every API called so far, by its
+// host, for GET /readyz
+func States() map[string]string {
+
+	breakers.Lock()
+	defer breakers.Unlock()
+
+	states := make(map[string]string, len(breakers.byHost))
+	for host, b := range breakers.byHost {
+		states[host] = b.currentState()
+	}
+	return states
+}
+
+// transport sends every call through the circuit breaker of its host
+type transport struct {
+	opts Options
+	next http.RoundTripper
+}
+
+// Transport returns an http.RoundTripper that fails the calls to an API fast
+// while its circuit is open. A call fails if there is no response or the
+// response is a 5xx, a call canceled by its caller doesn't count.
+func Transport(next http.RoundTripper, opts Options) http.RoundTripper {
+	return &transport{opts: opts, next: next}
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	b := breakerFor(r.URL.Host, t.opts)
+	if err := b.allow(); err != nil {
+		return nil, fmt.Errorf("%v: %w", r.URL.Host, err)
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil && errors.Is(r.Context().Err(), context.Canceled) {
+		b.release()
+		return resp, err
+	}
+
+	b.record(err != nil || resp.StatusCode >= http.StatusInternalServerError)
+	return resp, err
+}
@@ -0,0 +1,236 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+var apis atomic.Int64
+
+// testAPI is an API the calls are made to, answering with status. Its host
+// is a name of its own, so that its circuit is not shared with an earlier
+// server that had the same port.
+type testAPI struct {
+	url      string
+	status   atomic.Int64
+	delay    time.Duration
+	received atomic.Int64
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+func newTestAPI(t *testing.T, status int) *testAPI {
+	t.Helper()
+
+	api := &testAPI{url: fmt.Sprintf("http://api-%v.test", apis.Add(1))}
+	api.status.Store(int64(status))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.received.Add(1)
+		if api.delay > 0 {
+			select {
+			case <-time.After(api.delay):
+			case <-r.Context().Done():
+			}
+		}
+		w.WriteHeader(int(api.status.Load()))
+	}))
+	t.Cleanup(server.Close)
+
+	api.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	return api
+}
+
+// newTestClient returns a client configured like auth.ServiceClient, that
+// reaches every testAPI
+func newTestClient(opts Options, apis ...*testAPI) *resty.Client {
+
+	dials := make(map[string]func(ctx context.Context, network, addr string) (net.Conn, error))
+	for _, api := range apis {
+		dials[api.url[len("http://"):]+":80"] = api.dial
+	}
+	base := &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dials[addr](ctx, network, addr)
+	}}
+
+	client := Configure(resty.New(), opts)
+	return client.SetTransport(Transport(base, opts))
+}
+
+var testOptions = Options{
+	Timeout:          time.Second,
+	Retries:          2,
+	RetryWait:        time.Millisecond,
+	RetryMaxWait:     2 * time.Millisecond,
+	FailureThreshold: 100,
+	OpenDuration:     time.Minute,
+}
+
+// Only GETs are retried, and only when the API failed
+func TestRetries(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		method       string
+		status       int
+		wantReceived int64
+	}{
+		{"a GET that fails", http.MethodGet, http.StatusServiceUnavailable, 3},
+		{"a GET that is not found", http.MethodGet, http.StatusNotFound, 1},
+		{"a GET that succeeds", http.MethodGet, http.StatusOK, 1},
+		{"a POST that fails", http.MethodPost, http.StatusInternalServerError, 1},
+		{"a DELETE that fails", http.MethodDelete, http.StatusInternalServerError, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t, tt.status)
+			resp, err := newTestClient(testOptions, api).R().Execute(tt.method, api.url+"/votes/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode() != tt.status || api.received.Load() != tt.wantReceived {
+				t.Errorf("%v after %v calls, want %v after %v", resp.StatusCode(), api.received.Load(), tt.status, tt.wantReceived)
+			}
+		})
+	}
+}
+
+// A slow API makes the call fail after the Timeout rather than holding it
+func TestTimeout(t *testing.T) {
+
+	api := newTestAPI(t, http.StatusOK)
+	api.delay = time.Second
+
+	opts := testOptions
+	opts.Timeout, opts.Retries = 20*time.Millisecond, 0
+
+	start := time.Now()
+	_, err := newTestClient(opts, api).R().Get(api.url + "/polls/1")
+	if err == nil {
+		t.Fatalf("the call to a slow API succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the call gave up after %v, want about %v", elapsed, opts.Timeout)
+	}
+}
+
+// Once an API failed FailureThreshold times in a row its calls fail fast,
+// without being retried, while the calls to another API go on
+func TestCircuitOfEachAPI(t *testing.T) {
+
+	failing, healthy := newTestAPI(t, http.StatusInternalServerError), newTestAPI(t, http.StatusOK)
+	opts := testOptions
+	opts.FailureThreshold, opts.Retries = 3, 0
+	client := newTestClient(opts, failing, healthy)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.R().Post(failing.url + "/votes/1"); err != nil {
+			t.Fatalf("call %v: %v", i, err)
+		}
+	}
+
+	_, err := client.R().Get(failing.url + "/polls/1")
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if failing.received.Load() != 3 {
+		t.Errorf("the failing API received %v calls, want 3", failing.received.Load())
+	}
+
+	if resp, err := client.R().Get(healthy.url + "/polls/1"); err != nil || resp.StatusCode() != http.StatusOK {
+		t.Errorf("the call to another API = %v, %v", resp, err)
+	}
+
+	states := States()
+	if states[failing.url[len("http://"):]] != StateOpen || states[healthy.url[len("http://"):]] != StateClosed {
+		t.Errorf("States() = %v", states)
+	}
+}
+
+// After OpenDuration one call is let through, and the circuit closes if it
+// succeeds or opens again if it fails
+func TestCircuitRecovers(t *testing.T) {
+
+	api := newTestAPI(t, http.StatusBadGateway)
+	opts := testOptions
+	opts.FailureThreshold, opts.Retries, opts.OpenDuration = 1, 0, 20*time.Millisecond
+	client := newTestClient(opts, api)
+	host := api.url[len("http://"):]
+
+	client.R().Get(api.url + "/polls/1")
+	if state := States()[host]; state != StateOpen {
+		t.Fatalf("state = %v, want %v", state, StateOpen)
+	}
+
+	time.Sleep(opts.OpenDuration)
+	if state := States()[host]; state != StateHalfOpen {
+		t.Fatalf("state = %v after OpenDuration, want %v", state, StateHalfOpen)
+	}
+	client.R().Get(api.url + "/polls/1")
+	if state := States()[host]; state != StateOpen {
+		t.Fatalf("state = %v after a failed probe, want %v", state, StateOpen)
+	}
+
+	time.Sleep(opts.OpenDuration)
+	api.status.Store(http.StatusOK)
+	if resp, err := client.R().Get(api.url + "/polls/1"); err != nil || resp.StatusCode() != http.StatusOK {
+		t.Fatalf("the probe = %v, %v", resp, err)
+	}
+	if state := States()[host]; state != StateClosed {
+		t.Errorf("state = %v after a successful probe, want %v", state, StateClosed)
+	}
+}
+
+// A call its caller gave up on says nothing about the API
+func TestCanceledCallsDontCount(t *testing.T) {
+
+	api := newTestAPI(t, http.StatusOK)
+	api.delay = time.Second
+	opts := testOptions
+	opts.FailureThreshold, opts.Retries = 1, 0
+	client := newTestClient(opts, api)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := client.R().SetContext(ctx).Get(api.url + "/polls/1"); err == nil {
+		t.Fatalf("the canceled call succeeded")
+	}
+
+	if state := States()[api.url[len("http://"):]]; state != StateClosed {
+		t.Errorf("state = %v, want %v", state, StateClosed)
+	}
+}
+
+// While half-open only one call at a time is let through
+func TestHalfOpenLetsOneCallThrough(t *testing.T) {
+
+	b := &breaker{opts: Options{FailureThreshold: 1, OpenDuration: time.Millisecond}, state: StateClosed}
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow() error = %v", err)
+	}
+	b.record(true)
+	time.Sleep(2 * time.Millisecond)
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow() of the probe error = %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() during the probe error = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	b.release()
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow() after the probe was released error = %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Package server runs the http.Server of the Poll, Voter and Votes APIs and
+// shuts it down gracefully on SIGINT or SIGTERM (e.g. from docker compose
+// down). GET /readyz starts failing first, so that no new requests are sent
+// to the API, then the API stops accepting connections and the requests in
+// flight (e.g. a Vote half way through its saga) are given time to finish.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"drexel.edu/common/health"
+)
+
+// ReadHeaderTimeout is how long a client has to send the headers of its
+// request, there is no timeout on the rest of the request or on the response,
+// since the results streams stay open
+const ReadHeaderTimeout = 10 * time.Second
+
+// IdleTimeout is how long a keep-alive connection is kept open without a
+// request
+const IdleTimeout = 2 * time.Minute
+
+// Options are how the server is run and shut down
+type Options struct {
+	// Addr is the host:port the server listens on
+	Addr string
+	// Delay is how long the server keeps serving, with GET /readyz failing,
+	// once it is told to stop
+	Delay time.Duration
+	// Timeout is how long the requests in flight then have to finish, after
+	// that their connections are closed
+	Timeout time.Duration
+	// OnShutdown are called when the server starts shutting down, to end the
+	// requests that would otherwise never finish (e.g. the results streams)
+	OnShutdown []func()
+}
+
+// Run serves handler until the process gets SIGINT or SIGTERM, then marks
+// checker as draining and shuts the server down. It returns once every
+// request has finished (or was cut off after opts.Timeout), the caller then
+// closes what the requests used, e.g. its redis client.
+func Run(handler http.Handler, checker *health.Checker, opts Options) error {
+
+	srv := &http.Server{
+		Addr:              opts.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: ReadHeaderTimeout,
+		IdleTimeout:       IdleTimeout,
+	}
+	for _, f := range opts.OnShutdown {
+		srv.RegisterOnShutdown(f)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		log.Println("Listening on " + opts.Addr)
+		served <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-served:
+		// the server could not start, e.g. the port is taken
+		return err
+	case <-ctx.Done():
+	}
+	// a second signal kills the process right away
+	stop()
+
+	log.Printf("Shutting down, draining for %v", opts.Delay)
+	checker.SetDraining()
+	time.Sleep(opts.Delay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Requests still in flight after %v, closing their connections: %v", opts.Timeout, err)
+		srv.Close()
+		return err
+	}
+
+	if err := <-served; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Println("Shut down")
+	return nil
+}
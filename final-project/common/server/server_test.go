@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"drexel.edu/common/health"
+)
+
+// freeAddr is a port nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// start runs the handler like the APIs do, with GET /readyz and GET /slow,
+// which answers once release is closed or its request is cut off. It returns
+// once the server is ready, with the channel Run returns on.
+func start(t *testing.T, opts Options, started chan<- struct{}, release <-chan struct{}) <-chan error {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	checker := health.New("test-api", "v1", "")
+	r := gin.New()
+	r.GET("/readyz", checker.Readyz)
+	r.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			c.String(http.StatusOK, "done")
+		case <-c.Request.Context().Done():
+		}
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- Run(r, checker, opts) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for readyz(opts.Addr) != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatalf("the server on %v never got ready", opts.Addr)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return done
+}
+
+// readyz is the status of GET /readyz, 0 when the server doesn't answer
+func readyz(addr string) int {
+	resp, err := http.Get("http://" + addr + "/readyz")
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// stop sends the process SIGTERM, as docker compose down does
+func stop(t *testing.T) {
+	t.Helper()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// On SIGTERM GET /readyz fails for the Delay while the API still serves, and
+// then the requests in flight finish before Run returns
+func TestRunDrains(t *testing.T) {
+
+	var shutdowns atomic.Int64
+	opts := Options{
+		Addr:       freeAddr(t),
+		Delay:      300 * time.Millisecond,
+		Timeout:    5 * time.Second,
+		OnShutdown: []func(){func() { shutdowns.Add(1) }},
+	}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	done := start(t, opts, started, release)
+
+	inFlight := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + opts.Addr + "/slow")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = errors.New(resp.Status)
+			}
+		}
+		inFlight <- err
+	}()
+	<-started
+
+	stop(t)
+	deadline := time.Now().Add(opts.Delay)
+	for readyz(opts.Addr) != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("GET /readyz kept passing while draining")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the server is shutting down once the Delay is over, yet waits for /slow
+	time.Sleep(opts.Delay)
+	select {
+	case err := <-done:
+		t.Fatalf("Run() = %v before the request in flight finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if shutdowns.Load() != 1 {
+		t.Errorf("OnShutdown was called %v times, want 1", shutdowns.Load())
+	}
+
+	close(release)
+	if err := <-inFlight; err != nil {
+		t.Errorf("the request in flight failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return once the request in flight finished")
+	}
+	if status := readyz(opts.Addr); status != 0 {
+		t.Errorf("GET /readyz = %v after Run returned, want no answer", status)
+	}
+}
+
+// A request still in flight after the Timeout is cut off
+func TestRunTimeout(t *testing.T) {
+
+	opts := Options{Addr: freeAddr(t), Delay: 0, Timeout: 200 * time.Millisecond}
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	done := start(t, opts, started, release)
+
+	inFlight := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + opts.Addr + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		inFlight <- err
+	}()
+	<-started
+
+	stop(t)
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Run() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() didn't return after the Timeout")
+	}
+	if err := <-inFlight; err == nil {
+		t.Error("the request in flight finished, want its connection closed")
+	}
+}
+
+// Run returns right away when it can't listen
+func TestRunAddrInUse(t *testing.T) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	err = Run(http.NotFoundHandler(), health.New("test-api", "v1", ""), Options{Addr: l.Addr().String()})
+	if err == nil || errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("Run() = %v, want the listen error", err)
+	}
+}
@@ -0,0 +1,226 @@
+// Package tracing traces the requests handled by the Poll, Voter and Votes
+// APIs with OpenTelemetry: a span for every request, every call to another
+// API and every redis command. The W3C trace context (the traceparent
+// header) and the X-Request-ID are sent along with the calls to the other
+// APIs, so a Vote cast through the Votes API is a single trace across all
+// three. The spans are exported with OTLP (gRPC) to the collector at
+// OTEL_EXPORTER_OTLP_ENDPOINT.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"drexel.edu/common/requestid"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+// EnvEndpoint is the environment variable with the address of the OTLP
+// collector, e.g. otel-collector:4317 (or https://... for TLS)
+const EnvEndpoint = "OTEL_EXPORTER_OTLP_ENDPOINT"
+
+// RequestIDKey is the span attribute the request id is recorded in
+const RequestIDKey = label.Key("request.id")
+
+// instrumentationName is the name of the tracer the spans are started with
+const instrumentationName = "drexel.edu/common/tracing"
+
+// Init sets up tracing for the API service and returns the func that
+// flushes the spans that were not exported yet, to call before the process
+// exits. Without OTEL_EXPORTER_OTLP_ENDPOINT the spans are not exported, but
+// the trace context is still sent along with the calls to the other APIs.
+func Init(service string) (func(context.Context) error, error) {
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.ServiceNameKey.String(service))),
+	}
+
+	endpoint := os.Getenv(EnvEndpoint)
+	if endpoint == "" {
+		log.Println("Tracing: " + EnvEndpoint + " is not set, the spans are not exported")
+	} else {
+		exporter, err := otlp.NewExporter(context.Background(), exporterOptions(endpoint)...)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+		log.Println("Tracing: exporting the spans to " + endpoint)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// exporterOptions returns the options of an exporter to endpoint, which is
+// host:port, optionally prefixed with http:// or (for TLS) https://
+func exporterOptions(endpoint string) []otlp.ExporterOption {
+	if address := strings.TrimPrefix(endpoint, "https://"); address != endpoint {
+		return []otlp.ExporterOption{
+			otlp.WithAddress(address),
+			otlp.WithTLSCredentials(credentials.NewClientTLSFromCert(nil, "")),
+		}
+	}
+	return []otlp.ExporterOption{
+		otlp.WithAddress(strings.TrimPrefix(endpoint, "http://")),
+		otlp.WithInsecure(),
+	}
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Middleware starts a span for every request, continuing the trace of the
+// caller if it sent a traceparent header, and puts it in the context of the
+// request. It must come after the requestid Middleware.
+func Middleware(service string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(service, c.FullPath(), c.Request)...),
+			trace.WithAttributes(RequestIDKey.String(requestid.Get(c))),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
+
+//------------------------------------------------------------
+// CLIENT
+//------------------------------------------------------------
+
+// transport starts a span for every call it sends, and sends the trace
+// context and the request id along with it
+type transport struct {
+	next http.RoundTripper
+}
+
+// Transport returns an http.RoundTripper that traces the calls sent through
+// next. The calls must be made with the context of the request they are made
+// for, e.g. with resty's SetContext.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return &transport{next: next}
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	ctx, span := tracer().Start(r.Context(), r.Method+" "+r.URL.Host,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(r)...),
+	)
+	defer span.End()
+
+	// a RoundTripper must not change the request it is given
+	r = r.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, r.Header)
+	if id := requestid.FromContext(ctx); id != "" {
+		r.Header.Set(requestid.Header, id)
+		span.SetAttributes(RequestIDKey.String(id))
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode))
+	return resp, nil
+}
+
+//------------------------------------------------------------
+// REDIS
+//------------------------------------------------------------
+
+// RedisHook starts a span for every command run by the redis client it is
+// added to. Commands run outside of a traced request are not traced.
+type RedisHook struct{}
+
+var _ redis.Hook = RedisHook{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return startRedisSpan(ctx, "redis "+cmd.Name(), cmd.Name()), nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	endRedisSpan(ctx, cmd.Err())
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	return startRedisSpan(ctx, "redis pipeline", strings.Join(names, " ")), nil
+}
+
+func (RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil {
+			err = cmd.Err()
+		}
+	}
+	endRedisSpan(ctx, err)
+	return nil
+}
+
+// startRedisSpan starts a span for the commands, only their names are
+// recorded as the statement since their arguments are the documents
+func startRedisSpan(ctx context.Context, name string, statement string) context.Context {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ctx
+	}
+	ctx, _ = tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemRedis, semconv.DBStatementKey.String(statement)),
+	)
+	return ctx
+}
+
+func endRedisSpan(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
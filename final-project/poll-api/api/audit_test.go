@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"drexel.edu/common/audit"
+	"drexel.edu/poll-api/poll"
+)
+
+// Every change to a Poll is in the audit log with the Poll before and after
+// it, and a change that was refused is not
+func TestAuditLog(t *testing.T) {
+
+	r := newTestPollAPI(t, newFakeVotesAPI(t))
+	mustRequest(t, r, http.MethodPost, "/polls/1", `{"PollTitle": "Lunch"}`)
+	if w := request(r, http.MethodPost, "/polls/1", `{"PollTitle": "Lunch"}`); w.Code != http.StatusConflict {
+		t.Fatalf("adding the Poll again = %v, want 409", w.Code)
+	}
+	mustRequest(t, r, http.MethodPost, "/polls/2", `{"PollTitle": "Dinner"}`)
+	mustRequest(t, r, http.MethodPut, "/polls/1", `{"PollTitle": "Brunch"}`)
+	mustRequest(t, r, http.MethodDelete, "/polls/1", "")
+
+	w := mustRequest(t, r, http.MethodGet, "/admin/audit?entity=/polls/1", "")
+	var page audit.Page
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		action        string
+		before, after string
+	}{
+		{audit.ActionAdd, "", "Lunch"},
+		{audit.ActionUpdate, "Lunch", "Brunch"},
+		{audit.ActionDelete, "Brunch", ""},
+	}
+	if len(page.Items) != len(want) {
+		t.Fatalf("%v entries, want %v: %+v", len(page.Items), len(want), page.Items)
+	}
+
+	title := func(doc json.RawMessage) string {
+		if doc == nil {
+			return ""
+		}
+		var p poll.Poll
+		if err := json.Unmarshal(doc, &p); err != nil {
+			t.Fatal(err)
+		}
+		return p.PollTitle
+	}
+	for i, entry := range page.Items {
+		if entry.Entity != audit.EntityPoll || entry.Action != want[i].action {
+			t.Errorf("entry %v is a %v of a %v, want a %v of a %v", i, entry.Action, entry.Entity, want[i].action, audit.EntityPoll)
+		}
+		if before, after := title(entry.Before), title(entry.After); before != want[i].before || after != want[i].after {
+			t.Errorf("entry %v changed %q to %q, want %q to %q", i, before, after, want[i].before, want[i].after)
+		}
+		if entry.Actor != "anonymous" {
+			t.Errorf("entry %v was made by %v, want anonymous without authorization", i, entry.Actor)
+		}
+	}
+}
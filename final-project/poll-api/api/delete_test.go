@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDeletePollRejectOrCascade(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		path       string
+		votes      bool
+		wantStatus int
+		// wantPoll and wantOption say whether the Poll and its pollOption
+		// /polls/1/options/1 are left afterwards
+		wantPoll, wantOption bool
+		// wantVotes is how many of the Votes are left
+		wantVotes int
+	}{
+		{"a poll without votes", "/polls/1", false, http.StatusOK, false, false, 0},
+		{"a poll with votes is kept", "/polls/1", true, http.StatusConflict, true, true, 3},
+		{"a poll with votes is deleted with cascade", "/polls/1?cascade=true", true, http.StatusOK, false, false, 0},
+		{"an option without votes", "/polls/1/options/1", false, http.StatusOK, true, false, 0},
+		{"an option with votes is kept", "/polls/1/options/1", true, http.StatusConflict, true, true, 3},
+		{"an option with votes is deleted with cascade", "/polls/1/options/1?cascade=true", true, http.StatusOK, true, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			votes := newFakeVotesAPI(t)
+			r := newTestPollAPI(t, votes)
+			mustRequest(t, r, http.MethodPost, "/polls/1", `{"PollTitle": "Lunch", "PollType": "multi", "MaxSelections": 2}`)
+			mustRequest(t, r, http.MethodPost, "/polls/1/options/1", `{"PollOptions": [{"PollOptionText": "Pizza"}]}`)
+			mustRequest(t, r, http.MethodPost, "/polls/1/options/2", `{"PollOptions": [{"PollOptionText": "Tacos"}]}`)
+			if tt.votes {
+				votes.cast("/votes/1", "/polls/1", "/polls/1/options/1", "/polls/1/options/2")
+				votes.cast("/votes/2", "/polls/1", "/polls/1/options/1")
+				votes.cast("/votes/3", "/polls/1", "/polls/1/options/2")
+			}
+
+			if w := request(r, http.MethodDelete, tt.path, ""); w.Code != tt.wantStatus {
+				t.Fatalf("DELETE %v = %v, want %v: %v", tt.path, w.Code, tt.wantStatus, w.Body)
+			}
+
+			if got := request(r, http.MethodGet, "/polls/1", "").Code == http.StatusOK; got != tt.wantPoll {
+				t.Errorf("the Poll is left = %v, want %v", got, tt.wantPoll)
+			}
+			if got := request(r, http.MethodGet, "/polls/1/options/1", "").Code == http.StatusOK; got != tt.wantOption {
+				t.Errorf("the pollOption is left = %v, want %v", got, tt.wantOption)
+			}
+			votes.mu.Lock()
+			left := len(votes.votes)
+			votes.mu.Unlock()
+			if left != tt.wantVotes {
+				t.Errorf("%v Votes are left, want %v", left, tt.wantVotes)
+			}
+		})
+	}
+}
+
+// A Vote cast in between the check for Votes and the deletion is caught by
+// the check after it, which puts the Poll back
+func TestDeletePollVoteCastDuringTheDeletion(t *testing.T) {
+
+	votes := newFakeVotesAPI(t)
+	r := newTestPollAPI(t, votes)
+	mustRequest(t, r, http.MethodPost, "/polls/1", `{"PollTitle": "Lunch"}`)
+	mustRequest(t, r, http.MethodPost, "/polls/1/options/1", `{"PollOptions": [{"PollOptionText": "Pizza"}]}`)
+
+	votes.mu.Lock()
+	votes.afterCount = func() {
+		votes.castLocked("/votes/1", "/polls/1", "/polls/1/options/1")
+		votes.afterCount = nil
+	}
+	votes.mu.Unlock()
+
+	if w := request(r, http.MethodDelete, "/polls/1", ""); w.Code != http.StatusConflict {
+		t.Fatalf("DELETE /polls/1 = %v, want 409: %v", w.Code, w.Body)
+	}
+	if w := request(r, http.MethodGet, "/polls/1/options/1", ""); w.Code != http.StatusOK {
+		t.Errorf("the Poll was not put back with its pollOption: %v %v", w.Code, w.Body)
+	}
+	if votes.counts != 2 || votes.deletes != 0 {
+		t.Errorf("%v counts and %v deletions of Votes, want the 2 counts only", votes.counts, votes.deletes)
+	}
+}
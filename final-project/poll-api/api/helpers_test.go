@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"drexel.edu/client"
+	"drexel.edu/common/docstore"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeVotesAPI stands in for the Votes API: it counts the Votes cast in a
+// Poll or that select a pollOption, and deletes them
+type fakeVotesAPI struct {
+	mu sync.Mutex
+	// votes are the VoteValues of each VoteID
+	votes map[string][]string
+	// pollOf is the PollID of each VoteID
+	pollOf map[string]string
+	// afterCount, if set, runs after every count, under mu
+	afterCount func()
+	counts     int
+	deletes    int
+
+	server *httptest.Server
+}
+
+func newFakeVotesAPI(t *testing.T) *fakeVotesAPI {
+	f := &fakeVotesAPI{votes: make(map[string][]string), pollOf: make(map[string]string)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeVotesAPI) cast(voteID, pollID string, optionIDs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.castLocked(voteID, pollID, optionIDs...)
+}
+
+func (f *fakeVotesAPI) castLocked(voteID, pollID string, optionIDs ...string) {
+	f.votes[voteID] = optionIDs
+	f.pollOf[voteID] = pollID
+}
+
+// matching returns the VoteIDs of the Votes cast in the Poll, or that select
+// the pollOption, id
+func (f *fakeVotesAPI) matching(id string) []string {
+	var voteIDs []string
+	for voteID, optionIDs := range f.votes {
+		if f.pollOf[voteID] == id {
+			voteIDs = append(voteIDs, voteID)
+			continue
+		}
+		for _, optionID := range optionIDs {
+			if optionID == id {
+				voteIDs = append(voteIDs, voteID)
+				break
+			}
+		}
+	}
+	return voteIDs
+}
+
+func (f *fakeVotesAPI) serve(w http.ResponseWriter, r *http.Request) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if id, ok := strings.CutSuffix(r.URL.Path, "/votes/count"); ok && r.Method == http.MethodGet {
+		f.counts++
+		json.NewEncoder(w).Encode(client.VoteCount{Count: len(f.matching(strings.TrimPrefix(id, "/votes")))})
+		if f.afterCount != nil {
+			f.afterCount()
+		}
+		return
+	}
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.deletes++
+
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/votes"), "/votes")
+	voteIDs := f.matching(id)
+
+	votes := make([]client.Vote, 0, len(voteIDs))
+	for _, voteID := range voteIDs {
+		votes = append(votes, client.Vote{VoteID: voteID, PollID: f.pollOf[voteID], VoteValues: f.votes[voteID]})
+		delete(f.votes, voteID)
+		delete(f.pollOf, voteID)
+	}
+	json.NewEncoder(w).Encode(votes)
+}
+
+// newTestPollAPI returns an in-memory PollAPI that asks f about Votes, and a
+// router with its routes, without authorization
+func newTestPollAPI(t *testing.T, f *fakeVotesAPI) *gin.Engine {
+
+	gin.SetMode(gin.TestMode)
+
+	p, err := NewPollApi(docstore.BackendMemory, nil, f.server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/polls/:id", p.GetPoll)
+	r.POST("/polls/:id", p.AddPoll)
+	r.GET("/polls/:id/options/:optionid", p.GetPollOption)
+	r.POST("/polls/:id/options/:optionid", p.AddPollOption)
+	r.PUT("/polls/:id", p.UpdatePoll)
+	r.PUT("/polls/:id/options/:optionid", p.UpdatePollOption)
+	r.DELETE("/polls/:id", p.DeletePoll)
+	r.DELETE("/polls/:id/options/:optionid", p.DeletePollOption)
+	r.GET("/admin/audit", p.GetAuditLog)
+	return r
+}
+
+func request(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// mustRequest fails the test unless the request succeeds
+func mustRequest(t *testing.T, r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := request(r, method, path, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%v %v = %v: %v", method, path, w.Code, w.Body)
+	}
+	return w
+}
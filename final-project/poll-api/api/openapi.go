@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+
+	"drexel.edu/common/audit"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/openapi"
+	"drexel.edu/poll-api/poll"
+)
+
+// OpenAPI returns the OpenAPI document of the Poll API, with an operation for
+// every route registered in main.go
+func OpenAPI(version string) *openapi.Document {
+
+	doc := openapi.New("Poll API", version,
+		"The Polls and their PollOptions. The ID of a Poll is its path, e.g. /polls/1, and the ID of a pollOption is its path too, e.g. /polls/1/options/2.")
+
+	pollManager := openapi.Require(auth.RolePollManager)
+	admin := openapi.Require()
+	pollOptions := poll.Poll{}.PollOptions
+	cascade := openapi.Parameter{Name: "cascade", Type: "boolean", Description: "Also delete the Votes, without it what has Votes is not deleted."}
+
+	doc.Add(http.MethodGet, "/polls", openapi.Operation{
+		ID:          "getAllPolls",
+		Summary:     "Every Poll, or a page of them",
+		Description: "Without cursor and limit every Poll is returned, with either of them a PollPage.",
+		Tag:         "polls",
+		Query:       openapi.PageParams,
+		Response:    openapi.OneOf([]poll.Poll{}, PollPage{}),
+	})
+	doc.Add(http.MethodGet, "/polls/:id", openapi.Operation{
+		ID:       "getPoll",
+		Summary:  "A Poll",
+		Tag:      "polls",
+		Response: poll.Poll{},
+	})
+	doc.Add(http.MethodPost, "/polls/:id", openapi.Operation{
+		ID:          "addPoll",
+		Summary:     "Adds a Poll",
+		Description: "The PollID is taken from the path and the PollOptions are ignored, they are added one at a time.",
+		Tag:         "polls",
+		Auth:        pollManager,
+		Body:        poll.Poll{},
+	})
+	doc.Add(http.MethodGet, "/polls/:id/options", openapi.Operation{
+		ID:       "getPollOptions",
+		Summary:  "The PollOptions of a Poll",
+		Tag:      "poll options",
+		Response: pollOptions,
+	})
+	doc.Add(http.MethodGet, "/polls/:id/options/:optionid", openapi.Operation{
+		ID:       "getPollOption",
+		Summary:  "A pollOption of a Poll",
+		Tag:      "poll options",
+		Response: openapi.ItemOf(pollOptions),
+	})
+	doc.Add(http.MethodPost, "/polls/:id/options/:optionid", openapi.Operation{
+		ID:          "addPollOption",
+		Summary:     "Adds a pollOption to a Poll",
+		Description: "The pollOption is the only one of the PollOptions of the body, its PollOptionID is taken from the path. The other fields of the Poll are ignored.",
+		Tag:         "poll options",
+		Auth:        pollManager,
+		Body:        poll.Poll{},
+	})
+	doc.Add(http.MethodPost, "/polls/:id/open", openapi.Operation{
+		ID:       "openPoll",
+		Summary:  "Opens a Poll so that Votes can be cast in it",
+		Tag:      "polls",
+		Auth:     pollManager,
+		Response: poll.Poll{},
+	})
+	doc.Add(http.MethodPost, "/polls/:id/close", openapi.Operation{
+		ID:       "closePoll",
+		Summary:  "Closes a Poll so that no more Votes can be cast in it",
+		Tag:      "polls",
+		Auth:     pollManager,
+		Response: poll.Poll{},
+	})
+	doc.Add(http.MethodPut, "/polls/:id", openapi.Operation{
+		ID:          "updatePoll",
+		Summary:     "Changes the PollTitle and/or PollQuestion of a Poll",
+		Description: "The fields that are left out are not changed, and the other fields are ignored.",
+		Tag:         "polls",
+		Auth:        pollManager,
+		Body:        poll.Poll{},
+		Response:    poll.Poll{},
+	})
+	doc.Add(http.MethodPut, "/polls/:id/options/:optionid", openapi.Operation{
+		ID:          "updatePollOption",
+		Summary:     "Changes the PollOptionText of a pollOption",
+		Description: "The pollOption is the only one of the PollOptions of the body. A pollOption that has Votes is only changed with force.",
+		Tag:         "poll options",
+		Auth:        pollManager,
+		Query:       []openapi.Parameter{{Name: "force", Type: "boolean", Description: "Change the pollOption even if it has Votes."}},
+		Body:        poll.Poll{},
+		Response:    openapi.ItemOf(pollOptions),
+	})
+
+	doc.AddHealth("/polls/health")
+
+	doc.Add(http.MethodDelete, "/polls/:id", openapi.Operation{
+		ID:      "deletePoll",
+		Summary: "Deletes a Poll",
+		Tag:     "polls",
+		Auth:    admin,
+		Query:   []openapi.Parameter{cascade},
+	})
+	doc.Add(http.MethodDelete, "/polls/:id/options/:optionid", openapi.Operation{
+		ID:      "deletePollOption",
+		Summary: "Deletes a pollOption of a Poll",
+		Tag:     "poll options",
+		Auth:    admin,
+		Query:   []openapi.Parameter{cascade},
+	})
+
+	doc.Add(http.MethodGet, "/admin/export", openapi.Operation{
+		ID:       "exportPolls",
+		Summary:  "A snapshot of every Poll",
+		Tag:      "admin",
+		Auth:     admin,
+		Response: docstore.Snapshot[poll.Poll]{},
+	})
+	doc.Add(http.MethodPost, "/admin/import", openapi.Operation{
+		ID:          "importPolls",
+		Summary:     "Adds every Poll of a snapshot",
+		Description: "The Polls with the same PollID are overwritten.",
+		Tag:         "admin",
+		Auth:        admin,
+		Body:        docstore.Snapshot[poll.Poll]{},
+		Response:    docstore.ImportResult{},
+	})
+	doc.Add(http.MethodGet, "/admin/audit", openapi.Operation{
+		ID:       "getAuditLog",
+		Summary:  "A page of the audit log, oldest first",
+		Tag:      "admin",
+		Auth:     admin,
+		Query:    openapi.AuditParams,
+		Response: audit.Page{},
+	})
+
+	return doc
+}
@@ -1,37 +1,116 @@
 package api
 
 import (
+	"context"
 	"fmt"
-	"log"
+
 	"net/http"
 	"regexp"
-
+	"strconv"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/audit"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/changes"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/health"
+	"drexel.edu/common/paging"
+	"drexel.edu/common/requestid"
 	"drexel.edu/poll-api/poll"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 type PollAPI struct {
 	pollList *poll.PollList
+	audit    audit.Log
+	checks   []health.Check
+	changes  changes.Publisher
+	nonces   auth.Nonces
+	client   *redis.Client
 }
 
-func NewPollApi() (*PollAPI, error) {
-	pollListHandler, err := poll.New()
+// PollPage is one page of GET /polls?cursor=&limit=, Next is the link to the
+// following page and is left out on the last page
+type PollPage struct {
+	Items []poll.Poll
+	Next  string `json:",omitempty"`
+}
+
+// NewPollApi returns a PollAPI that keeps its Polls and its audit log in the
+// store backend, docstore.BackendRedis (connected to with redisOptions) or
+// docstore.BackendMemory
+func NewPollApi(store string, redisOptions *redis.Options, votesAPIurl string) (*PollAPI, error) {
+	if store == docstore.BackendMemory {
+		return &PollAPI{pollList: poll.NewInMemory(votesAPIurl), audit: audit.NewMemory(), changes: changes.NewNone(), nonces: auth.NewMemoryNonces()}, nil
+	}
+
+	client, jsonHelper, err := docstore.Connect(redisOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PollAPI{pollList: pollListHandler}, nil
+	return &PollAPI{
+		pollList: poll.NewWithStore(docstore.NewRedis[poll.Poll](client, jsonHelper, poll.RedisKeyPrefix), votesAPIurl),
+		audit:    audit.NewRedis(client),
+		checks:   []health.Check{health.Redis(client)},
+		changes:  changes.NewRedis(client),
+		nonces:   auth.NewRedisNonces(client),
+		client:   client,
+	}, nil
+}
+
+// HealthChecks returns the checks of the dependencies of the PollAPI for
+// GET /readyz, redis unless the Polls are kept in memory
+func (p *PollAPI) HealthChecks() []health.Check {
+	return p.checks
+}
+
+// Nonces returns where the nonces of the signed requests are remembered, in
+// the same store as the Polls
+func (p *PollAPI) Nonces() auth.Nonces {
+	return p.nonces
+}
+
+// Close closes the redis client of the PollAPI, once it has stopped serving
+// requests
+func (p *PollAPI) Close() error {
+	if p.client == nil {
+		return nil
+	}
+	return p.client.Close()
 }
 
 // THE API FUNCTIONS
 
 // implementation for GET /polls
-// returns all Polls
+// returns all Polls, or with ?limit= and/or ?cursor= a PollPage
 func (p *PollAPI) GetAllPolls(c *gin.Context) {
-	polls, err := p.pollList.GetAllPolls()
+
+	ctx := c.Request.Context()
+
+	paged, cursor, limit, err := paging.Params(c)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error reading the page parameters: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	if paged {
+		polls, next, err := p.pollList.GetPolls(ctx, cursor, limit)
+		if err != nil {
+			requestid.Logger(ctx).Println("Error getting a page of Polls: ", err)
+			apierror.Abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, PollPage{Items: polls, Next: paging.NextLink(c.Request.URL.Path, next, limit)})
+		return
+	}
+
+	polls, err := p.pollList.GetAllPolls(ctx)
 	if err != nil {
-		log.Println("Error getting all Polls: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println("Error getting all Polls: ", err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -46,12 +125,14 @@ func (p *PollAPI) GetAllPolls(c *gin.Context) {
 // returns a single Poll
 func (p *PollAPI) GetPoll(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	idS := c.Request.URL.String()
 
-	poll, err := p.pollList.GetPoll(idS)
+	poll, err := p.pollList.GetPoll(ctx, idS)
 	if err != nil {
-		log.Println(fmt.Sprintf("Poll with the ID %v not found: ", idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Poll with the ID %v not found: ", idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -65,18 +146,20 @@ func (p *PollAPI) GetPoll(c *gin.Context) {
 // if the user includes PollID in the JSON it is simply overridden by the URL
 func (p *PollAPI) AddPoll(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	var poll poll.Poll
 	if err := c.ShouldBindJSON(&poll); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
 		return
 	}
 
 	poll.PollID = c.Request.URL.String()
 
-	if err := p.pollList.AddPoll(poll); err != nil {
-		log.Println(fmt.Sprintf("Error adding Poll with the ID %v: ", poll.PollID), err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := p.audited(c, audit.ActionAdd, poll.PollID, func() error { return p.pollList.AddPoll(ctx, poll) }); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error adding Poll with the ID %v: ", poll.PollID), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -87,14 +170,16 @@ func (p *PollAPI) AddPoll(c *gin.Context) {
 // returns the poll options (PollOptions) for the Poll with ID id
 func (p *PollAPI) GetPollOptions(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re := regexp.MustCompile(`^/polls/\d+`)
 	idS := string(re.Find([]byte(url)))
 
-	poll, err := p.pollList.GetPoll(idS)
+	poll, err := p.pollList.GetPoll(ctx, idS)
 	if err != nil {
-		log.Println(fmt.Sprintf("Poll with the ID %v not found: ", idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Poll with the ID %v not found: ", idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -105,16 +190,18 @@ func (p *PollAPI) GetPollOptions(c *gin.Context) {
 // returns the poll option (pollOption) for the Poll with ID id for pollOption optionid
 func (v *PollAPI) GetPollOption(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re_id := regexp.MustCompile(`^/polls/\d+`)
 	idS := string(re_id.Find([]byte(url)))
 
 	optionidS := url
 
-	poll, err := v.pollList.GetPollOption(idS, optionidS)
+	poll, err := v.pollList.GetPollOption(ctx, idS, optionidS)
 	if err != nil {
-		log.Println(fmt.Sprintf("Error finding PollOptionID %v in Poll %v's PollOptions: ", optionidS, idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error finding PollOptionID %v in Poll %v's PollOptions: ", optionidS, idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -130,6 +217,8 @@ func (v *PollAPI) GetPollOption(c *gin.Context) {
 // simply overridden by the PollOptionID in the URL
 func (v *PollAPI) AddPollOption(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re_id := regexp.MustCompile(`^/polls/\d+`)
 	idS := string(re_id.Find([]byte(url)))
@@ -141,41 +230,89 @@ func (v *PollAPI) AddPollOption(c *gin.Context) {
 
 	var poll poll.Poll
 	if err := c.ShouldBindJSON(&poll); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
 		return
 	}
 
-	if err := v.pollList.AddPollOption(idS, optionidS, poll); err != nil {
-		log.Println("Error adding pollOption: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := v.audited(c, audit.ActionUpdate, idS, func() error { return v.pollList.AddPollOption(ctx, idS, optionidS, poll) }); err != nil {
+		requestid.Logger(ctx).Println("Error adding pollOption: ", err)
+		apierror.Abort(c, err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
-// implementation of GET /voters/health
-// returns the health of the Voter-API Application
-func (v *PollAPI) GetHealth(c *gin.Context) {
-	c.JSON(http.StatusOK,
-		gin.H{
-			"status":  "ok",
-			"version": "1.0.0",
-		})
+// implementation for POST /polls/:id/open
+// opens the Poll so that Votes can be cast in it, and returns the Poll
+func (p *PollAPI) OpenPoll(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	url := c.Request.URL.String()
+	re := regexp.MustCompile(`^/polls/\d+`)
+	idS := string(re.Find([]byte(url)))
+
+	var poll poll.Poll
+	err := p.audited(c, audit.ActionUpdate, idS, func() (err error) {
+		poll, err = p.pollList.OpenPoll(ctx, idS)
+		return err
+	})
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error opening Poll %v: ", idS), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, poll)
+}
+
+// implementation for POST /polls/:id/close
+// closes the Poll so that no more Votes can be cast in it, and returns the Poll
+func (p *PollAPI) ClosePoll(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	url := c.Request.URL.String()
+	re := regexp.MustCompile(`^/polls/\d+`)
+	idS := string(re.Find([]byte(url)))
+
+	var poll poll.Poll
+	err := p.audited(c, audit.ActionUpdate, idS, func() (err error) {
+		poll, err = p.pollList.ClosePoll(ctx, idS)
+		return err
+	})
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error closing Poll %v: ", idS), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, poll)
 }
 
 // Extra Credit Handlers
 
 // implementation for DELETE /polls/:pollid
-// deletes a Poll
+// deletes a Poll, a Poll with Votes is only deleted with ?cascade=true,
+// which deletes its Votes as well
 func (v *PollAPI) DeletePoll(c *gin.Context) {
 
-	idS := c.Request.URL.String()
+	ctx := c.Request.Context()
 
-	if err := v.pollList.DeletePoll(idS); err != nil {
-		log.Println(fmt.Sprintf("Error deleting Polls with ID %v: ", idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+	idS := c.Request.URL.Path
+
+	cascade, err := boolQuery(c, "cascade")
+	if err != nil {
+		requestid.Logger(ctx).Println("Error parsing cascade: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	if err := v.audited(c, audit.ActionDelete, idS, func() error { return v.pollList.DeletePoll(ctx, idS, cascade) }); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error deleting Polls with ID %v: ", idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -183,53 +320,231 @@ func (v *PollAPI) DeletePoll(c *gin.Context) {
 }
 
 // implementation for DELETE /polls/:pollid/options/:optionid
-// deletes pollOption for the Poll
+// deletes pollOption for the Poll, a pollOption with Votes is only deleted
+// with ?cascade=true, which deletes the Votes that select it as well
 func (v *PollAPI) DeletePollOption(c *gin.Context) {
 
-	url := c.Request.URL.String()
+	ctx := c.Request.Context()
+
+	url := c.Request.URL.Path
 	re_id := regexp.MustCompile(`^/polls/\d+`)
 	pollidS := string(re_id.Find([]byte(url)))
 
 	optionidS := url
 
-	err := v.pollList.DeletePollOption(pollidS, optionidS)
+	cascade, err := boolQuery(c, "cascade")
+	if err != nil {
+		requestid.Logger(ctx).Println("Error parsing cascade: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	err = v.audited(c, audit.ActionUpdate, pollidS, func() error {
+		return v.pollList.DeletePollOption(ctx, pollidS, optionidS, cascade)
+	})
 	if err != nil {
-		log.Println(fmt.Sprintf("Error deleting pollOption %v from Poll %v's: ", optionidS, pollidS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error deleting pollOption %v from Poll %v's: ", optionidS, pollidS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
-// // implementation for PUT /voters/:id/polls/:pollid
-// // updates a voterPoll, specifically its VoteDate, of the Voter with ID id
-// // only one voterPoll is allowed to be updated at a time
-// // any data in the Voter fields outside of VoteHistory will be ignored because
-// // voter.VoterID and voterPoll.PollID fields are redundant (they are included
-// // in the URL), if the user includes either of them in the JSON, they are overridden
-// func (v *PollAPI) UpdatePollData(c *gin.Context) {
+// implementation for PUT /polls/:id
+// updates a Poll's PollTitle and/or PollQuestion, the fields that are left
+// out are not changed, and any other fields are ignored
+func (v *PollAPI) UpdatePoll(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	var poll poll.Poll
+	if err := c.ShouldBindJSON(&poll); err != nil {
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
+		return
+	}
+
+	poll.PollID = c.Request.URL.Path
+
+	updated := poll
+	err := v.audited(c, audit.ActionUpdate, poll.PollID, func() (err error) {
+		updated, err = v.pollList.UpdatePoll(ctx, poll)
+		return err
+	})
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error updating Poll with the ID %v: ", poll.PollID), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// implementation for PUT /polls/:id/options/:optionid
+// updates the PollOptionText of a pollOption of the Poll with ID id
+// only one pollOption is allowed to be updated at a time, and like
+// AddPollOption any fields outside of PollOptions are ignored and the
+// PollOptionID is taken from the URL
+// a pollOption that already has Votes is only updated with ?force=true
+func (v *PollAPI) UpdatePollOption(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	url := c.Request.URL.Path
+	re_id := regexp.MustCompile(`^/polls/\d+`)
+	idS := string(re_id.Find([]byte(url)))
+
+	optionidS := url
+
+	force, err := boolQuery(c, "force")
+	if err != nil {
+		requestid.Logger(ctx).Println("Error parsing force: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	var poll poll.Poll
+	if err := c.ShouldBindJSON(&poll); err != nil {
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
+		return
+	}
+
+	var option any
+	err = v.audited(c, audit.ActionUpdate, idS, func() (err error) {
+		option, err = v.pollList.UpdatePollOption(ctx, idS, optionidS, poll, force)
+		return err
+	})
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error updating pollOption %v in Poll %v: ", optionidS, idS), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, option)
+}
+
+// implementation for GET /admin/export
+// returns a snapshot of every Poll, which can be restored with
+// POST /admin/import
+func (p *PollAPI) ExportPolls(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	snapshot, err := p.Export(ctx)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error exporting Polls: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// implementation for POST /admin/import
+// adds every Poll in a snapshot from GET /admin/export, overwriting the
+// Polls with the same PollID
+func (p *PollAPI) ImportPolls(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	var snapshot docstore.Snapshot[poll.Poll]
+	if err := c.ShouldBindJSON(&snapshot); err != nil {
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
+		return
+	}
+
+	imported, err := p.Import(ctx, snapshot, audit.ActorFrom(c))
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error importing Polls, %v were imported: ", imported), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, docstore.ImportResult{Kind: snapshot.Kind, Imported: imported})
+}
+
+// implementation for GET /admin/audit
+// returns a page of the audit log, see audit.Handler for the query
+// parameters
+func (p *PollAPI) GetAuditLog(c *gin.Context) {
+	audit.Handler(p.audit)(c)
+}
+
+// Export returns a snapshot of every Poll, for GET /admin/export and the
+// export command
+func (p *PollAPI) Export(ctx context.Context) (docstore.Snapshot[poll.Poll], error) {
+	return p.pollList.Export(ctx)
+}
+
+// Empty reports whether there are no Polls at all, the snapshot of -restore
+// is only imported then
+func (p *PollAPI) Empty(ctx context.Context) (bool, error) {
+	polls, _, err := p.pollList.GetPolls(ctx, 0, 1)
+	return len(polls) == 0, err
+}
+
+// Import adds every Poll in the snapshot, for POST /admin/import and the
+// import command, and records the imported Polls as imported by actor
+func (p *PollAPI) Import(ctx context.Context, snapshot docstore.Snapshot[poll.Poll], actor audit.Actor) (int, error) {
 
-// 	url := c.Request.URL.String()
-// 	re_id := regexp.MustCompile(`^/voters/\d+`)
-// 	idS := string(re_id.Find([]byte(url)))
-// 	re_pollid := regexp.MustCompile(`/polls/\d+$`)
-// 	pollidS := string(re_pollid.Find([]byte(url)))
+	before := make([]any, len(snapshot.Items))
+	for i, item := range snapshot.Items {
+		if existing, err := p.pollList.GetPoll(ctx, item.PollID); err == nil {
+			before[i] = existing
+		}
+	}
+
+	imported, err := p.pollList.Import(ctx, snapshot)
+	for i := 0; i < imported; i++ {
+		audit.Record(ctx, p.audit, actor, audit.EntityPoll, snapshot.Items[i].PollID, audit.ActionImport, before[i], snapshot.Items[i])
+		p.publish(ctx, snapshot.Items[i].PollID)
+	}
+
+	return imported, err
+}
+
+// audited makes the change to the Poll pollID and records it in the audit
+// log, along with the Poll before and after the change, and publishes it
+func (p *PollAPI) audited(c *gin.Context, action string, pollID string, change func() error) error {
+
+	ctx := c.Request.Context()
+
+	var before, after any
+	if poll, err := p.pollList.GetPoll(ctx, pollID); err == nil {
+		before = poll
+	}
 
-// 	var voter voter.Voter
+	if err := change(); err != nil {
+		return err
+	}
 
-// 	if err := c.ShouldBindJSON(&voter); err != nil {
-// 		log.Println("Error binding JSON: ", err)
-// 		c.AbortWithStatus(http.StatusBadRequest)
-// 		return
-// 	}
+	if poll, err := p.pollList.GetPoll(ctx, pollID); err == nil {
+		after = poll
+	}
+	audit.Record(ctx, p.audit, audit.ActorFrom(c), audit.EntityPoll, pollID, action, before, after)
+	p.publish(ctx, pollID)
 
-// 	if err := v.pollList.UpdatePollData(idS, pollidS, voter); err != nil {
-// 		log.Println(fmt.Sprintf("Error updating poll in Voter %v's history: ", idS), err)
-// 		c.AbortWithStatus(http.StatusInternalServerError)
-// 		return
-// 	}
+	return nil
+}
 
-// 	c.Status(http.StatusOK)
+// publish tells the Votes API that the Poll pollID changed, so that it drops
+// its cached copy
+func (p *PollAPI) publish(ctx context.Context, pollID string) {
+	if err := p.changes.Publish(ctx, audit.EntityPoll, pollID); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error publishing the change to Poll %v: ", pollID), err)
+	}
+}
 
-// }
+// boolQuery returns the value of the query parameter name, which is false
+// when it is left out
+func boolQuery(c *gin.Context, name string) (bool, error) {
+
+	value, err := strconv.ParseBool(c.DefaultQuery(name, "false"))
+	if err != nil {
+		return false, apierror.Validation(name, "%v must be true or false, %q given.", name, c.Query(name))
+	}
+	return value, nil
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"drexel.edu/poll-api/poll"
+)
+
+func TestUpdatePoll(t *testing.T) {
+
+	r := newTestPollAPI(t, newFakeVotesAPI(t))
+	mustRequest(t, r, http.MethodPost, "/polls/1", `{"PollTitle": "Lunch", "PollQuestion": "Where to?"}`)
+
+	// only the fields given change, PollType and the rest are ignored
+	w := mustRequest(t, r, http.MethodPut, "/polls/1", `{"PollTitle": "Dinner", "PollType": "ranked", "Status": "closed"}`)
+
+	var updated poll.Poll
+	if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.PollTitle != "Dinner" || updated.PollQuestion != "Where to?" {
+		t.Errorf("PollTitle, PollQuestion = %q, %q, want Dinner, Where to?", updated.PollTitle, updated.PollQuestion)
+	}
+	if updated.PollType != poll.PollTypeSingle || updated.Status != poll.PollStatusOpen {
+		t.Errorf("PollType, Status = %v, %v, want them unchanged", updated.PollType, updated.Status)
+	}
+
+	if w := request(r, http.MethodPut, "/polls/2", `{"PollTitle": "Dinner"}`); w.Code != http.StatusNotFound {
+		t.Errorf("updating an unknown Poll = %v, want 404", w.Code)
+	}
+}
+
+func TestUpdatePollOption(t *testing.T) {
+
+	r := newTestPollAPI(t, newFakeVotesAPI(t))
+	mustRequest(t, r, http.MethodPost, "/polls/1", `{"PollTitle": "Lunch"}`)
+	mustRequest(t, r, http.MethodPost, "/polls/1/options/1", `{"PollOptions": [{"PollOptionText": "Pizza"}]}`)
+
+	tests := []struct {
+		name       string
+		path, body string
+		wantStatus int
+	}{
+		{"two options at once", "/polls/1/options/1", `{"PollOptions": [{"PollOptionText": "A"}, {"PollOptionText": "B"}]}`, http.StatusBadRequest},
+		{"no option", "/polls/1/options/1", `{"PollOptions": []}`, http.StatusBadRequest},
+		{"an unknown option", "/polls/1/options/2", `{"PollOptions": [{"PollOptionText": "Tacos"}]}`, http.StatusNotFound},
+		{"an unknown poll", "/polls/2/options/1", `{"PollOptions": [{"PollOptionText": "Tacos"}]}`, http.StatusNotFound},
+		{"force that isn't a bool", "/polls/1/options/1?force=yes", `{"PollOptions": [{"PollOptionText": "Tacos"}]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := request(r, http.MethodPut, tt.path, tt.body); w.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v: %v", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+
+	// the PollOptionID comes from the path, not the body
+	mustRequest(t, r, http.MethodPut, "/polls/1/options/1", `{"PollOptions": [{"PollOptionID": "/polls/1/options/9", "PollOptionText": "Sushi"}]}`)
+	w := mustRequest(t, r, http.MethodGet, "/polls/1/options/1", "")
+	var option struct{ PollOptionID, PollOptionText string }
+	if err := json.Unmarshal(w.Body.Bytes(), &option); err != nil {
+		t.Fatal(err)
+	}
+	if option.PollOptionID != "/polls/1/options/1" || option.PollOptionText != "Sushi" {
+		t.Errorf("pollOption = %+v, want /polls/1/options/1 Sushi", option)
+	}
+}
+
+// A pollOption that Votes select only changes with ?force=true, so that the
+// meaning of those Votes doesn't change unnoticed
+func TestUpdatePollOptionWithVotes(t *testing.T) {
+
+	votes := newFakeVotesAPI(t)
+	r := newTestPollAPI(t, votes)
+	mustRequest(t, r, http.MethodPost, "/polls/1", `{"PollTitle": "Lunch"}`)
+	mustRequest(t, r, http.MethodPost, "/polls/1/options/1", `{"PollOptions": [{"PollOptionText": "Pizza"}]}`)
+	mustRequest(t, r, http.MethodPost, "/polls/1/options/2", `{"PollOptions": [{"PollOptionText": "Tacos"}]}`)
+	votes.cast("/votes/1", "/polls/1", "/polls/1/options/1")
+
+	if w := request(r, http.MethodPut, "/polls/1/options/1", `{"PollOptions": [{"PollOptionText": "Sushi"}]}`); w.Code != http.StatusConflict {
+		t.Errorf("updating a pollOption with Votes = %v, want 409: %v", w.Code, w.Body)
+	}
+	mustRequest(t, r, http.MethodPut, "/polls/1/options/1?force=true", `{"PollOptions": [{"PollOptionText": "Sushi"}]}`)
+	mustRequest(t, r, http.MethodPut, "/polls/1/options/2", `{"PollOptions": [{"PollOptionText": "Burritos"}]}`)
+}
@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag poll-api:v1  -f ./dockerfile .
\ No newline at end of file
+docker build --build-arg COMMIT=$(git rev-parse --short HEAD) --tag poll-api:v1  -f ./dockerfile ..
\ No newline at end of file
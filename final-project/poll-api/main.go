@@ -1,72 +1,159 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
 	"os"
-
+	"time"
+
+	"drexel.edu/common/auth"
+	"drexel.edu/common/config"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/health"
+	"drexel.edu/common/metrics"
+	"drexel.edu/common/openapi"
+	"drexel.edu/common/requestid"
+	"drexel.edu/common/resilience"
+	"drexel.edu/common/server"
+	"drexel.edu/common/tracing"
 	"drexel.edu/poll-api/api"
+	"drexel.edu/poll-api/poll"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// Global variables to hold the command line flags to drive the todo CLI
-// application
+// version and commit are reported by GET /livez and GET /readyz, the
+// dockerfile sets them with -ldflags "-X main.version=... -X main.commit=..."
 var (
-	hostFlag string
-	portFlag uint
+	version = "1.0.0"
+	commit  = ""
 )
 
-// processCmdLineFlags parses the command line flags for our CLI
-
-func processCmdLineFlags() {
-
-	//Note some networking lingo, some frameworks start the server on localhost
-	//this is a local-only interface and is fine for testing but its not accessible
-	//from other machines.  To make the server accessible from other machines, we
-	//need to listen on an interface, that could be an IP address, but modern
-	//cloud servers may have multiple network interfaces for scale.  With TCP/IP
-	//the address 0.0.0.0 instructs the network stack to listen on all interfaces
-	//We set this up as a flag so that we can overwrite it on the command line if
-	//needed
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 2080, "Default Port")
-
-	flag.Parse()
-}
-
 // main is the entry point for our todo API application.  It processes
 // the command line flags and then uses the db package to perform the
 // requested operation
 func main() {
-	processCmdLineFlags()
-	r := gin.Default()
-	r.Use(cors.Default())
+	cfg, err := config.Load(auth.ServicePollAPI)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	resilience.DefaultOptions.Timeout = time.Duration(cfg.Timeouts.Call)
+
+	apiHandler, err := api.NewPollApi(cfg.Store, cfg.RedisOptions(), cfg.APIs.Votes)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	snapshots := docstore.SnapshotCommands[poll.Poll]{Service: "poll-api", Kind: docstore.KindPolls, API: apiHandler}
+	switch flag.Arg(0) {
+	case "export":
+		if err := snapshots.Export(flag.Args()[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	case "import":
+		if err := snapshots.Import(flag.Args()[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.RestoreFile != "" {
+		snapshots.Restore(cfg.RestoreFile)
+	}
 
-	apiHandler, err := api.NewPollApi()
+	authn, err := auth.FromEnv(apiHandler.Nonces())
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
+	shutdownTracing, err := tracing.Init(auth.ServicePollAPI)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(requestid.LogFormatter), gin.Recovery())
+	r.Use(cors.New(cfg.CORS()))
+	r.Use(requestid.Middleware())
+	r.Use(tracing.Middleware(auth.ServicePollAPI))
+	r.Use(metrics.Middleware())
+
+	// Every request, and its response, is validated against the OpenAPI
+	// document, which has an operation for every route below (see Check)
+	doc := api.OpenAPI(version)
+	r.Use(doc.Middleware(cfg.OpenAPIValidation))
+
+	// The GET routes are open to everyone, the others need a poll-manager
+	// (or an admin), and deleting needs an admin
+	pollManager := authn.Require(auth.RolePollManager)
+	admin := authn.Require()
+
 	r.GET("/polls", apiHandler.GetAllPolls)
 
 	r.GET("/polls/:id", apiHandler.GetPoll)
-	r.POST("/polls/:id", apiHandler.AddPoll)
+	r.POST("/polls/:id", pollManager, apiHandler.AddPoll)
 
 	r.GET("/polls/:id/options", apiHandler.GetPollOptions)
 
 	r.GET("/polls/:id/options/:optionid", apiHandler.GetPollOption)
-	r.POST("/polls/:id/options/:optionid", apiHandler.AddPollOption)
+	r.POST("/polls/:id/options/:optionid", pollManager, apiHandler.AddPollOption)
+
+	r.POST("/polls/:id/open", pollManager, apiHandler.OpenPoll)
+	r.POST("/polls/:id/close", pollManager, apiHandler.ClosePoll)
+
+	r.PUT("/polls/:id", pollManager, apiHandler.UpdatePoll)
+	r.PUT("/polls/:id/options/:optionid", pollManager, apiHandler.UpdatePollOption)
 
-	r.GET("/polls/health", apiHandler.GetHealth)
+	// /livez and /readyz are open, like /metrics, for compose and kubernetes
+	checker := health.New(auth.ServicePollAPI, version, commit, apiHandler.HealthChecks()...).
+		SetTimeout(time.Duration(cfg.Timeouts.Health)).
+		SetCircuits(resilience.States)
+	r.GET("/livez", checker.Livez)
+	r.GET("/readyz", checker.Readyz)
+	r.GET("/polls/health", checker.Readyz)
+	r.GET("/metrics", metrics.Handler())
 
 	// Extra Credit Handlers
 
-	r.DELETE("/polls/:id", apiHandler.DeletePoll)
-	r.DELETE("/polls/:id/options/:optionid", apiHandler.DeletePollOption)
+	r.DELETE("/polls/:id", admin, apiHandler.DeletePoll)
+	r.DELETE("/polls/:id/options/:optionid", admin, apiHandler.DeletePollOption)
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	r.GET("/admin/export", admin, apiHandler.ExportPolls)
+	r.POST("/admin/import", admin, apiHandler.ImportPolls)
+	r.GET("/admin/audit", admin, apiHandler.GetAuditLog)
+
+	// The OpenAPI document and its Swagger UI page are open to everyone
+	r.GET("/openapi.json", doc.Handler())
+	r.GET("/docs", openapi.SwaggerUI)
+	r.GET("/docs/*file", openapi.SwaggerUI)
+
+	if err := doc.Check(r.Routes()); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// On SIGTERM /readyz fails first, then the requests in flight are given
+	// time to finish before redis is closed
+	err = server.Run(r, checker, server.Options{
+		Addr:    cfg.Addr(),
+		Delay:   time.Duration(cfg.Timeouts.ShutdownDelay),
+		Timeout: time.Duration(cfg.Timeouts.Shutdown),
+	})
+	if err != nil {
+		log.Println("Error serving: ", err)
+	}
+	if err := apiHandler.Close(); err != nil {
+		log.Println("Error closing redis: ", err)
+	}
 }
@@ -0,0 +1,150 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"drexel.edu/common/apierror"
+)
+
+func TestCurrentStatus(t *testing.T) {
+
+	now := time.Date(2023, 8, 20, 18, 30, 0, 0, time.UTC)
+	before, after := now.Add(-time.Second), now.Add(time.Second)
+
+	tests := []struct {
+		name string
+		poll Poll
+		want string
+	}{
+		{"a draft without a window stays a draft", Poll{Status: PollStatusDraft}, PollStatusDraft},
+		{"a draft before OpensAt", Poll{Status: PollStatusDraft, OpensAt: &after}, PollStatusDraft},
+		{"a draft opens at OpensAt", Poll{Status: PollStatusDraft, OpensAt: &now}, PollStatusOpen},
+		{"a draft after OpensAt", Poll{Status: PollStatusDraft, OpensAt: &before}, PollStatusOpen},
+		{"open before ClosesAt", Poll{Status: PollStatusOpen, ClosesAt: &after}, PollStatusOpen},
+		{"open closes at ClosesAt", Poll{Status: PollStatusOpen, ClosesAt: &now}, PollStatusClosed},
+		{"a draft past its whole window", Poll{Status: PollStatusDraft, OpensAt: &before, ClosesAt: &now}, PollStatusClosed},
+		{"closed before ClosesAt", Poll{Status: PollStatusClosed, ClosesAt: &after}, PollStatusClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.poll.currentStatus(now); got != tt.want {
+				t.Errorf("currentStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAndClosePoll(t *testing.T) {
+
+	ctx := context.Background()
+	pl := NewInMemory("")
+
+	later := time.Now().Add(time.Hour)
+	if err := pl.AddPoll(ctx, Poll{PollID: "/polls/1", OpensAt: &later}); err != nil {
+		t.Fatal(err)
+	}
+	poll, err := pl.GetPoll(ctx, "/polls/1")
+	if err != nil || poll.Status != PollStatusDraft {
+		t.Fatalf("a Poll scheduled to open later is %v, %v, want %v", poll.Status, err, PollStatusDraft)
+	}
+
+	// opening it early moves OpensAt to now
+	opened, err := pl.OpenPoll(ctx, "/polls/1")
+	if err != nil {
+		t.Fatalf("OpenPoll() error = %v", err)
+	}
+	if opened.Status != PollStatusOpen || opened.OpensAt == nil || opened.OpensAt.After(time.Now()) {
+		t.Errorf("OpenPoll() = %v opening at %v, want open since now", opened.Status, opened.OpensAt)
+	}
+
+	closed, err := pl.ClosePoll(ctx, "/polls/1")
+	if err != nil {
+		t.Fatalf("ClosePoll() error = %v", err)
+	}
+	if closed.Status != PollStatusClosed || closed.ClosesAt == nil {
+		t.Errorf("ClosePoll() = %v closing at %v, want closed since now", closed.Status, closed.ClosesAt)
+	}
+
+	// closing it again changes nothing
+	again, err := pl.ClosePoll(ctx, "/polls/1")
+	if err != nil || !again.ClosesAt.Equal(*closed.ClosesAt) {
+		t.Errorf("closing again = %v, %v, want the same ClosesAt %v", again.ClosesAt, err, closed.ClosesAt)
+	}
+
+	// and it can't be opened again
+	_, err = pl.OpenPoll(ctx, "/polls/1")
+	if !errors.Is(err, ErrPollClosed) || !errors.Is(err, apierror.ErrConflict) {
+		t.Errorf("reopening a closed Poll: error = %v, want %v", err, ErrPollClosed)
+	}
+
+	if _, err := pl.OpenPoll(ctx, "/polls/2"); !errors.Is(err, ErrPollNotFound) {
+		t.Errorf("opening an unknown Poll: error = %v, want %v", err, ErrPollNotFound)
+	}
+}
+
+// A Poll whose ClosesAt passed is closed even though it was stored open, so
+// it can't be opened again either
+func TestPollClosedByItsWindow(t *testing.T) {
+
+	ctx := context.Background()
+	pl := NewInMemory("")
+
+	opensAt, closesAt := time.Now().Add(-time.Hour), time.Now().Add(-time.Minute)
+	if err := pl.AddPoll(ctx, Poll{PollID: "/polls/1", Status: PollStatusOpen, OpensAt: &opensAt, ClosesAt: &closesAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	poll, err := pl.GetPoll(ctx, "/polls/1")
+	if err != nil || poll.Status != PollStatusClosed {
+		t.Fatalf("GetPoll() = %v, %v, want %v", poll.Status, err, PollStatusClosed)
+	}
+	if _, err := pl.OpenPoll(ctx, "/polls/1"); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("OpenPoll() error = %v, want %v", err, ErrPollClosed)
+	}
+}
+
+func TestAddPollWindow(t *testing.T) {
+
+	ctx := context.Background()
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		poll       Poll
+		wantStatus string
+		wantErr    error
+	}{
+		{"open by default", Poll{}, PollStatusOpen, nil},
+		{"a draft until OpensAt", Poll{OpensAt: &later}, PollStatusDraft, nil},
+		{"ClosesAt at OpensAt", Poll{OpensAt: &later, ClosesAt: &later}, "", ErrInvalidPollWindow},
+		{"ClosesAt before OpensAt", Poll{OpensAt: &later, ClosesAt: &now}, "", ErrInvalidPollWindow},
+		{"a new Poll can't be closed", Poll{Status: PollStatusClosed}, "", ErrInvalidPollWindow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			pl := NewInMemory("")
+			tt.poll.PollID = "/polls/1"
+
+			err := pl.AddPoll(ctx, tt.poll)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("AddPoll() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddPoll() error = %v", err)
+			}
+			if poll, _ := pl.GetPoll(ctx, "/polls/1"); poll.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v", poll.Status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -2,14 +2,15 @@ package poll
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"os"
+	"strings"
+	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/nitishm/go-rejson/v4"
+	"drexel.edu/client"
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
 )
 
 //STRUCTS
@@ -24,116 +25,154 @@ type Poll struct {
 	PollTitle    string `json:",omitempty"`
 	PollQuestion string `json:",omitempty"`
 	PollOptions  []pollOption
+	Status       string
+	OpensAt      *time.Time `json:",omitempty"`
+	ClosesAt     *time.Time `json:",omitempty"`
+	PollType     string
+	// MaxSelections is how many PollOptions a Vote can select in a multi Poll
+	MaxSelections int `json:",omitempty"`
 }
 
+// The PollTypes of a Poll. A Vote selects exactly one PollOption in a single
+// Poll, up to MaxSelections PollOptions in a multi Poll, and ranks one or
+// more PollOptions in order of preference in a ranked Poll.
+const (
+	PollTypeSingle = "single"
+	PollTypeMulti  = "multi"
+	PollTypeRanked = "ranked"
+)
+
+// The Statuses of a Poll. Votes can only be cast while a Poll is open.
+const (
+	PollStatusDraft  = "draft"
+	PollStatusOpen   = "open"
+	PollStatusClosed = "closed"
+)
+
 const (
-	RedisNilError        = "redis: nil"
 	RedisDefaultLocation = "0.0.0.0:6379"
 	RedisKeyPrefix       = "poll:"
+	RedisScanCount       = 100
 )
 
-type cache struct {
-	cacheClient *redis.Client
-	jsonHelper  *rejson.Handler
-	context     context.Context
+// The errors returned by the PollList, each wraps the apierror kind that
+// says which HTTP status it maps to
+var (
+	ErrPollNotFound       = fmt.Errorf("Poll %w", apierror.ErrNotFound)
+	ErrPollExists         = fmt.Errorf("Poll %w", apierror.ErrConflict)
+	ErrPollOptionNotFound = fmt.Errorf("pollOption %w", apierror.ErrNotFound)
+	ErrPollOptionExists   = fmt.Errorf("pollOption %w", apierror.ErrConflict)
+	ErrInvalidPollOptions = fmt.Errorf("PollOptions %w", apierror.ErrValidation)
+	ErrInvalidPollWindow  = fmt.Errorf("Poll window %w", apierror.ErrValidation)
+	ErrInvalidPollType    = fmt.Errorf("PollType %w", apierror.ErrValidation)
+	ErrPollClosed         = fmt.Errorf("Poll already closed, it %w", apierror.ErrConflict)
+	ErrPollOptionHasVotes = fmt.Errorf("pollOption has Votes, a change %w", apierror.ErrConflict)
+	ErrPollHasVotes       = fmt.Errorf("Poll has Votes, a deletion %w", apierror.ErrConflict)
+)
+
+// PollStore is where a PollList keeps its Polls, by PollID. The redis and
+// in-memory implementations are in drexel.edu/common/docstore, and Get
+// returns docstore.ErrNotFound when there is no Poll with the PollID.
+type PollStore interface {
+	Get(ctx context.Context, pollID string) (Poll, error)
+	Set(ctx context.Context, pollID string, poll Poll) error
+	Delete(ctx context.Context, pollID string) (bool, error)
+	List(ctx context.Context, cursor uint64, limit int64) ([]Poll, uint64, error)
 }
 
 type PollList struct {
 
-	//Redis cache connections
-	cache
+	//Where the Polls are kept, in redis or in memory
+	store PollStore
+
+	//The Votes API is asked whether a Poll or pollOption has Votes before
+	//it is changed or deleted
+	votes *client.VotesService
 }
 
-// New is a constructor function that returns a pointer to a new
-// PollList struct.  If this is called it uses the default Redis URL
-// with the companion constructor NewWithCacheInstance.
-func New() (*PollList, error) {
-	//We will use an override if the REDIS_URL is provided as an environment
-	//variable, which is the preferred way to wire up a docker container
-	redisUrl := os.Getenv("POLLAPI_REDIS_URL")
-	//This handles the default condition
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
-	}
-	return NewWithCacheInstance(redisUrl)
+// NewInMemory is a constructor function that returns a pointer to a new
+// PollList struct that keeps its Polls in memory rather than in redis. The
+// Polls are lost when the process exits.
+func NewInMemory(votesAPIurl string) *PollList {
+	return NewWithStore(docstore.NewMemory[Poll](), votesAPIurl)
 }
 
-// NewWithCacheInstance is a constructor function that returns a pointer to a new
-// PollList struct.  It accepts a string that represents the location of the redis
-// cache.
-func NewWithCacheInstance(location string) (*PollList, error) {
+// NewWithStore is a constructor function that returns a pointer to a new
+// PollList struct that keeps its Polls in store.
+func NewWithStore(store PollStore, votesAPIurl string) *PollList {
+	return &PollList{
+		store: store,
+		votes: client.New(client.Config{VotesAPI: votesAPIurl, HTTP: auth.ServiceClient(auth.ServicePollAPI)}).Votes,
+	}
+}
 
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
+//------------------------------------------------------------
+// STORE HELPERS
+//------------------------------------------------------------
 
-	//We use this context to coordinate betwen our go code and
-	//the redis operaitons
-	ctx := context.Background()
+// Helper to return a Poll from the store provided its PollID
+func (pl *PollList) getItem(ctx context.Context, pollID string, poll *Poll) error {
 
-	//This is the reccomended way to ensure that our redis connection
-	//is working
-	err := client.Ping(ctx).Err()
+	stored, err := pl.store.Get(ctx, pollID)
 	if err != nil {
-		log.Println("Error connecting to redis" + err.Error())
-		return nil, err
+		return err
 	}
+	*poll = stored
+	poll.normalize()
 
-	//By default, redis manages keys and values, where the values
-	//are either strings, sets, maps, etc.  Redis has an extension
-	//module called ReJSON that allows us to store JSON objects
-	//however, we need a companion library in order to work with it
-	//Below we create an instance of the JSON helper and associate
-	//it with our redis connnection
-	jsonHelper := rejson.NewReJSONHandler()
-	jsonHelper.SetGoRedisClientWithContext(ctx, client)
-
-	//Return a pointer to a new PollList struct
-	return &PollList{
-		cache: cache{
-			cacheClient: client,
-			jsonHelper:  jsonHelper,
-			context:     ctx,
-		},
-	}, nil
+	return nil
 }
 
-//------------------------------------------------------------
-// REDIS HELPERS
-//------------------------------------------------------------
+// normalize fills in the fields of a Poll that was just read from the store
+func (p *Poll) normalize() {
 
-// We will use this later, you can ignore for now
-func isRedisNilError(err error) bool {
-	return errors.Is(err, redis.Nil) || err.Error() == RedisNilError
-}
+	//The Status depends on the time the Poll is read at, if it was
+	//scheduled to open or close since it was stored
+	p.Status = p.currentStatus(time.Now())
 
-// In redis, our keys will be strings, they will look like
-// poll:<number>.  This function will take an unsigned integer and
-// return a string that can be used as a key in redis
-func redisKeyFromId(id string) string {
-	return fmt.Sprintf("%s%s", RedisKeyPrefix, id)
+	//Polls stored before there were PollTypes are single choice
+	if p.PollType == "" {
+		p.PollType = PollTypeSingle
+	}
 }
 
-// Helper to return a Poll from redis provided a key
-func (pl *PollList) getItemFromRedis(key string, poll *Poll) error {
+// validate fills in the default Status and PollType of a Poll that is added
+// or imported, and checks its Status is one of statuses, its PollType and
+// MaxSelections and that its window closes after it opens
+func (p *Poll) validate(statuses ...string) error {
 
-	//Lets query redis for the item, note we can return parts of the
-	//json structure, the second parameter "." means return the entire
-	//json structure
-	itemObject, err := pl.jsonHelper.JSONGet(key, ".")
-	if err != nil {
-		return err
+	switch p.Status {
+	case "":
+		p.Status = PollStatusOpen
+		if p.OpensAt != nil && p.OpensAt.After(time.Now()) {
+			p.Status = PollStatusDraft
+		}
+	default:
+		allowed := false
+		for _, status := range statuses {
+			allowed = allowed || p.Status == status
+		}
+		if !allowed {
+			return apierror.Wrap(ErrInvalidPollWindow, "Status", "The Status of the Poll must be %v, %q given.", strings.Join(statuses, " or "), p.Status)
+		}
 	}
 
-	//JSONGet returns an "any" object, or empty interface,
-	//we need to convert it to a byte array, which is the
-	//underlying type of the object, then we can unmarshal
-	//it into our Poll struct
-	err = json.Unmarshal(itemObject.([]byte), poll)
-	if err != nil {
-		return err
+	switch p.PollType {
+	case "":
+		p.PollType = PollTypeSingle
+		p.MaxSelections = 0
+	case PollTypeSingle, PollTypeRanked:
+		p.MaxSelections = 0
+	case PollTypeMulti:
+		if p.MaxSelections < 1 {
+			return apierror.Wrap(ErrInvalidPollType, "MaxSelections", "A %v Poll needs a MaxSelections of at least 1, %v given.", PollTypeMulti, p.MaxSelections)
+		}
+	default:
+		return apierror.Wrap(ErrInvalidPollType, "PollType", "PollType must be %v, %v or %v, %q given.", PollTypeSingle, PollTypeMulti, PollTypeRanked, p.PollType)
+	}
+
+	if p.OpensAt != nil && p.ClosesAt != nil && !p.ClosesAt.After(*p.OpensAt) {
+		return apierror.Wrap(ErrInvalidPollWindow, "ClosesAt", "ClosesAt (%v) must be after OpensAt (%v).", p.ClosesAt, p.OpensAt)
 	}
 
 	return nil
@@ -144,32 +183,53 @@ func (pl *PollList) getItemFromRedis(key string, poll *Poll) error {
 //------------------------------------------------------------
 
 // returns all Polls (as a Slice)
-func (pl *PollList) GetAllPolls() ([]Poll, error) {
+func (pl *PollList) GetAllPolls(ctx context.Context) ([]Poll, error) {
 
 	var polls []Poll
 
-	//Lets query redis for all of the items
-	key := RedisKeyPrefix + "*"
-	ks, _ := pl.cacheClient.Keys(pl.context, key).Result()
-	for _, key := range ks {
-		var poll Poll
-		err := pl.getItemFromRedis(key, &poll)
+	//Page through all of the items, rather than using KEYS which
+	//blocks redis while it walks the whole keyspace
+	var cursor uint64
+	for {
+		page, next, err := pl.GetPolls(ctx, cursor, RedisScanCount)
 		if err != nil {
 			return nil, err
 		}
-		polls = append(polls, poll)
+		polls = append(polls, page...)
+		if next == 0 {
+			return polls, nil
+		}
+		cursor = next
 	}
+}
+
+// returns a page of Polls starting at cursor, along with the cursor of the
+// next page (0 when there are no more Polls). In redis, like the SCAN command it
+// is built on, a page can hold slightly more or fewer than limit Polls, and
+// a Poll that is added or deleted while paging may or may not be returned.
+func (pl *PollList) GetPolls(ctx context.Context, cursor uint64, limit int64) ([]Poll, uint64, error) {
 
-	return polls, nil
+	polls, next, err := pl.store.List(ctx, cursor, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for i := range polls {
+		polls[i].normalize()
+	}
+
+	return polls, next, nil
 }
 
 // returns the Poll with the PollID pollID
-func (pl *PollList) GetPoll(pollOptionID string) (Poll, error) {
+func (pl *PollList) GetPoll(ctx context.Context, pollOptionID string) (Poll, error) {
 
 	var poll Poll
-	key := redisKeyFromId(pollOptionID)
-	err := pl.getItemFromRedis(key, &poll)
+	err := pl.getItem(ctx, pollOptionID, &poll)
 	if err != nil {
+		if errors.Is(err, docstore.ErrNotFound) {
+			return Poll{}, apierror.Wrap(ErrPollNotFound, "PollID", "Poll with ID %v does not exist.", pollOptionID)
+		}
 		return Poll{}, err
 	}
 
@@ -178,19 +238,24 @@ func (pl *PollList) GetPoll(pollOptionID string) (Poll, error) {
 
 // AddPoll accepts a Poll and adds it to Polls.
 // its PollOptions is always initialized to an empty slice
-func (pl *PollList) AddPoll(poll Poll) error {
+// its Status can be draft or open, if it is left out the Poll is open right
+// away unless it is scheduled to open later (OpensAt is in the future)
+func (pl *PollList) AddPoll(ctx context.Context, poll Poll) error {
 
-	key := redisKeyFromId(poll.PollID)
 	var existingVoter Poll
-	if err := pl.getItemFromRedis(key, &existingVoter); err == nil {
-		return errors.New(fmt.Sprintf("A Poll with the ID %v already exists.", poll.PollID))
+	if err := pl.getItem(ctx, poll.PollID, &existingVoter); err == nil {
+		return apierror.Wrap(ErrPollExists, "PollID", "A Poll with the ID %v already exists.", poll.PollID)
 	}
 
 	if poll.PollOptions == nil || len(poll.PollOptions) > 0 {
 		poll.PollOptions = make([]pollOption, 0)
 	}
 
-	if _, err := pl.jsonHelper.JSONSet(key, ".", poll); err != nil {
+	if err := poll.validate(PollStatusDraft, PollStatusOpen); err != nil {
+		return err
+	}
+
+	if err := pl.store.Set(ctx, poll.PollID, poll); err != nil {
 		return err
 	}
 
@@ -198,15 +263,14 @@ func (pl *PollList) AddPoll(poll Poll) error {
 }
 
 // returns the Poll's pollPoll where the PollID matches pollOptionID
-func (pl *PollList) GetPollOption(pollID, pollOptionID string) (pollOption, error) {
-	key := redisKeyFromId(pollID)
+func (pl *PollList) GetPollOption(ctx context.Context, pollID, pollOptionID string) (pollOption, error) {
 	var poll Poll
-	if err := pl.getItemFromRedis(key, &poll); err != nil {
-		return pollOption{}, errors.New(fmt.Sprintf("Poll with ID %v does not exist.", pollID))
+	if err := pl.getItem(ctx, pollID, &poll); err != nil {
+		return pollOption{}, apierror.Wrap(ErrPollNotFound, "PollID", "Poll with ID %v does not exist.", pollID)
 	}
 
 	if len(poll.PollOptions) == 0 {
-		return pollOption{}, errors.New(fmt.Sprintf("pollOption with ID %v not found in Poll %v's PollOptions.", pollOptionID, pollID))
+		return pollOption{}, apierror.Wrap(ErrPollOptionNotFound, "PollOptionID", "pollOption with ID %v not found in Poll %v's PollOptions.", pollOptionID, pollID)
 	}
 
 	relevantOptions := make([]pollOption, 0)
@@ -217,7 +281,7 @@ func (pl *PollList) GetPollOption(pollID, pollOptionID string) (pollOption, erro
 		}
 	}
 	if len(relevantOptions) == 0 {
-		return pollOption{}, errors.New(fmt.Sprintf("pollOption with ID %v not found in Poll %v's PollOptions.", pollOptionID, pollID))
+		return pollOption{}, apierror.Wrap(ErrPollOptionNotFound, "PollOptionID", "pollOption with ID %v not found in Poll %v's PollOptions.", pollOptionID, pollID)
 	} else if len(relevantOptions) > 1 {
 		return pollOption{}, errors.New(fmt.Sprintf("There is an error with the internal state. There is an error with the internal state. Multiple instances of pollOption with ID %v in Poll %v's PollOptions.", pollOptionID, pollID))
 	} else {
@@ -227,16 +291,15 @@ func (pl *PollList) GetPollOption(pollID, pollOptionID string) (pollOption, erro
 }
 
 // AddPollOption accepts the pollID, the pollOptionID, and a new Poll and adds the pollOption to the Poll's PollOptions
-func (pl *PollList) AddPollOption(pollID, pollOptionID string, newPoll Poll) error {
+func (pl *PollList) AddPollOption(ctx context.Context, pollID, pollOptionID string, newPoll Poll) error {
 
-	key := redisKeyFromId(pollID)
 	var existingPoll Poll
-	if err := pl.getItemFromRedis(key, &existingPoll); err != nil {
-		return errors.New(fmt.Sprintf("Poll with ID %v does not exist.", pollID))
+	if err := pl.getItem(ctx, pollID, &existingPoll); err != nil {
+		return apierror.Wrap(ErrPollNotFound, "PollID", "Poll with ID %v does not exist.", pollID)
 	}
 
 	if len(newPoll.PollOptions) > 1 || len(newPoll.PollOptions) == 0 {
-		return errors.New(fmt.Sprintf("Only allowed to add one new pollOption at a time, and %v given.", len(newPoll.PollOptions)))
+		return apierror.Wrap(ErrInvalidPollOptions, "PollOptions", "Only allowed to add one new pollOption at a time, and %v given.", len(newPoll.PollOptions))
 	}
 
 	pollOption := newPoll.PollOptions[0]
@@ -247,39 +310,57 @@ func (pl *PollList) AddPollOption(pollID, pollOptionID string, newPoll Poll) err
 		for i := 0; i < len(existingPoll.PollOptions); i++ {
 			currOption := existingPoll.PollOptions[i]
 			if currOption.PollOptionID == pollOption.PollOptionID {
-				return errors.New(fmt.Sprintf("PollOption with ID %v already exists in Poll %v's PollOptions.", pollOptionID, pollID))
+				return apierror.Wrap(ErrPollOptionExists, "PollOptionID", "PollOption with ID %v already exists in Poll %v's PollOptions.", pollOptionID, pollID)
 			}
 		}
 	}
 	existingPoll.PollOptions = append(existingPoll.PollOptions, pollOption)
-	if _, err := pl.jsonHelper.JSONSet(key, ".", existingPoll); err != nil {
+	if err := pl.store.Set(ctx, pollID, existingPoll); err != nil {
 		return err
 	}
 	return nil
 }
 
-// // deletes the Poll with the PollID pollID from Polls
-func (pl *PollList) DeletePoll(pollID string) error {
+// deletes the Poll with the PollID pollID from Polls. A Poll that Votes were
+// cast in is only deleted if cascade is set, in which case its Votes are
+// deleted (through the Votes API) first.
+func (pl *PollList) DeletePoll(ctx context.Context, pollID string, cascade bool) error {
 
-	key := redisKeyFromId(pollID)
-	numDeleted, err := pl.cacheClient.Del(pl.context, key).Result()
+	var poll Poll
+	if err := pl.getItem(ctx, pollID, &poll); err != nil {
+		return apierror.Wrap(ErrPollNotFound, "PollID", "An poll with the ID %v does not exist, thus it cannot be removed.", pollID)
+	}
+
+	if err := pl.resolveVotes(ctx, pollID, cascade, ErrPollHasVotes, "PollID"); err != nil {
+		return err
+	}
+
+	deleted, err := pl.store.Delete(ctx, pollID)
 	if err != nil {
 		return err
 	}
-	if numDeleted == 0 {
-		return errors.New(fmt.Sprintf("An poll with the ID %v does not exist, thus it cannot be removed.", pollID))
+	if !deleted {
+		return apierror.Wrap(ErrPollNotFound, "PollID", "An poll with the ID %v does not exist, thus it cannot be removed.", pollID)
+	}
+
+	//A Vote may have been cast after the Votes were checked, none can be
+	//once the Poll is gone, so they are checked again and the Poll is put
+	//back if one was
+	if err := pl.resolveVotes(ctx, pollID, cascade, ErrPollHasVotes, "PollID"); err != nil {
+		return pl.restore(ctx, poll, err)
 	}
 
 	return nil
 }
 
-// deletes the pollOption pollOptionID from the Poll PollID
-func (pl *PollList) DeletePollOption(pollID, pollOptionID string) error {
+// deletes the pollOption pollOptionID from the Poll PollID. Like DeletePoll,
+// a pollOption that Votes select is only deleted if cascade is set, which
+// deletes those Votes first.
+func (pl *PollList) DeletePollOption(ctx context.Context, pollID, pollOptionID string, cascade bool) error {
 
-	key := redisKeyFromId(pollID)
 	var poll Poll
-	if err := pl.getItemFromRedis(key, &poll); err != nil {
-		return errors.New(fmt.Sprintf("Poll with ID %v does not exist.", pollOptionID))
+	if err := pl.getItem(ctx, pollID, &poll); err != nil {
+		return apierror.Wrap(ErrPollNotFound, "PollID", "Poll with ID %v does not exist.", pollID)
 	}
 
 	i := -1
@@ -291,72 +372,293 @@ func (pl *PollList) DeletePollOption(pollID, pollOptionID string) error {
 	}
 
 	if i == -1 {
-		return errors.New(fmt.Sprintf("pollOption %v does not exist in Poll %v's PollOptions:", pollOptionID, poll.PollID))
+		return apierror.Wrap(ErrPollOptionNotFound, "PollOptionID", "pollOption %v does not exist in Poll %v's PollOptions.", pollOptionID, poll.PollID)
 	}
 
+	if err := pl.resolveVotes(ctx, pollOptionID, cascade, ErrPollOptionHasVotes, "PollOptionID"); err != nil {
+		return err
+	}
+
+	before := poll
+	before.PollOptions = append([]pollOption(nil), poll.PollOptions...)
 	poll.PollOptions = append(poll.PollOptions[:i], poll.PollOptions[i+1:]...)
 
-	if _, err := pl.jsonHelper.JSONSet(key, ".", poll); err != nil {
+	if err := pl.store.Set(ctx, pollID, poll); err != nil {
 		return err
-	} else {
-		return nil
 	}
+
+	//Like DeletePoll, the Votes are checked again now that no more can
+	//select the pollOption
+	if err := pl.resolveVotes(ctx, pollOptionID, cascade, ErrPollOptionHasVotes, "PollOptionID"); err != nil {
+		return pl.restore(ctx, before, err)
+	}
+
+	return nil
 }
 
-// // updates an existing Poll with the newPoll's fields (PollTitle and PollQuestion)
-// func (pl *PollList) UpdatePoll(newPoll Poll) error {
-
-// 	key := redisKeyFromId(newPoll.PollID)
-// 	var existingPoll Poll
-// 	if err := pl.getItemFromRedis(key, &existingPoll); err != nil {
-// 		return errors.New(fmt.Sprintf("The poll to be updated Poll %v, does not exist.", newPoll.PollID))
-// 	}
-
-// 	// Only updates the included Poll fields, and leaves not included ones unchanged
-// 	if newPoll.PollTitle != "" {
-// 		existingPoll.PollTitle = newPoll.PollTitle
-// 	}
-// 	if newPoll.PollQuestion != "" {
-// 		existingPoll.PollQuestion = newPoll.PollQuestion
-// 	}
-
-// 	//Add item to database with JSON Set.  Note there is no update
-// 	//functionality, so we just overwrite the existing item
-// 	if _, err := pl.jsonHelper.JSONSet(key, ".", existingPoll); err != nil {
-// 		return err
-// 	}
-
-// 	//If everything is ok, return nil for the error
-// 	return nil
-// }
-
-// // updates an existing pollOption in Poll ID's PollOptions
-// func (pl *PollList) UpdatePollData(pollID, pollOptionID string, newPoll Poll) error {
-
-// 	key := redisKeyFromId(pollID)
-// 	var poll Poll
-// 	if err := pl.getItemFromRedis(key, &poll); err != nil {
-// 		return errors.New(fmt.Sprintf("Poll with ID %v does not exist.", pollID))
-// 	}
-
-// 	if len(newPoll.PollOptions) > 1 || len(newPoll.PollOptions) == 0 {
-// 		return errors.New(fmt.Sprintf("Only allowed to update one pollOption at a time, and %v given.", len(newPoll.PollOptions)))
-// 	}
-
-// 	newPollOption := newPoll.PollOptions[0]
-
-// 	newPollOption.PollOptionID = pollOptionID
-
-// 	for index, currOption := range poll.PollOptions {
-// 		if currOption.PollOptionID == pollOptionID {
-// 			poll.PollOptions[index] = newPollOption
-// 			if _, err := pl.jsonHelper.JSONSet(key, ".", poll); err != nil {
-// 				return err
-// 			} else {
-// 				return nil
-// 			}
-// 		}
-// 	}
-
-// 	return errors.New(fmt.Sprintf("Poll with ID %v does not exist in Poll %v's VoteHistory", newPoll.PollID, pollOptionID))
-// }
+//------------------------------------------------------------
+// POLL LIFECYCLE
+//------------------------------------------------------------
+
+// currentStatus returns the Status of the Poll at the time now. A draft Poll
+// is open once its OpensAt has passed, and any Poll is closed once its
+// ClosesAt has passed.
+func (p *Poll) currentStatus(now time.Time) string {
+	switch {
+	case p.Status == PollStatusClosed:
+		return PollStatusClosed
+	case p.ClosesAt != nil && !now.Before(*p.ClosesAt):
+		return PollStatusClosed
+	case p.Status == PollStatusOpen:
+		return PollStatusOpen
+	case p.OpensAt != nil && !now.Before(*p.OpensAt):
+		return PollStatusOpen
+	default:
+		return PollStatusDraft
+	}
+}
+
+// opens the Poll pollID now, a Poll that was closed can not be opened again
+func (pl *PollList) OpenPoll(ctx context.Context, pollID string) (Poll, error) {
+
+	var poll Poll
+	if err := pl.getItem(ctx, pollID, &poll); err != nil {
+		return Poll{}, apierror.Wrap(ErrPollNotFound, "PollID", "Poll with ID %v does not exist.", pollID)
+	}
+
+	if poll.Status == PollStatusClosed {
+		return Poll{}, apierror.Wrap(ErrPollClosed, "Status", "Poll %v is closed, it cannot be opened again.", pollID)
+	}
+
+	now := time.Now().UTC()
+	poll.Status = PollStatusOpen
+	if poll.OpensAt == nil || poll.OpensAt.After(now) {
+		poll.OpensAt = &now
+	}
+
+	if err := pl.store.Set(ctx, pollID, poll); err != nil {
+		return Poll{}, err
+	}
+	return poll, nil
+}
+
+// closes the Poll pollID now, no more Votes can be cast in it afterwards
+func (pl *PollList) ClosePoll(ctx context.Context, pollID string) (Poll, error) {
+
+	var poll Poll
+	if err := pl.getItem(ctx, pollID, &poll); err != nil {
+		return Poll{}, apierror.Wrap(ErrPollNotFound, "PollID", "Poll with ID %v does not exist.", pollID)
+	}
+
+	if poll.Status == PollStatusClosed {
+		return poll, nil
+	}
+
+	now := time.Now().UTC()
+	poll.Status = PollStatusClosed
+	if poll.ClosesAt == nil || poll.ClosesAt.After(now) {
+		poll.ClosesAt = &now
+	}
+
+	if err := pl.store.Set(ctx, pollID, poll); err != nil {
+		return Poll{}, err
+	}
+	return poll, nil
+}
+
+// updates an existing Poll with the newPoll's fields (PollTitle and PollQuestion)
+// and returns the updated Poll
+func (pl *PollList) UpdatePoll(ctx context.Context, newPoll Poll) (Poll, error) {
+
+	var existingPoll Poll
+	if err := pl.getItem(ctx, newPoll.PollID, &existingPoll); err != nil {
+		return Poll{}, apierror.Wrap(ErrPollNotFound, "PollID", "The poll to be updated Poll %v, does not exist.", newPoll.PollID)
+	}
+
+	// Only updates the included Poll fields, and leaves not included ones unchanged
+	if newPoll.PollTitle != "" {
+		existingPoll.PollTitle = newPoll.PollTitle
+	}
+	if newPoll.PollQuestion != "" {
+		existingPoll.PollQuestion = newPoll.PollQuestion
+	}
+
+	//Add item to database with JSON Set.  Note there is no update
+	//functionality, so we just overwrite the existing item
+	if err := pl.store.Set(ctx, newPoll.PollID, existingPoll); err != nil {
+		return Poll{}, err
+	}
+
+	//If everything is ok, return nil for the error
+	return existingPoll, nil
+}
+
+// updates an existing pollOption (its PollOptionText) in Poll pollID's PollOptions.
+// A pollOption that Votes were already cast for is only changed if force is set,
+// otherwise the meaning of those Votes would silently change.
+func (pl *PollList) UpdatePollOption(ctx context.Context, pollID, pollOptionID string, newPoll Poll, force bool) (pollOption, error) {
+
+	var poll Poll
+	if err := pl.getItem(ctx, pollID, &poll); err != nil {
+		return pollOption{}, apierror.Wrap(ErrPollNotFound, "PollID", "Poll with ID %v does not exist.", pollID)
+	}
+
+	if len(newPoll.PollOptions) > 1 || len(newPoll.PollOptions) == 0 {
+		return pollOption{}, apierror.Wrap(ErrInvalidPollOptions, "PollOptions", "Only allowed to update one pollOption at a time, and %v given.", len(newPoll.PollOptions))
+	}
+
+	newPollOption := newPoll.PollOptions[0]
+
+	newPollOption.PollOptionID = pollOptionID
+
+	for index, currOption := range poll.PollOptions {
+		if currOption.PollOptionID != pollOptionID {
+			continue
+		}
+
+		if !force {
+			votes, err := pl.countVotes(ctx, pollOptionID, "PollOptionID")
+			if err != nil {
+				return pollOption{}, err
+			}
+			if votes > 0 {
+				return pollOption{}, apierror.Wrap(ErrPollOptionHasVotes, "PollOptionID", "pollOption %v already has %v Vote(s), use ?force=true to change it anyway.", pollOptionID, votes)
+			}
+		}
+
+		poll.PollOptions[index] = newPollOption
+		if err := pl.store.Set(ctx, pollID, poll); err != nil {
+			return pollOption{}, err
+		}
+		return newPollOption, nil
+	}
+
+	return pollOption{}, apierror.Wrap(ErrPollOptionNotFound, "PollOptionID", "pollOption %v does not exist in Poll %v's PollOptions.", pollOptionID, pollID)
+}
+
+//------------------------------------------------------------
+// SNAPSHOTS
+//------------------------------------------------------------
+
+// returns a snapshot of every Poll, as it is stored
+func (pl *PollList) Export(ctx context.Context) (docstore.Snapshot[Poll], error) {
+
+	var polls []Poll
+
+	var cursor uint64
+	for {
+		page, next, err := pl.store.List(ctx, cursor, RedisScanCount)
+		if err != nil {
+			return docstore.Snapshot[Poll]{}, err
+		}
+		polls = append(polls, page...)
+		if next == 0 {
+			return docstore.NewSnapshot(docstore.KindPolls, polls), nil
+		}
+		cursor = next
+	}
+}
+
+// adds every Poll in the snapshot to Polls, overwriting the Polls that have
+// the same PollID, and returns how many were imported. Nothing is imported
+// unless every Poll has a PollID.
+func (pl *PollList) Import(ctx context.Context, snapshot docstore.Snapshot[Poll]) (int, error) {
+
+	if err := snapshot.Check(docstore.KindPolls); err != nil {
+		return 0, apierror.Validation("", "Invalid snapshot: %v", err)
+	}
+
+	//Every Poll is checked as AddPoll would, before any is written. Unlike
+	//a new Poll an imported one may already be closed.
+	polls := make([]Poll, len(snapshot.Items))
+	for i, poll := range snapshot.Items {
+		if poll.PollID == "" {
+			return 0, apierror.Validation("PollID", "Poll %v in the snapshot has no PollID.", i)
+		}
+		if poll.PollOptions == nil {
+			poll.PollOptions = make([]pollOption, 0)
+		}
+		if err := poll.validate(PollStatusDraft, PollStatusOpen, PollStatusClosed); err != nil {
+			var apiErr *apierror.Error
+			if errors.As(err, &apiErr) {
+				return 0, apierror.Wrap(apiErr.Err, apiErr.Field, "Poll %v in the snapshot is invalid: %v", poll.PollID, apiErr.Message)
+			}
+			return 0, err
+		}
+		polls[i] = poll
+	}
+
+	for i, poll := range polls {
+		if err := pl.store.Set(ctx, poll.PollID, poll); err != nil {
+			return i, err
+		}
+	}
+
+	return len(snapshot.Items), nil
+}
+
+//------------------------------------------------------------
+// VOTES API HELPERS
+//------------------------------------------------------------
+
+// returns the number of Votes cast in the Poll, or that select the pollOption,
+// with the ID id according to the Votes API
+func (pl *PollList) countVotes(ctx context.Context, id, field string) (int, error) {
+
+	countForID := pl.votes.CountForPoll
+	if field == "PollOptionID" {
+		countForID = pl.votes.CountForPollOption
+	}
+
+	votes, err := countForID(ctx, id)
+	if err != nil {
+		return 0, apierror.Call(err, field, "Could not count the Votes of %v with the Votes API: %v", id, err)
+	}
+
+	return votes, nil
+}
+
+// deletes the Votes cast in the Poll, or that select the pollOption, with the
+// ID id through the Votes API, which also removes them from the Voters'
+// VoteHistory
+func (pl *PollList) deleteVotes(ctx context.Context, id, field string) error {
+
+	deleteForID := pl.votes.DeleteForPoll
+	if field == "PollOptionID" {
+		deleteForID = pl.votes.DeleteForPollOption
+	}
+
+	if err := deleteForID(ctx, id); err != nil {
+		return apierror.Call(err, field, "Could not delete the Votes of %v through the Votes API: %v", id, err)
+	}
+
+	return nil
+}
+
+// restore puts the Poll back as it was before a deletion that failed with
+// err, and returns err
+func (pl *PollList) restore(ctx context.Context, poll Poll, err error) error {
+	if restoreErr := pl.store.Set(ctx, poll.PollID, poll); restoreErr != nil {
+		return fmt.Errorf("%w, and Poll %v could not be put back: %v", err, poll.PollID, restoreErr)
+	}
+	return err
+}
+
+// resolveVotes makes sure nothing refers to the Poll or pollOption id once it
+// is deleted: its Votes are deleted if cascade is set, otherwise hasVotes is
+// returned if it has any
+func (pl *PollList) resolveVotes(ctx context.Context, id string, cascade bool, hasVotes error, field string) error {
+
+	if cascade {
+		return pl.deleteVotes(ctx, id, field)
+	}
+
+	votes, err := pl.countVotes(ctx, id, field)
+	if err != nil {
+		return err
+	}
+	if votes > 0 {
+		return apierror.Wrap(hasVotes, field, "%v has %v Vote(s), use ?cascade=true to delete them as well.", id, votes)
+	}
+	return nil
+}
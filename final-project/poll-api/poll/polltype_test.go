@@ -0,0 +1,49 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAddPollType(t *testing.T) {
+
+	tests := []struct {
+		poll              Poll
+		wantType          string
+		wantMaxSelections int
+		wantErr           error
+	}{
+		{poll: Poll{}, wantType: PollTypeSingle},
+		{poll: Poll{PollType: PollTypeSingle, MaxSelections: 3}, wantType: PollTypeSingle},
+		{poll: Poll{PollType: PollTypeRanked, MaxSelections: 3}, wantType: PollTypeRanked},
+		{poll: Poll{PollType: PollTypeMulti, MaxSelections: 2}, wantType: PollTypeMulti, wantMaxSelections: 2},
+		{poll: Poll{PollType: PollTypeMulti}, wantErr: ErrInvalidPollType},
+		{poll: Poll{PollType: "approval"}, wantErr: ErrInvalidPollType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.poll.PollType, func(t *testing.T) {
+
+			ctx := context.Background()
+			pl := NewInMemory("")
+			tt.poll.PollID = "/polls/1"
+
+			err := pl.AddPoll(ctx, tt.poll)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddPoll() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			poll, err := pl.GetPoll(ctx, "/polls/1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if poll.PollType != tt.wantType || poll.MaxSelections != tt.wantMaxSelections {
+				t.Errorf("PollType, MaxSelections = %v, %v, want %v, %v", poll.PollType, poll.MaxSelections, tt.wantType, tt.wantMaxSelections)
+			}
+		})
+	}
+}
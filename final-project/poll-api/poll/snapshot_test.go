@@ -0,0 +1,91 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"drexel.edu/common/docstore"
+)
+
+// An exported Poll, including a closed one, is imported unchanged
+func TestExportImportRoundTrip(t *testing.T) {
+
+	ctx := context.Background()
+	source := NewInMemory("")
+
+	opensAt, closesAt := time.Now().Add(time.Hour).UTC(), time.Now().Add(2*time.Hour).UTC()
+	polls := []Poll{
+		{PollID: "/polls/1", PollTitle: "Lunch", PollType: PollTypeMulti, MaxSelections: 2},
+		{PollID: "/polls/2", OpensAt: &opensAt, ClosesAt: &closesAt},
+	}
+	for _, poll := range polls {
+		if err := source.AddPoll(ctx, poll); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := source.AddPollOption(ctx, "/polls/1", "/polls/1/options/1", Poll{PollOptions: []pollOption{{PollOptionText: "Pizza"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.AddPoll(ctx, Poll{PollID: "/polls/3"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.ClosePoll(ctx, "/polls/3"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := source.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := NewInMemory("")
+	if n, err := imported.Import(ctx, snapshot); n != 3 || err != nil {
+		t.Fatalf("Import() = %v, %v, want 3", n, err)
+	}
+
+	want, _ := source.GetAllPolls(ctx)
+	got, _ := imported.GetAllPolls(ctx)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported %+v, want %+v", got, want)
+	}
+}
+
+// An imported Poll is checked as AddPoll checks a new one, and nothing is
+// imported when one of them is invalid
+func TestImportValidation(t *testing.T) {
+
+	ctx := context.Background()
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		poll    Poll
+		wantErr error
+	}{
+		{"an unknown PollType", Poll{PollType: "approval"}, ErrInvalidPollType},
+		{"a multi Poll without MaxSelections", Poll{PollType: PollTypeMulti}, ErrInvalidPollType},
+		{"an unknown Status", Poll{Status: "archived"}, ErrInvalidPollWindow},
+		{"ClosesAt before OpensAt", Poll{OpensAt: &later, ClosesAt: &now}, ErrInvalidPollWindow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			pl := NewInMemory("")
+			tt.poll.PollID = "/polls/2"
+			snapshot := docstore.NewSnapshot(docstore.KindPolls, []Poll{{PollID: "/polls/1"}, tt.poll})
+
+			n, err := pl.Import(ctx, snapshot)
+			if n != 0 || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Import() = %v, %v, want 0, %v", n, err, tt.wantErr)
+			}
+			if polls, _ := pl.GetAllPolls(ctx); len(polls) != 0 {
+				t.Errorf("%v Polls were imported, want none", len(polls))
+			}
+		})
+	}
+}
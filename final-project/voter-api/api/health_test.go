@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/health"
+)
+
+// The Voter API is ready while the Votes API is up, which it asks with
+// GET /livez since the Votes API checks the Voter API in turn
+func TestHealthChecksTheVotesAPI(t *testing.T) {
+
+	var mu sync.Mutex
+	var paths []string
+	votesAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+	}))
+	defer votesAPI.Close()
+
+	v, err := NewVoterApi(docstore.BackendMemory, nil, votesAPI.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := health.New(auth.ServiceVoterAPI, "1.0.0", "", v.HealthChecks()...)
+
+	report := checker.Ready(context.Background())
+	if report.Status != health.StatusOK || report.Checks[auth.ServiceVotesAPI].Status != health.StatusOK {
+		t.Errorf("Ready() = %+v, want ok", report)
+	}
+	if _, ok := report.Checks["redis"]; ok {
+		t.Errorf("redis is checked with the Voters kept in memory")
+	}
+	mu.Lock()
+	if len(paths) != 1 || paths[0] != "/livez" {
+		t.Errorf("the Votes API was asked for %v, want [/livez]", paths)
+	}
+	mu.Unlock()
+
+	votesAPI.Close()
+	report = checker.Ready(context.Background())
+	if report.Status != health.StatusUnavailable || report.Checks[auth.ServiceVotesAPI].Status != health.StatusUnavailable {
+		t.Errorf("Ready() with the Votes API down = %+v, want unavailable", report)
+	}
+}
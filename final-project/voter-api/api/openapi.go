@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+
+	"drexel.edu/common/audit"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/openapi"
+	"drexel.edu/voter-api/voter"
+)
+
+// OpenAPI returns the OpenAPI document of the Voter API, with an operation
+// for every route registered in main.go
+func OpenAPI(version string) *openapi.Document {
+
+	doc := openapi.New("Voter API", version,
+		"The Voters and their VoteHistory. The ID of a Voter is its path, e.g. /voters/1, and the VoteHistory has a voterPoll for every Poll (e.g. /polls/1) the Voter has voted in.")
+
+	admin := openapi.Require()
+	votesAPI := openapi.RequireService(auth.ServiceVotesAPI)
+	voteHistory := voter.Voter{}.VoteHistory
+
+	doc.Add(http.MethodGet, "/voters", openapi.Operation{
+		ID:          "getAllVoters",
+		Summary:     "Every Voter, or a page of them",
+		Description: "Without cursor and limit every Voter is returned, with either of them a VoterPage.",
+		Tag:         "voters",
+		Query:       openapi.PageParams,
+		Response:    openapi.OneOf([]voter.Voter{}, VoterPage{}),
+	})
+	doc.Add(http.MethodGet, "/voters/:id", openapi.Operation{
+		ID:       "getVoter",
+		Summary:  "A Voter",
+		Tag:      "voters",
+		Response: voter.Voter{},
+	})
+	doc.Add(http.MethodPost, "/voters/:id", openapi.Operation{
+		ID:          "addVoter",
+		Summary:     "Adds a Voter",
+		Description: "The VoterID is taken from the path and the VoteHistory is ignored, it is written by the Votes API as Votes are cast.",
+		Tag:         "voters",
+		Auth:        admin,
+		Body:        voter.Voter{},
+	})
+	doc.Add(http.MethodGet, "/voters/:id/polls", openapi.Operation{
+		ID:       "getVoteHistory",
+		Summary:  "The VoteHistory of a Voter",
+		Tag:      "vote history",
+		Response: voteHistory,
+	})
+	doc.Add(http.MethodGet, "/voters/:id/polls/:pollid", openapi.Operation{
+		ID:       "getPollData",
+		Summary:  "The voterPoll of a Voter for a Poll",
+		Tag:      "vote history",
+		Response: openapi.ItemOf(voteHistory),
+	})
+	doc.Add(http.MethodPost, "/voters/:id/polls/:pollid", openapi.Operation{
+		ID:          "addPollData",
+		Summary:     "Adds a voterPoll to the VoteHistory of a Voter",
+		Description: "The voterPoll is the only one of the VoteHistory of the body, its PollID is taken from the path. The other fields of the Voter are ignored.",
+		Tag:         "vote history",
+		Auth:        votesAPI,
+		Body:        voter.Voter{},
+	})
+
+	doc.AddHealth("/voters/health")
+
+	doc.Add(http.MethodDelete, "/voters/:id", openapi.Operation{
+		ID:      "deleteVoter",
+		Summary: "Deletes a Voter",
+		Tag:     "voters",
+		Auth:    admin,
+		Query:   []openapi.Parameter{{Name: "cascade", Type: "boolean", Description: "Also delete the Votes of the Voter, without it a Voter who has voted is not deleted."}},
+	})
+	doc.Add(http.MethodDelete, "/voters/:id/polls/:pollid", openapi.Operation{
+		ID:      "deletePollData",
+		Summary: "Removes a voterPoll from the VoteHistory of a Voter",
+		Tag:     "vote history",
+		Auth:    votesAPI,
+	})
+	doc.Add(http.MethodPut, "/voters/:id/polls/:pollid", openapi.Operation{
+		ID:          "updatePollData",
+		Summary:     "Changes the VoteDate of a voterPoll of a Voter",
+		Description: "The voterPoll is the only one of the VoteHistory of the body. The other fields of the Voter are ignored.",
+		Tag:         "vote history",
+		Auth:        votesAPI,
+		Body:        voter.Voter{},
+	})
+
+	doc.Add(http.MethodGet, "/admin/export", openapi.Operation{
+		ID:       "exportVoters",
+		Summary:  "A snapshot of every Voter",
+		Tag:      "admin",
+		Auth:     admin,
+		Response: docstore.Snapshot[voter.Voter]{},
+	})
+	doc.Add(http.MethodPost, "/admin/import", openapi.Operation{
+		ID:          "importVoters",
+		Summary:     "Adds every Voter of a snapshot",
+		Description: "The Voters with the same VoterID are overwritten.",
+		Tag:         "admin",
+		Auth:        admin,
+		Body:        docstore.Snapshot[voter.Voter]{},
+		Response:    docstore.ImportResult{},
+	})
+	doc.Add(http.MethodGet, "/admin/audit", openapi.Operation{
+		ID:       "getAuditLog",
+		Summary:  "A page of the audit log, oldest first",
+		Tag:      "admin",
+		Auth:     admin,
+		Query:    openapi.AuditParams,
+		Response: audit.Page{},
+	})
+
+	return doc
+}
@@ -1,37 +1,121 @@
 package api
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"regexp"
-
+	"strconv"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/audit"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/changes"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/health"
+	"drexel.edu/common/paging"
+	"drexel.edu/common/requestid"
 	"drexel.edu/voter-api/voter"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 type VoterAPI struct {
 	voterList *voter.VoterList
+	audit     audit.Log
+	checks    []health.Check
+	changes   changes.Publisher
+	nonces    auth.Nonces
+	client    *redis.Client
+}
+
+// VoterPage is one page of GET /voters?cursor=&limit=, Next is the link to
+// the following page and is left out on the last page
+type VoterPage struct {
+	Items []voter.Voter
+	Next  string `json:",omitempty"`
 }
 
-func NewVoterApi(location string, votesAPIurl string) (*VoterAPI, error) {
-	voterListHandler, err := voter.New(location, votesAPIurl)
+// NewVoterApi returns a VoterAPI that keeps its Voters and its audit log in
+// the store backend, docstore.BackendRedis (connected to with redisOptions)
+// or docstore.BackendMemory
+func NewVoterApi(store string, redisOptions *redis.Options, votesAPIurl string) (*VoterAPI, error) {
+	if store == docstore.BackendMemory {
+		return &VoterAPI{
+			voterList: voter.NewInMemory(votesAPIurl),
+			audit:     audit.NewMemory(),
+			checks:    []health.Check{health.API(auth.ServiceVotesAPI, votesAPIurl)},
+			changes:   changes.NewNone(),
+			nonces:    auth.NewMemoryNonces(),
+		}, nil
+	}
+
+	client, jsonHelper, err := docstore.Connect(redisOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	return &VoterAPI{voterList: voterListHandler}, nil
+	return &VoterAPI{
+		voterList: voter.NewWithStore(docstore.NewRedis[voter.Voter](client, jsonHelper, voter.RedisKeyPrefix), votesAPIurl),
+		audit:     audit.NewRedis(client),
+		checks:    []health.Check{health.Redis(client), health.API(auth.ServiceVotesAPI, votesAPIurl)},
+		changes:   changes.NewRedis(client),
+		nonces:    auth.NewRedisNonces(client),
+		client:    client,
+	}, nil
+}
+
+// HealthChecks returns the checks of the dependencies of the VoterAPI for
+// GET /readyz: redis (unless the Voters are kept in memory) and the Votes API
+func (v *VoterAPI) HealthChecks() []health.Check {
+	return v.checks
+}
+
+// Nonces returns where the nonces of the signed requests are remembered, in
+// the same store as the Voters
+func (v *VoterAPI) Nonces() auth.Nonces {
+	return v.nonces
+}
+
+// Close closes the redis client of the VoterAPI, once it has stopped serving
+// requests
+func (v *VoterAPI) Close() error {
+	if v.client == nil {
+		return nil
+	}
+	return v.client.Close()
 }
 
 // THE API FUNCTIONS
 
 // implementation for GET /voters
-// returns all Voters
+// returns all Voters, or with ?limit= and/or ?cursor= a VoterPage
 func (v *VoterAPI) GetAllVoters(c *gin.Context) {
-	voters, err := v.voterList.GetAllVoters()
+
+	ctx := c.Request.Context()
+
+	paged, cursor, limit, err := paging.Params(c)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error reading the page parameters: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	if paged {
+		voters, next, err := v.voterList.GetVoters(ctx, cursor, limit)
+		if err != nil {
+			requestid.Logger(ctx).Println("Error getting a page of Voters: ", err)
+			apierror.Abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, VoterPage{Items: voters, Next: paging.NextLink(c.Request.URL.Path, next, limit)})
+		return
+	}
+
+	voters, err := v.voterList.GetAllVoters(ctx)
 	if err != nil {
-		log.Println("Error getting all Voters: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println("Error getting all Voters: ", err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -46,12 +130,14 @@ func (v *VoterAPI) GetAllVoters(c *gin.Context) {
 // returns a single Voter
 func (v *VoterAPI) GetVoter(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	idS := c.Request.URL.String()
 
-	voter, err := v.voterList.GetVoter(idS)
+	voter, err := v.voterList.GetVoter(ctx, idS)
 	if err != nil {
-		log.Println(fmt.Sprintf("Voter with the ID %v not found: ", idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Voter with the ID %v not found: ", idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -65,18 +151,20 @@ func (v *VoterAPI) GetVoter(c *gin.Context) {
 // if the user includes VoterID in the JSON it is simply overridden by the URL
 func (v *VoterAPI) AddVoter(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	var voter voter.Voter
 	if err := c.ShouldBindJSON(&voter); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
 		return
 	}
 
 	voter.VoterID = c.Request.URL.String()
 
-	if err := v.voterList.AddVoter(voter); err != nil {
-		log.Println(fmt.Sprintf("Error adding Voter with the ID %v: ", voter.VoterID), err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := v.audited(c, audit.ActionAdd, voter.VoterID, func() error { return v.voterList.AddVoter(ctx, voter) }); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error adding Voter with the ID %v: ", voter.VoterID), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -87,14 +175,16 @@ func (v *VoterAPI) AddVoter(c *gin.Context) {
 // returns the voting history (VoteHistory) for the Voter with ID id
 func (v *VoterAPI) GetVoteHistory(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re := regexp.MustCompile(`^/voters/\d+`)
 	idS := string(re.Find([]byte(url)))
 
-	voter, err := v.voterList.GetVoter(idS)
+	voter, err := v.voterList.GetVoter(ctx, idS)
 	if err != nil {
-		log.Println(fmt.Sprintf("Voter with the ID %v not found: ", idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Voter with the ID %v not found: ", idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -105,16 +195,18 @@ func (v *VoterAPI) GetVoteHistory(c *gin.Context) {
 // returns the poll data (voterPoll) for the Voter with ID id for voterPoll pollid
 func (v *VoterAPI) GetPollData(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re_id := regexp.MustCompile(`^/voters/\d+`)
 	idS := string(re_id.Find([]byte(url)))
 	re_pollid := regexp.MustCompile(`/polls/\d+$`)
 	pollidS := string(re_pollid.Find([]byte(url)))
 
-	poll, err := v.voterList.GetVoterPoll(idS, pollidS)
+	poll, err := v.voterList.GetVoterPoll(ctx, idS, pollidS)
 	if err != nil {
-		log.Println(fmt.Sprintf("Error finding PollID %v in Voter %v's VoteHistory: ", pollidS, idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error finding PollID %v in Voter %v's VoteHistory: ", pollidS, idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -130,6 +222,8 @@ func (v *VoterAPI) GetPollData(c *gin.Context) {
 // simply overridden by the PollID in the URL
 func (v *VoterAPI) AddPollData(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re_id := regexp.MustCompile(`^/voters/\d+`)
 	idS := string(re_id.Find([]byte(url)))
@@ -138,41 +232,41 @@ func (v *VoterAPI) AddPollData(c *gin.Context) {
 
 	var voter voter.Voter
 	if err := c.ShouldBindJSON(&voter); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
 		return
 	}
 
-	if err := v.voterList.AddVoterPoll(idS, pollidS, voter); err != nil {
-		log.Println("Error adding poll: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := v.audited(c, audit.ActionUpdate, idS, func() error { return v.voterList.AddVoterPoll(ctx, idS, pollidS, voter) }); err != nil {
+		requestid.Logger(ctx).Println("Error adding poll: ", err)
+		apierror.Abort(c, err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 }
 
-// implementation of GET /voters/health
-// returns the health of the Voter-API Application
-func (v *VoterAPI) GetHealth(c *gin.Context) {
-	c.JSON(http.StatusOK,
-		gin.H{
-			"status":  "ok",
-			"version": "3.0.0",
-		})
-}
-
 // Extra Credit Handlers
 
 // implementation for DELETE /voters/:id
-// deletes a Voter
+// deletes a Voter, a Voter that has cast Votes is only deleted with
+// ?cascade=true, which deletes their Votes as well
 func (v *VoterAPI) DeleteVoter(c *gin.Context) {
 
-	idS := c.Request.URL.String()
+	ctx := c.Request.Context()
+
+	idS := c.Request.URL.Path
+
+	cascade, err := strconv.ParseBool(c.DefaultQuery("cascade", "false"))
+	if err != nil {
+		requestid.Logger(ctx).Println("Error parsing cascade: ", err)
+		apierror.Abort(c, apierror.Validation("cascade", "cascade must be true or false, %q given.", c.Query("cascade")))
+		return
+	}
 
-	if err := v.voterList.DeleteVoter(idS); err != nil {
-		log.Println(fmt.Sprintf("Error deleting Voter with ID %v: ", idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+	if err := v.audited(c, audit.ActionDelete, idS, func() error { return v.voterList.DeleteVoter(ctx, idS, cascade) }); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error deleting Voter with ID %v: ", idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -183,16 +277,18 @@ func (v *VoterAPI) DeleteVoter(c *gin.Context) {
 // deletes the data (voterPoll) for the Voter with ID id and voterPoll pollid
 func (v *VoterAPI) DeletePollData(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re_id := regexp.MustCompile(`^/voters/\d+`)
 	idS := string(re_id.Find([]byte(url)))
 	re_pollid := regexp.MustCompile(`/polls/\d+$`)
 	pollidS := string(re_pollid.Find([]byte(url)))
 
-	err := v.voterList.DeleteVoterPoll(idS, pollidS)
+	err := v.audited(c, audit.ActionUpdate, idS, func() error { return v.voterList.DeleteVoterPoll(ctx, idS, pollidS) })
 	if err != nil {
-		log.Println(fmt.Sprintf("Error deleting %v from Voter %v's history: ", pollidS, idS), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error deleting %v from Voter %v's history: ", pollidS, idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -207,6 +303,8 @@ func (v *VoterAPI) DeletePollData(c *gin.Context) {
 // in the URL), if the user includes either of them in the JSON, they are overridden
 func (v *VoterAPI) UpdatePollData(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re_id := regexp.MustCompile(`^/voters/\d+`)
 	idS := string(re_id.Find([]byte(url)))
@@ -216,17 +314,130 @@ func (v *VoterAPI) UpdatePollData(c *gin.Context) {
 	var voter voter.Voter
 
 	if err := c.ShouldBindJSON(&voter); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
 		return
 	}
 
-	if err := v.voterList.UpdatePollData(idS, pollidS, voter); err != nil {
-		log.Println(fmt.Sprintf("Error updating poll in Voter %v's history: ", idS), err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := v.audited(c, audit.ActionUpdate, idS, func() error { return v.voterList.UpdatePollData(ctx, idS, pollidS, voter) }); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error updating poll in Voter %v's history: ", idS), err)
+		apierror.Abort(c, err)
 		return
 	}
 
 	c.Status(http.StatusOK)
 
 }
+
+// implementation for GET /admin/export
+// returns a snapshot of every Voter, which can be restored with
+// POST /admin/import
+func (v *VoterAPI) ExportVoters(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	snapshot, err := v.Export(ctx)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error exporting Voters: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// implementation for POST /admin/import
+// adds every Voter in a snapshot from GET /admin/export, overwriting the
+// Voters with the same VoterID
+func (v *VoterAPI) ImportVoters(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	var snapshot docstore.Snapshot[voter.Voter]
+	if err := c.ShouldBindJSON(&snapshot); err != nil {
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
+		return
+	}
+
+	imported, err := v.Import(ctx, snapshot, audit.ActorFrom(c))
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error importing Voters, %v were imported: ", imported), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, docstore.ImportResult{Kind: snapshot.Kind, Imported: imported})
+}
+
+// implementation for GET /admin/audit
+// returns a page of the audit log, see audit.Handler for the query
+// parameters
+func (v *VoterAPI) GetAuditLog(c *gin.Context) {
+	audit.Handler(v.audit)(c)
+}
+
+// Export returns a snapshot of every Voter, for GET /admin/export and the
+// export command
+func (v *VoterAPI) Export(ctx context.Context) (docstore.Snapshot[voter.Voter], error) {
+	return v.voterList.Export(ctx)
+}
+
+// Empty reports whether there are no Voters at all, the snapshot of -restore
+// is only imported then
+func (v *VoterAPI) Empty(ctx context.Context) (bool, error) {
+	voters, _, err := v.voterList.GetVoters(ctx, 0, 1)
+	return len(voters) == 0, err
+}
+
+// Import adds every Voter in the snapshot, for POST /admin/import and the
+// import command, and records the imported Voters as imported by actor
+func (v *VoterAPI) Import(ctx context.Context, snapshot docstore.Snapshot[voter.Voter], actor audit.Actor) (int, error) {
+
+	before := make([]any, len(snapshot.Items))
+	for i, item := range snapshot.Items {
+		if existing, err := v.voterList.GetVoter(ctx, item.VoterID); err == nil {
+			before[i] = existing
+		}
+	}
+
+	imported, err := v.voterList.Import(ctx, snapshot)
+	for i := 0; i < imported; i++ {
+		audit.Record(ctx, v.audit, actor, audit.EntityVoter, snapshot.Items[i].VoterID, audit.ActionImport, before[i], snapshot.Items[i])
+		v.publish(ctx, snapshot.Items[i].VoterID)
+	}
+
+	return imported, err
+}
+
+// audited makes the change to the Voter voterID and records it in the audit
+// log, along with the Voter before and after the change, and publishes it
+func (v *VoterAPI) audited(c *gin.Context, action string, voterID string, change func() error) error {
+
+	ctx := c.Request.Context()
+
+	var before, after any
+	if voter, err := v.voterList.GetVoter(ctx, voterID); err == nil {
+		before = voter
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	if voter, err := v.voterList.GetVoter(ctx, voterID); err == nil {
+		after = voter
+	}
+	audit.Record(ctx, v.audit, audit.ActorFrom(c), audit.EntityVoter, voterID, action, before, after)
+	v.publish(ctx, voterID)
+
+	return nil
+}
+
+// publish tells the Votes API that the Voter voterID changed, so that it
+// drops its cached copy
+func (v *VoterAPI) publish(ctx context.Context, voterID string) {
+	if err := v.changes.Publish(ctx, audit.EntityVoter, voterID); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error publishing the change to Voter %v: ", voterID), err)
+	}
+}
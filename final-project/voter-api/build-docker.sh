@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag voter-api:v3  -f ./dockerfile .
+docker build --build-arg COMMIT=$(git rev-parse --short HEAD) --tag voter-api:v3  -f ./dockerfile ..
@@ -1,94 +1,154 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
-
+	"time"
+
+	"drexel.edu/common/auth"
+	"drexel.edu/common/config"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/health"
+	"drexel.edu/common/metrics"
+	"drexel.edu/common/openapi"
+	"drexel.edu/common/requestid"
+	"drexel.edu/common/resilience"
+	"drexel.edu/common/server"
+	"drexel.edu/common/tracing"
 	"drexel.edu/voter-api/api"
+	"drexel.edu/voter-api/voter"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// version and commit are reported by GET /livez and GET /readyz, the
+// dockerfile sets them with -ldflags "-X main.version=... -X main.commit=..."
 var (
-	hostFlag    string
-	portFlag    uint
-	cacheURL    string
-	votesAPIURL string
+	version = "3.0.0"
+	commit  = ""
 )
 
-func processCmdLineFlags() {
-
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.StringVar(&votesAPIURL, "votesapi", "http://localhost:3080", "Default endpoint for the Votes API")
-	flag.StringVar(&cacheURL, "c", "0.0.0.0:6379", "Default cache location")
-	flag.UintVar(&portFlag, "p", 1080, "Default Port")
-
-	flag.Parse()
-}
+func main() {
 
-func envVarOrDefault(envVar string, defaultVal string) string {
-	envVal := os.Getenv(envVar)
-	if envVal != "" {
-		return envVal
+	cfg, err := config.Load(auth.ServiceVoterAPI)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
-	return defaultVal
-}
+	resilience.DefaultOptions.Timeout = time.Duration(cfg.Timeouts.Call)
 
-func setupParms() {
-	processCmdLineFlags()
-
-	cacheURL = envVarOrDefault("VOTERAPI_CACHE_URL", cacheURL)
-	votesAPIURL = envVarOrDefault("VOTERAPI_VOTES_API_URL", votesAPIURL)
-	hostFlag = envVarOrDefault("VOTERAPI_HOST", hostFlag)
-
-	pfNew, err := strconv.Atoi(envVarOrDefault("VOTERAPI_PORT", fmt.Sprintf("%d", portFlag)))
-
-	if err == nil {
-		portFlag = uint(pfNew)
+	apiHandler, err := api.NewVoterApi(cfg.Store, cfg.RedisOptions(), cfg.APIs.Votes)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-}
-
-func main() {
+	snapshots := docstore.SnapshotCommands[voter.Voter]{Service: "voter-api", Kind: docstore.KindVoters, API: apiHandler}
+	switch flag.Arg(0) {
+	case "export":
+		if err := snapshots.Export(flag.Args()[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	case "import":
+		if err := snapshots.Import(flag.Args()[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	setupParms()
-	log.Println("Init/cacheURL: " + cacheURL)
-	log.Println("Init/votesAPIURL: " + votesAPIURL)
-	log.Println("Init/hostFlag: " + hostFlag)
-	log.Printf("Init/portFlag: %d", portFlag)
+	if cfg.RestoreFile != "" {
+		snapshots.Restore(cfg.RestoreFile)
+	}
 
-	apiHandler, err := api.NewVoterApi(cacheURL, votesAPIURL)
+	authn, err := auth.FromEnv(apiHandler.Nonces())
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	r := gin.Default()
-	r.Use(cors.Default())
+	shutdownTracing, err := tracing.Init(auth.ServiceVoterAPI)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background())
+
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(requestid.LogFormatter), gin.Recovery())
+	r.Use(cors.New(cfg.CORS()))
+	r.Use(requestid.Middleware())
+	r.Use(tracing.Middleware(auth.ServiceVoterAPI))
+	r.Use(metrics.Middleware())
+
+	// Every request, and its response, is validated against the OpenAPI
+	// document, which has an operation for every route below (see Check)
+	doc := api.OpenAPI(version)
+	r.Use(doc.Middleware(cfg.OpenAPIValidation))
+
+	// The GET routes are open to everyone, the others need an admin. The
+	// VoteHistory is only written by the Votes API, with signed requests, so
+	// that it always matches the Votes.
+	admin := authn.Require()
+	votesAPI := authn.RequireService(auth.ServiceVotesAPI)
 
 	r.GET("/voters", apiHandler.GetAllVoters)
 
 	r.GET("/voters/:id", apiHandler.GetVoter)
-	r.POST("/voters/:id", apiHandler.AddVoter)
+	r.POST("/voters/:id", admin, apiHandler.AddVoter)
 
 	r.GET("/voters/:id/polls", apiHandler.GetVoteHistory)
 
 	r.GET("/voters/:id/polls/:pollid", apiHandler.GetPollData)
-	r.POST("/voters/:id/polls/:pollid", apiHandler.AddPollData)
+	r.POST("/voters/:id/polls/:pollid", votesAPI, apiHandler.AddPollData)
 
-	r.GET("/voters/health", apiHandler.GetHealth)
+	// /livez and /readyz are open, like /metrics, for compose and kubernetes
+	checker := health.New(auth.ServiceVoterAPI, version, commit, apiHandler.HealthChecks()...).
+		SetTimeout(time.Duration(cfg.Timeouts.Health)).
+		SetCircuits(resilience.States)
+	r.GET("/livez", checker.Livez)
+	r.GET("/readyz", checker.Readyz)
+	r.GET("/voters/health", checker.Readyz)
+	r.GET("/metrics", metrics.Handler())
 
 	// EXTRA CREDIT
 
-	r.DELETE("/voters/:id", apiHandler.DeleteVoter)
-	r.DELETE("/voters/:id/polls/:pollid", apiHandler.DeletePollData)
+	r.DELETE("/voters/:id", admin, apiHandler.DeleteVoter)
+	r.DELETE("/voters/:id/polls/:pollid", votesAPI, apiHandler.DeletePollData)
+
+	r.PUT("/voters/:id/polls/:pollid", votesAPI, apiHandler.UpdatePollData)
 
-	r.PUT("/voters/:id/polls/:pollid", apiHandler.UpdatePollData)
+	r.GET("/admin/export", admin, apiHandler.ExportVoters)
+	r.POST("/admin/import", admin, apiHandler.ImportVoters)
+	r.GET("/admin/audit", admin, apiHandler.GetAuditLog)
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	// The OpenAPI document and its Swagger UI page are open to everyone
+	r.GET("/openapi.json", doc.Handler())
+	r.GET("/docs", openapi.SwaggerUI)
+	r.GET("/docs/*file", openapi.SwaggerUI)
+
+	if err := doc.Check(r.Routes()); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// On SIGTERM /readyz fails first, then the requests in flight are given
+	// time to finish before redis is closed
+	err = server.Run(r, checker, server.Options{
+		Addr:    cfg.Addr(),
+		Delay:   time.Duration(cfg.Timeouts.ShutdownDelay),
+		Timeout: time.Duration(cfg.Timeouts.Shutdown),
+	})
+	if err != nil {
+		log.Println("Error serving: ", err)
+	}
+	if err := apiHandler.Close(); err != nil {
+		log.Println("Error closing redis: ", err)
+	}
 }
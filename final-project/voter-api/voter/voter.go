@@ -2,134 +2,88 @@ package voter
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
-	"time"
 
-	"github.com/go-redis/redis/v8"
-	"github.com/go-resty/resty/v2"
-	"github.com/nitishm/go-rejson/v4"
+	"drexel.edu/client"
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
 )
 
 // VOTER STRUCTS
 
-type voterPoll struct {
-	PollID   string
-	VoteDate time.Time
-}
+// The Voter and its voterPolls are the models of the client module, the
+// ones the Votes API reads them into
+type voterPoll = client.VoterPoll
 
-type Voter struct {
-	VoterID     string
-	FirstName   string `json:",omitempty"`
-	LastName    string `json:",omitempty"`
-	VoteHistory []voterPoll
-}
+type Voter = client.Voter
 
 func NewVoter(voterID string, first string, last string) (*Voter, error) {
 	voteHistory := make([]voterPoll, 0)
 	return &Voter{VoterID: voterID, FirstName: first, LastName: last, VoteHistory: voteHistory}, nil
 }
 
-// Additional STRUCTS
-
-type Poll struct {
-	PollID       string
-	PollTitle    string `json:",omitempty"`
-	PollQuestion string `json:",omitempty"`
-	PollOptions  []pollOption
-}
-
-type pollOption struct {
-	PollOptionID   string
-	PollOptionText string
-}
-
-type Vote struct {
-	VoteID    string
-	VoterID   string
-	PollID    string
-	VoteValue string
-}
-
 const (
-	RedisNilError        = "redis: nil"
 	RedisDefaultLocation = "0.0.0.0:6379"
 	RedisKeyPrefix       = "voter:"
+	RedisScanCount       = 100
+)
+
+// The errors returned by the VoterList, each wraps the apierror kind that
+// says which HTTP status it maps to
+var (
+	ErrVoterNotFound     = fmt.Errorf("Voter %w", apierror.ErrNotFound)
+	ErrVoterExists       = fmt.Errorf("Voter %w", apierror.ErrConflict)
+	ErrVoterPollNotFound = fmt.Errorf("voterPoll %w", apierror.ErrNotFound)
+	ErrVoterPollExists   = fmt.Errorf("voterPoll %w", apierror.ErrConflict)
+	ErrInvalidHistory    = fmt.Errorf("VoteHistory %w", apierror.ErrValidation)
+	ErrPollNotFound      = fmt.Errorf("Poll %w", apierror.ErrNotFound)
+	ErrVoterHasVotes     = fmt.Errorf("Voter has Votes, a deletion %w", apierror.ErrConflict)
 )
 
-type cache struct {
-	cacheClient *redis.Client
-	jsonHelper  *rejson.Handler
-	context     context.Context
+// VoterStore is where a VoterList keeps its Voters, by VoterID. The redis and
+// in-memory implementations are in drexel.edu/common/docstore, and Get
+// returns docstore.ErrNotFound when there is no Voter with the VoterID.
+type VoterStore interface {
+	Get(ctx context.Context, voterID string) (Voter, error)
+	Set(ctx context.Context, voterID string, voter Voter) error
+	Delete(ctx context.Context, voterID string) (bool, error)
+	List(ctx context.Context, cursor uint64, limit int64) ([]Voter, uint64, error)
 }
 
 type VoterList struct {
-	cache
-	votesAPIURL string
-	apiClient   *resty.Client
+	store VoterStore
+	// the Votes API is asked whether a Poll exists before it is added to a
+	// VoteHistory, and for the Votes of a Voter before they are deleted
+	votes *client.VotesService
 }
 
-func New(location string, votesAPIurl string) (*VoterList, error) {
-	return NewWithCacheInstance(location, votesAPIurl)
+// NewInMemory returns a VoterList that keeps its Voters in memory rather than
+// in redis, they are lost when the process exits
+func NewInMemory(votesAPIurl string) *VoterList {
+	return NewWithStore(docstore.NewMemory[Voter](), votesAPIurl)
 }
 
-func NewWithCacheInstance(location string, votesAPIurl string) (*VoterList, error) {
-
-	apiClient := resty.New()
-
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
-
-	ctx := context.Background()
-
-	err := client.Ping(ctx).Err()
-	if err != nil {
-		log.Println("Error connecting to redis" + err.Error())
-		return nil, err
-	}
-
-	jsonHelper := rejson.NewReJSONHandler()
-	jsonHelper.SetGoRedisClientWithContext(ctx, client)
-
-	//Return a pointer to a new PollList struct
+func NewWithStore(store VoterStore, votesAPIurl string) *VoterList {
 	return &VoterList{
-		cache: cache{
-			cacheClient: client,
-			jsonHelper:  jsonHelper,
-			context:     ctx,
-		},
-		votesAPIURL: votesAPIurl,
-		apiClient:   apiClient,
-	}, nil
+		store: store,
+		votes: client.New(client.Config{VotesAPI: votesAPIurl, HTTP: auth.ServiceClient(auth.ServiceVoterAPI)}).Votes,
+	}
 }
 
 //------------------------------------------------------------
-// REDIS HELPERS
+// STORE HELPERS
 //------------------------------------------------------------
 
-func isRedisNilError(err error) bool {
-	return errors.Is(err, redis.Nil) || err.Error() == RedisNilError
-}
-
-func redisKeyFromId(id string) string {
-	return fmt.Sprintf("%s%s", RedisKeyPrefix, id)
-}
-
-func (vl *VoterList) getItemFromRedis(key string, voter *Voter) error {
-
-	itemObject, err := vl.jsonHelper.JSONGet(key, ".")
-	if err != nil {
-		return err
-	}
+func (vl *VoterList) getItem(ctx context.Context, voterID string, voter *Voter) error {
 
-	err = json.Unmarshal(itemObject.([]byte), voter)
+	stored, err := vl.store.Get(ctx, voterID)
 	if err != nil {
 		return err
 	}
 
+	*voter = stored
 	return nil
 }
 
@@ -138,32 +92,44 @@ func (vl *VoterList) getItemFromRedis(key string, voter *Voter) error {
 //------------------------------------------------------------
 
 // returns all Voters (as a Slice)
-func (vl *VoterList) GetAllVoters() ([]Voter, error) {
+func (vl *VoterList) GetAllVoters(ctx context.Context) ([]Voter, error) {
 
 	var voters []Voter
 
-	//Lets query redis for all of the items
-	key := RedisKeyPrefix + "*"
-	ks, _ := vl.cacheClient.Keys(vl.context, key).Result()
-	for _, key := range ks {
-		var voter Voter
-		err := vl.getItemFromRedis(key, &voter)
+	//Page through all of the items, rather than using KEYS which
+	//blocks redis while it walks the whole keyspace
+	var cursor uint64
+	for {
+		page, next, err := vl.GetVoters(ctx, cursor, RedisScanCount)
 		if err != nil {
 			return nil, err
 		}
-		voters = append(voters, voter)
+		voters = append(voters, page...)
+		if next == 0 {
+			return voters, nil
+		}
+		cursor = next
 	}
+}
 
-	return voters, nil
+// returns a page of Voters starting at cursor, along with the cursor of the
+// next page (0 when there are no more Voters). In redis, like the SCAN command it
+// is built on, a page can hold slightly more or fewer than limit Voters, and
+// a Voter that is added or deleted while paging may or may not be returned.
+func (vl *VoterList) GetVoters(ctx context.Context, cursor uint64, limit int64) ([]Voter, uint64, error) {
+
+	return vl.store.List(ctx, cursor, limit)
 }
 
 // returns the Voter with the VoterID voterID
-func (vl *VoterList) GetVoter(voterID string) (Voter, error) {
+func (vl *VoterList) GetVoter(ctx context.Context, voterID string) (Voter, error) {
 
 	var voter Voter
-	key := redisKeyFromId(voterID)
-	err := vl.getItemFromRedis(key, &voter)
+	err := vl.getItem(ctx, voterID, &voter)
 	if err != nil {
+		if errors.Is(err, docstore.ErrNotFound) {
+			return Voter{}, apierror.Wrap(ErrVoterNotFound, "VoterID", "Voter with ID %v does not exist.", voterID)
+		}
 		return Voter{}, err
 	}
 
@@ -172,19 +138,18 @@ func (vl *VoterList) GetVoter(voterID string) (Voter, error) {
 
 // AddVoter accepts a Voter and adds it to Voters.
 // its VoteHistory is always initialized to an empty slice
-func (vl *VoterList) AddVoter(voter Voter) error {
+func (vl *VoterList) AddVoter(ctx context.Context, voter Voter) error {
 
-	key := redisKeyFromId(voter.VoterID)
 	var existingVoter Voter
-	if err := vl.getItemFromRedis(key, &existingVoter); err == nil {
-		return errors.New(fmt.Sprintf("A Voter with the ID %v already exists.", voter.VoterID))
+	if err := vl.getItem(ctx, voter.VoterID, &existingVoter); err == nil {
+		return apierror.Wrap(ErrVoterExists, "VoterID", "A Voter with the ID %v already exists.", voter.VoterID)
 	}
 
 	if voter.VoteHistory == nil || len(voter.VoteHistory) > 0 {
 		voter.VoteHistory = make([]voterPoll, 0)
 	}
 
-	if _, err := vl.jsonHelper.JSONSet(key, ".", voter); err != nil {
+	if err := vl.store.Set(ctx, voter.VoterID, voter); err != nil {
 		return err
 	}
 
@@ -192,15 +157,14 @@ func (vl *VoterList) AddVoter(voter Voter) error {
 }
 
 // returns the Voter's voterPoll where the PollID matches pollID
-func (vl *VoterList) GetVoterPoll(voterID, pollID string) (voterPoll, error) {
-	key := redisKeyFromId(voterID)
+func (vl *VoterList) GetVoterPoll(ctx context.Context, voterID, pollID string) (voterPoll, error) {
 	var voter Voter
-	if err := vl.getItemFromRedis(key, &voter); err != nil {
-		return voterPoll{}, errors.New(fmt.Sprintf("Voter with ID %v does not exist.", voterID))
+	if err := vl.getItem(ctx, voterID, &voter); err != nil {
+		return voterPoll{}, apierror.Wrap(ErrVoterNotFound, "VoterID", "Voter with ID %v does not exist.", voterID)
 	}
 
 	if len(voter.VoteHistory) == 0 {
-		return voterPoll{}, errors.New(fmt.Sprintf("Poll with ID %v not found in voter %v's history.", pollID, voterID))
+		return voterPoll{}, apierror.Wrap(ErrVoterPollNotFound, "PollID", "Poll with ID %v not found in voter %v's history.", pollID, voterID)
 	}
 
 	relevantPolls := make([]voterPoll, 0)
@@ -211,7 +175,7 @@ func (vl *VoterList) GetVoterPoll(voterID, pollID string) (voterPoll, error) {
 		}
 	}
 	if len(relevantPolls) == 0 {
-		return voterPoll{}, errors.New(fmt.Sprintf("Poll with ID %v not found in voter %v's history.", pollID, voterID))
+		return voterPoll{}, apierror.Wrap(ErrVoterPollNotFound, "PollID", "Poll with ID %v not found in voter %v's history.", pollID, voterID)
 	} else if len(relevantPolls) > 1 {
 		return voterPoll{}, errors.New(fmt.Sprintf("There is an error with the internal state. Multiple instances of voterPoll with ID %v in Voter %v's VoteHistory.", pollID, voterID))
 	} else {
@@ -221,16 +185,15 @@ func (vl *VoterList) GetVoterPoll(voterID, pollID string) (voterPoll, error) {
 }
 
 // AddVoterPoll accepts the voterID, the pollID and a new Voter and adds the voterPoll to the Voter's VoteHistory
-func (vl *VoterList) AddVoterPoll(voterID, pollID string, newVoter Voter) error {
+func (vl *VoterList) AddVoterPoll(ctx context.Context, voterID, pollID string, newVoter Voter) error {
 
-	key := redisKeyFromId(voterID)
 	var existingVoter Voter
-	if err := vl.getItemFromRedis(key, &existingVoter); err != nil {
-		return errors.New(fmt.Sprintf("Voter with ID %v does not exist.", voterID))
+	if err := vl.getItem(ctx, voterID, &existingVoter); err != nil {
+		return apierror.Wrap(ErrVoterNotFound, "VoterID", "Voter with ID %v does not exist.", voterID)
 	}
 
 	if len(newVoter.VoteHistory) > 1 || len(newVoter.VoteHistory) == 0 {
-		return errors.New(fmt.Sprintf("Only allowed to add one new voterPoll at a time, and %v given.", len(newVoter.VoteHistory)))
+		return apierror.Wrap(ErrInvalidHistory, "VoteHistory", "Only allowed to add one new voterPoll at a time, and %v given.", len(newVoter.VoteHistory))
 	}
 
 	poll := newVoter.VoteHistory[0]
@@ -239,12 +202,11 @@ func (vl *VoterList) AddVoterPoll(voterID, pollID string, newVoter Voter) error
 
 	// Query Votes API to ensure that the Poll exists
 
-	URL := vl.votesAPIURL + "/votes" + poll.PollID
-	var pollTwo Poll
-
-	resp, err := vl.apiClient.R().SetResult(&pollTwo).Get(URL)
-	if err != nil || resp.Status() != "200 OK" {
-		return errors.New(fmt.Sprintf("Could not get Poll %v from Votes API (Poll API)", poll.PollID))
+	if _, err := vl.votes.Poll(ctx, poll.PollID); err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return apierror.Wrap(ErrPollNotFound, "PollID", "Poll %v does not exist in the Poll API.", poll.PollID)
+		}
+		return apierror.Call(err, "PollID", "Could not get Poll %v from Votes API (Poll API): %v", poll.PollID, err)
 	}
 
 	// Check if the Poll
@@ -253,39 +215,58 @@ func (vl *VoterList) AddVoterPoll(voterID, pollID string, newVoter Voter) error
 		for i := 0; i < len(existingVoter.VoteHistory); i++ {
 			currPoll := existingVoter.VoteHistory[i]
 			if currPoll.PollID == poll.PollID {
-				return errors.New(fmt.Sprintf("Poll with ID %v already exists in Voter %v's VoteHistory. Use PUT to update the voterPoll.", poll.PollID, voterID))
+				return apierror.Wrap(ErrVoterPollExists, "PollID", "Poll with ID %v already exists in Voter %v's VoteHistory. Use PUT to update the voterPoll.", poll.PollID, voterID)
 			}
 		}
 	}
 	existingVoter.VoteHistory = append(existingVoter.VoteHistory, poll)
-	if _, err := vl.jsonHelper.JSONSet(key, ".", existingVoter); err != nil {
+	if err := vl.store.Set(ctx, voterID, existingVoter); err != nil {
 		return err
 	}
 	return nil
 }
 
-// deletes the Voter with the VoterID voterID from Voters
-func (vl *VoterList) DeleteVoter(voterID string) error {
+// deletes the Voter with the VoterID voterID from Voters. A Voter that has
+// cast Votes is only deleted if cascade is set, in which case their Votes are
+// deleted (through the Votes API) first.
+func (vl *VoterList) DeleteVoter(ctx context.Context, voterID string, cascade bool) error {
+
+	var voter Voter
+	if err := vl.getItem(ctx, voterID, &voter); err != nil {
+		return apierror.Wrap(ErrVoterNotFound, "VoterID", "An voter with the ID %v does not exist, thus they cannot be removed.", voterID)
+	}
+
+	if cascade {
+		if err := vl.deleteVoterVotes(ctx, voterID); err != nil {
+			return err
+		}
+	} else {
+		votes, err := vl.countVoterVotes(ctx, voterID)
+		if err != nil {
+			return err
+		}
+		if votes > 0 {
+			return apierror.Wrap(ErrVoterHasVotes, "VoterID", "Voter %v has %v Vote(s), use ?cascade=true to delete them as well.", voterID, votes)
+		}
+	}
 
-	key := redisKeyFromId(voterID)
-	numDeleted, err := vl.cacheClient.Del(vl.context, key).Result()
+	deleted, err := vl.store.Delete(ctx, voterID)
 	if err != nil {
 		return err
 	}
-	if numDeleted == 0 {
-		return errors.New(fmt.Sprintf("An voter with the ID %v does not exist, thus they cannot be removed.", voterID))
+	if !deleted {
+		return apierror.Wrap(ErrVoterNotFound, "VoterID", "An voter with the ID %v does not exist, thus they cannot be removed.", voterID)
 	}
 
 	return nil
 }
 
 // deletes the voterPoll with the PollID pollID from the Voter voterID
-func (vl *VoterList) DeleteVoterPoll(voterID, pollID string) error {
+func (vl *VoterList) DeleteVoterPoll(ctx context.Context, voterID, pollID string) error {
 
-	key := redisKeyFromId(voterID)
 	var voter Voter
-	if err := vl.getItemFromRedis(key, &voter); err != nil {
-		return errors.New(fmt.Sprintf("Voter with ID %v does not exist.", voterID))
+	if err := vl.getItem(ctx, voterID, &voter); err != nil {
+		return apierror.Wrap(ErrVoterNotFound, "VoterID", "Voter with ID %v does not exist.", voterID)
 	}
 
 	i := -1
@@ -297,12 +278,12 @@ func (vl *VoterList) DeleteVoterPoll(voterID, pollID string) error {
 	}
 
 	if i == -1 {
-		return errors.New(fmt.Sprintf("Poll with ID %v does not exist in Voter %v's VoteHistory", pollID, voter.VoterID))
+		return apierror.Wrap(ErrVoterPollNotFound, "PollID", "Poll with ID %v does not exist in Voter %v's VoteHistory", pollID, voter.VoterID)
 	}
 
 	voter.VoteHistory = append(voter.VoteHistory[:i], voter.VoteHistory[i+1:]...)
 
-	if _, err := vl.jsonHelper.JSONSet(key, ".", voter); err != nil {
+	if err := vl.store.Set(ctx, voterID, voter); err != nil {
 		return err
 	} else {
 		return nil
@@ -310,16 +291,15 @@ func (vl *VoterList) DeleteVoterPoll(voterID, pollID string) error {
 }
 
 // updates an existing voterPoll in Voter voterID's VoteHistory
-func (vl *VoterList) UpdatePollData(voterID, pollID string, newVoter Voter) error {
+func (vl *VoterList) UpdatePollData(ctx context.Context, voterID, pollID string, newVoter Voter) error {
 
-	key := redisKeyFromId(voterID)
 	var voter Voter
-	if err := vl.getItemFromRedis(key, &voter); err != nil {
-		return errors.New(fmt.Sprintf("Voter with ID %v does not exist.", voterID))
+	if err := vl.getItem(ctx, voterID, &voter); err != nil {
+		return apierror.Wrap(ErrVoterNotFound, "VoterID", "Voter with ID %v does not exist.", voterID)
 	}
 
 	if len(newVoter.VoteHistory) > 1 || len(newVoter.VoteHistory) == 0 {
-		return errors.New(fmt.Sprintf("Only allowed to update one voterPoll at a time, and %v given.", len(newVoter.VoteHistory)))
+		return apierror.Wrap(ErrInvalidHistory, "VoteHistory", "Only allowed to update one voterPoll at a time, and %v given.", len(newVoter.VoteHistory))
 	}
 
 	newPoll := newVoter.VoteHistory[0]
@@ -329,7 +309,7 @@ func (vl *VoterList) UpdatePollData(voterID, pollID string, newVoter Voter) erro
 	for index, currPoll := range voter.VoteHistory {
 		if currPoll.PollID == newPoll.PollID {
 			voter.VoteHistory[index] = newPoll
-			if _, err := vl.jsonHelper.JSONSet(key, ".", voter); err != nil {
+			if err := vl.store.Set(ctx, voterID, voter); err != nil {
 				return err
 			} else {
 				return nil
@@ -337,5 +317,74 @@ func (vl *VoterList) UpdatePollData(voterID, pollID string, newVoter Voter) erro
 		}
 	}
 
-	return errors.New(fmt.Sprintf("Poll with ID %v does not exist in Voter %v's VoteHistory", newPoll.PollID, voterID))
+	return apierror.Wrap(ErrVoterPollNotFound, "PollID", "Poll with ID %v does not exist in Voter %v's VoteHistory", newPoll.PollID, voterID)
+}
+
+//------------------------------------------------------------
+// SNAPSHOTS
+//------------------------------------------------------------
+
+// returns a snapshot of every Voter
+func (vl *VoterList) Export(ctx context.Context) (docstore.Snapshot[Voter], error) {
+
+	voters, err := vl.GetAllVoters(ctx)
+	if err != nil {
+		return docstore.Snapshot[Voter]{}, err
+	}
+
+	return docstore.NewSnapshot(docstore.KindVoters, voters), nil
+}
+
+// adds every Voter in the snapshot to Voters, overwriting the Voters that
+// have the same VoterID, and returns how many were imported. Nothing is
+// imported unless every Voter has a VoterID.
+func (vl *VoterList) Import(ctx context.Context, snapshot docstore.Snapshot[Voter]) (int, error) {
+
+	if err := snapshot.Check(docstore.KindVoters); err != nil {
+		return 0, apierror.Validation("", "Invalid snapshot: %v", err)
+	}
+
+	for i, voter := range snapshot.Items {
+		if voter.VoterID == "" {
+			return 0, apierror.Validation("VoterID", "Voter %v in the snapshot has no VoterID.", i)
+		}
+	}
+
+	for i, voter := range snapshot.Items {
+		if voter.VoteHistory == nil {
+			voter.VoteHistory = make([]voterPoll, 0)
+		}
+		if err := vl.store.Set(ctx, voter.VoterID, voter); err != nil {
+			return i, err
+		}
+	}
+
+	return len(snapshot.Items), nil
+}
+
+//------------------------------------------------------------
+// VOTES API HELPERS
+//------------------------------------------------------------
+
+// returns the number of Votes the Voter voterID has cast, according to the
+// Votes API
+func (vl *VoterList) countVoterVotes(ctx context.Context, voterID string) (int, error) {
+
+	votes, err := vl.votes.ForVoter(ctx, voterID)
+	if err != nil {
+		return 0, apierror.Call(err, "VoterID", "Could not get the Votes of Voter %v from the Votes API: %v", voterID, err)
+	}
+
+	return len(votes), nil
+}
+
+// deletes the Votes the Voter voterID has cast through the Votes API, which
+// also removes them from the Voter's VoteHistory
+func (vl *VoterList) deleteVoterVotes(ctx context.Context, voterID string) error {
+
+	if err := vl.votes.DeleteForVoter(ctx, voterID); err != nil {
+		return apierror.Call(err, "VoterID", "Could not delete the Votes of Voter %v through the Votes API: %v", voterID, err)
+	}
+
+	return nil
 }
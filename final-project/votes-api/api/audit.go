@@ -0,0 +1,14 @@
+package api
+
+import (
+	"drexel.edu/common/audit"
+	"github.com/gin-gonic/gin"
+)
+
+// /votes/admin/audit
+// returns a page of the audit log, every add, update, delete and import of
+// the Votes and (when the APIs share redis) of the Polls and Voters, see
+// audit.Handler for the query parameters
+func (v *VotesAPI) GetAuditLog(c *gin.Context) {
+	audit.Handler(v.audit)(c)
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"drexel.edu/common/audit"
+	"drexel.edu/common/changes"
+	"drexel.edu/votes-api/schema"
+	"github.com/go-redis/redis/v8"
+)
+
+// Every Vote that is cast or changed needs its Voter and its Poll, and the
+// /votes/polls/... and /votes/voters/... routes relay them, so rather than
+// GET them from the other APIs every time the VotesAPI keeps a copy of the
+// ones it fetched. The Poll API and Voter API publish every change to a Poll
+// or Voter (see the changes package) and the copy is dropped right away, and
+// in any case it expires after CacheTTL, e.g. if a change was published while
+// redis was unreachable. Without that feed of changes, when the Votes are kept
+// in memory or the subscription fails, nothing is cached. A Vote is only cast
+// or changed after the Poll is fetched again, so that a Poll that was just
+// closed can't be voted in.
+
+// CacheTTL is how long a Poll or Voter is kept in the cache
+const CacheTTL = 30 * time.Second
+
+// CacheSize is how many Polls, and how many Voters, are kept in the cache at
+// most, the least recently used are dropped first
+const CacheSize = 1000
+
+// cache keeps the values fetched with load, by ID, for ttl. It keeps at most
+// size of them, dropping the least recently used. The hits and misses are
+// counted in the cache_lookups_total metric. With a ttl of 0 nothing is kept.
+type cache[T any] struct {
+	name string
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	loads   map[string]*cacheLoad
+}
+
+type cacheEntry[T any] struct {
+	id      string
+	value   T
+	expires time.Time
+}
+
+// cacheLoad counts the loads of an ID in flight, along with the generation
+// of the ID they started in. invalidate bumps the generation, so that a load
+// that started before the change isn't kept.
+type cacheLoad struct {
+	generation uint64
+	inFlight   int
+}
+
+func newCache[T any](name string, ttl time.Duration, size int) *cache[T] {
+	return &cache[T]{
+		name:    name,
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		loads:   make(map[string]*cacheLoad),
+	}
+}
+
+// get returns the value of id, from the cache if it is there and has not
+// expired, otherwise from load. Errors are not cached, so a Poll or Voter
+// that does not exist is fetched again the next time.
+func (c *cache[T]) get(id string, load func() (T, error)) (T, error) {
+
+	if c.ttl == 0 {
+		return load()
+	}
+
+	value, ok, generation := c.lookup(id)
+	if ok {
+		cacheLookups.WithLabelValues(c.name, "hit").Inc()
+		return value, nil
+	}
+	cacheLookups.WithLabelValues(c.name, "miss").Inc()
+
+	value, err := load()
+	c.loaded(id, generation, value, err)
+	return value, err
+}
+
+// lookup returns the value of id if it is cached. If it isn't a load of id is
+// started, and the generation it starts in is returned, loaded has to be
+// called once it is done.
+func (c *cache[T]) lookup(id string) (T, bool, uint64) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero T
+	if element, ok := c.entries[id]; ok {
+		entry := element.Value.(*cacheEntry[T])
+		if !time.Now().After(entry.expires) {
+			c.lru.MoveToFront(element)
+			return entry.value, true, 0
+		}
+		c.remove(element)
+	}
+
+	load, ok := c.loads[id]
+	if !ok {
+		load = &cacheLoad{}
+		c.loads[id] = load
+	}
+	load.inFlight++
+	return zero, false, load.generation
+}
+
+// loaded ends a load of id that started in generation, and adds its value
+// unless the load failed or id was invalidated in the meantime
+func (c *cache[T]) loaded(id string, generation uint64, value T, err error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	load := c.loads[id]
+	load.inFlight--
+	if load.inFlight == 0 {
+		delete(c.loads, id)
+	}
+	if err != nil || load.generation != generation {
+		return
+	}
+
+	if element, ok := c.entries[id]; ok {
+		c.remove(element)
+	}
+	c.entries[id] = c.lru.PushFront(&cacheEntry[T]{id: id, value: value, expires: time.Now().Add(c.ttl)})
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		cacheEvictions.WithLabelValues(c.name).Inc()
+	}
+	cacheEntries.WithLabelValues(c.name).Set(float64(c.lru.Len()))
+}
+
+// invalidate drops the value of id, so that it is fetched again, along with
+// the values of the loads of id in flight, which may predate the change
+func (c *cache[T]) invalidate(id string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		c.remove(element)
+	}
+	if load, ok := c.loads[id]; ok {
+		load.generation++
+	}
+}
+
+// remove drops the element, c.mu must be held
+func (c *cache[T]) remove(element *list.Element) {
+	c.lru.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry[T]).id)
+	cacheEntries.WithLabelValues(c.name).Set(float64(c.lru.Len()))
+}
+
+// getPoll returns the Poll pollID, from the cache or the Poll API
+func (v *VotesAPI) getPoll(ctx context.Context, pollID string) (schema.Poll, error) {
+	return v.polls.get(pollID, func() (schema.Poll, error) {
+		poll, err := v.apis.Polls.Get(ctx, pollID)
+		if err != nil {
+			return poll, getError(err, "PollID", pollID)
+		}
+		return poll, nil
+	})
+}
+
+// getPollFresh returns the Poll pollID from the Poll API, never from the
+// cache, for the checks a Vote is cast or changed on
+func (v *VotesAPI) getPollFresh(ctx context.Context, pollID string) (schema.Poll, error) {
+	poll, err := v.apis.Polls.Get(ctx, pollID)
+	if err != nil {
+		return poll, getError(err, "PollID", pollID)
+	}
+	return poll, nil
+}
+
+// getVoter returns the Voter voterID, from the cache or the Voter API
+func (v *VotesAPI) getVoter(ctx context.Context, voterID string) (schema.Voter, error) {
+	return v.voters.get(voterID, func() (schema.Voter, error) {
+		voter, err := v.apis.Voters.Get(ctx, voterID)
+		if err != nil {
+			return voter, getError(err, "VoterID", voterID)
+		}
+		return voter, nil
+	})
+}
+
+// invalidateOnChanges turns the cache on, and drops the Polls and Voters the
+// Poll API and Voter API publish changes to from it, until the VotesAPI is
+// closed. If the subscription fails the cache stays off. It is called before
+// the VotesAPI serves any request.
+func (v *VotesAPI) invalidateOnChanges(client *redis.Client) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	v.stopChanges = cancel
+
+	changed, err := changes.Subscribe(ctx, client, audit.EntityPoll, audit.EntityVoter)
+	if err != nil {
+		log.Println("Error subscribing to the changes to Polls and Voters, they are not cached: ", err)
+		return
+	}
+
+	v.polls = newCache[schema.Poll](audit.EntityPoll, CacheTTL, CacheSize)
+	v.voters = newCache[schema.Voter](audit.EntityVoter, CacheTTL, CacheSize)
+
+	go func() {
+		for change := range changed {
+			switch change.Entity {
+			case audit.EntityPoll:
+				v.polls.invalidate(change.ID)
+			case audit.EntityVoter:
+				v.voters.invalidate(change.ID)
+			}
+		}
+	}()
+}
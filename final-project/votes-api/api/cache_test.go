@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"drexel.edu/common/audit"
+	"drexel.edu/votes-api/schema"
+)
+
+// withCache turns the cache of Polls and Voters on, as invalidateOnChanges
+// does once it is subscribed to the changes
+func withCache(v *VotesAPI) {
+	v.polls = newCache[schema.Poll](audit.EntityPoll, CacheTTL, CacheSize)
+	v.voters = newCache[schema.Voter](audit.EntityVoter, CacheTTL, CacheSize)
+}
+
+func relayedTitle(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var poll schema.Poll
+	if err := json.Unmarshal(w.Body.Bytes(), &poll); err != nil {
+		t.Fatal(err)
+	}
+	return poll.PollTitle
+}
+
+// Without a feed of changes, as when the Votes are kept in memory, every
+// Poll is fetched from the Poll API
+func TestCacheIsOffWithoutChanges(t *testing.T) {
+
+	f := newFakeAPIs(t, []schema.Poll{openPoll("/polls/1", schema.PollTypeSingle)}, nil)
+	_, r := newTestVotesAPI(t, f)
+
+	for i := 0; i < 3; i++ {
+		if w := serve(r, http.MethodGet, "/votes/polls/1", ""); w.Code != http.StatusOK {
+			t.Fatalf("GET /votes/polls/1 = %v: %v", w.Code, w.Body)
+		}
+	}
+	if fetched := f.fetched("/polls/1"); fetched != 3 {
+		t.Errorf("the Poll was fetched %v times, want 3", fetched)
+	}
+}
+
+// A cached Poll is served until a change to it is published
+func TestCacheDropsAChangedPoll(t *testing.T) {
+
+	poll := openPoll("/polls/1", schema.PollTypeSingle)
+	poll.PollTitle = "Lunch"
+	f := newFakeAPIs(t, []schema.Poll{poll}, nil)
+	v, r := newTestVotesAPI(t, f)
+	withCache(v)
+
+	serve(r, http.MethodGet, "/votes/polls/1", "")
+	poll.PollTitle = "Dinner"
+	f.setPoll(poll)
+
+	if title := relayedTitle(t, serve(r, http.MethodGet, "/votes/polls/1", "")); title != "Lunch" || f.fetched("/polls/1") != 1 {
+		t.Errorf("before the change was published: %q after %v fetches, want the cached Lunch", title, f.fetched("/polls/1"))
+	}
+
+	v.polls.invalidate("/polls/1")
+	if title := relayedTitle(t, serve(r, http.MethodGet, "/votes/polls/1", "")); title != "Dinner" || f.fetched("/polls/1") != 2 {
+		t.Errorf("after the change was published: %q after %v fetches, want Dinner fetched again", title, f.fetched("/polls/1"))
+	}
+}
+
+// A Poll that doesn't exist isn't cached, so it is found once it is added
+func TestCacheDoesNotKeepErrors(t *testing.T) {
+
+	f := newFakeAPIs(t, nil, nil)
+	v, r := newTestVotesAPI(t, f)
+	withCache(v)
+
+	if w := serve(r, http.MethodGet, "/votes/polls/1", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("GET /votes/polls/1 = %v, want 404", w.Code)
+	}
+	f.setPoll(openPoll("/polls/1", schema.PollTypeSingle))
+	if w := serve(r, http.MethodGet, "/votes/polls/1", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /votes/polls/1 once it was added = %v, want 200", w.Code)
+	}
+}
+
+// A Vote is checked against the Poll as it is now, even while an open copy
+// of it is cached, so a Poll that was just closed can't be voted in
+func TestVotesIgnoreTheCachedPoll(t *testing.T) {
+
+	poll := openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1")
+	f := newFakeAPIs(t, []schema.Poll{poll}, votersOf("/voters/1"))
+	v, r := newTestVotesAPI(t, f)
+	withCache(v)
+
+	serve(r, http.MethodGet, "/votes/polls/1", "")
+	poll.Status = schema.PollStatusClosed
+	f.setPoll(poll)
+
+	if w := serve(r, http.MethodPost, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/1")); w.Code != http.StatusForbidden {
+		t.Errorf("POST /votes/1 = %v, want 403: %v", w.Code, w.Body)
+	}
+}
+
+func TestCacheExpiresAndEvicts(t *testing.T) {
+
+	loads := map[string]int{}
+	load := func(id string) func() (string, error) {
+		return func() (string, error) {
+			loads[id]++
+			return id, nil
+		}
+	}
+
+	c := newCache[string]("test", 20*time.Millisecond, 2)
+	c.get("/polls/1", load("/polls/1"))
+	c.get("/polls/2", load("/polls/2"))
+	c.get("/polls/1", load("/polls/1"))
+	// /polls/2 is the least recently used
+	c.get("/polls/3", load("/polls/3"))
+	c.get("/polls/1", load("/polls/1"))
+	c.get("/polls/2", load("/polls/2"))
+
+	if loads["/polls/1"] != 1 || loads["/polls/2"] != 2 || loads["/polls/3"] != 1 {
+		t.Errorf("loads = %v, want /polls/2 loaded again once evicted", loads)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	c.get("/polls/1", load("/polls/1"))
+	if loads["/polls/1"] != 2 {
+		t.Errorf("an expired Poll was loaded %v times, want 2", loads["/polls/1"])
+	}
+}
+
+// A load that is in flight when the ID is invalidated may have read the
+// value from before the change, so it is returned but not kept
+func TestCacheInvalidateDuringLoad(t *testing.T) {
+
+	c := newCache[string]("test", time.Minute, 10)
+
+	loading, finish := make(chan struct{}), make(chan struct{})
+	done := make(chan string)
+	go func() {
+		value, _ := c.get("/polls/1", func() (string, error) {
+			close(loading)
+			<-finish
+			return "before the change", nil
+		})
+		done <- value
+	}()
+
+	<-loading
+	c.invalidate("/polls/1")
+	close(finish)
+	<-done
+
+	value, _ := c.get("/polls/1", func() (string, error) { return "after the change", nil })
+	if value != "after the change" {
+		t.Errorf("get() = %q, the load that raced the invalidation was kept", value)
+	}
+	if len(c.loads) != 0 {
+		t.Errorf("%v loads are still tracked, want none", len(c.loads))
+	}
+}
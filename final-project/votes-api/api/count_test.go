@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"drexel.edu/votes-api/schema"
+)
+
+func TestVoteCounts(t *testing.T) {
+
+	poll := openPoll("/polls/1", schema.PollTypeMulti, "/polls/1/options/1", "/polls/1/options/2", "/polls/1/options/3")
+	poll.MaxSelections = 2
+	other := openPoll("/polls/2", schema.PollTypeSingle, "/polls/2/options/1")
+	f := newFakeAPIs(t, []schema.Poll{poll, other}, votersOf("/voters/1", "/voters/2", "/voters/3"))
+	_, r := newTestVotesAPI(t, f)
+
+	for path, body := range map[string]string{
+		"/votes/1": `{"VoterID": "/voters/1", "PollID": "/polls/1", "VoteValues": ["/polls/1/options/1", "/polls/1/options/2"]}`,
+		"/votes/2": `{"VoterID": "/voters/2", "PollID": "/polls/1", "VoteValues": ["/polls/1/options/2"]}`,
+		"/votes/3": `{"VoterID": "/voters/1", "PollID": "/polls/2", "VoteValue": "/polls/2/options/1"}`,
+	} {
+		if w := serve(r, http.MethodPost, path, body); w.Code != http.StatusOK {
+			t.Fatalf("POST %v = %v: %v", path, w.Code, w.Body)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/votes/polls/1/votes/count", 2},
+		{"/votes/polls/2/votes/count", 1},
+		{"/votes/polls/3/votes/count", 0},
+		{"/votes/polls/1/options/1/votes/count", 1},
+		{"/votes/polls/1/options/2/votes/count", 2},
+		{"/votes/polls/1/options/3/votes/count", 0},
+	}
+
+	for _, tt := range tests {
+		w := serve(r, http.MethodGet, tt.path, "")
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %v = %v: %v", tt.path, w.Code, w.Body)
+			continue
+		}
+		var count schema.VoteCount
+		if err := json.Unmarshal(w.Body.Bytes(), &count); err != nil {
+			t.Fatal(err)
+		}
+		if count.Count != tt.want {
+			t.Errorf("GET %v = %v, want %v", tt.path, count.Count, tt.want)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/audit"
+	"drexel.edu/common/requestid"
+	"drexel.edu/votes-api/schema"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisVoteEventsPrefix is the prefix of the redis Pub/Sub channel the
+// VoteEvents of a Poll are published on, e.g. vote-events:/polls/1
+const RedisVoteEventsPrefix = "vote-events:"
+
+// HeartbeatInterval is how often a comment is sent on an idle results
+// stream, so that proxies don't close it
+const HeartbeatInterval = 15 * time.Second
+
+// TallyTimeout is how long the results of a Poll with results streams may
+// take to tally, and TallyRetryInterval how long after a failed tally it is
+// tried again. Only once the Poll no longer exists are its streams ended.
+const (
+	TallyTimeout       = 10 * time.Second
+	TallyRetryInterval = 5 * time.Second
+)
+
+// VoteEvent is published whenever a Vote in the Poll PollID is added,
+// updated, deleted or imported (Action is one of the audit Actions)
+type VoteEvent struct {
+	Action string
+	VoteID string
+	PollID string
+}
+
+// VoteEvents is where the VoteEvents are published, and subscribed to by the
+// results streams. Subscribe returns the VoteEvents of the Poll pollID from
+// then on, until the returned func is called.
+type VoteEvents interface {
+	Publish(ctx context.Context, event VoteEvent) error
+	Subscribe(ctx context.Context, pollID string) (<-chan VoteEvent, func(), error)
+}
+
+// changed records a change to a Vote in the audit log and the metrics, and
+// publishes it to the results streams of its Poll. before is nil for an add,
+// after for a delete.
+func (v *VotesAPI) changed(ctx context.Context, actor audit.Actor, action string, before *schema.Vote, after *schema.Vote) {
+
+	vote := after
+	var beforeDoc, afterDoc any
+	if before != nil {
+		beforeDoc = *before
+		vote = before
+	}
+	if after != nil {
+		afterDoc = *after
+	}
+	audit.Record(ctx, v.audit, actor, audit.EntityVote, vote.VoteID, action, beforeDoc, afterDoc)
+	voteChanges.WithLabelValues(vote.PollID, action).Inc()
+
+	pollIDs := []string{vote.PollID}
+	if before != nil && after != nil && before.PollID != after.PollID {
+		pollIDs = append(pollIDs, after.PollID)
+	}
+	for _, pollID := range pollIDs {
+		if err := v.events.Publish(ctx, VoteEvent{Action: action, VoteID: vote.VoteID, PollID: pollID}); err != nil {
+			requestid.Logger(ctx).Println(fmt.Sprintf("Error publishing the %v of Vote %v: ", action, vote.VoteID), err)
+		}
+	}
+}
+
+// /votes/polls/:pollid/results/stream
+// streams the results of the Poll as Server-Sent Events, a "results" event
+// with the PollResults (see GET /votes/polls/:pollid/results) right away and
+// again after every change to the Votes cast in the Poll
+func (v *VotesAPI) GetPollResultsStream(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	re := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re.Find([]byte(c.Request.URL.Path)))
+
+	poll, err := v.getPoll(ctx, pollidS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get Poll %v from Poll API", pollidS), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	// subscribe before the first tally, so that no change is missed
+	updates, unsubscribe, err := v.streams.subscribe(ctx, pollidS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not subscribe to the VoteEvents of Poll %v", pollidS), err)
+		apierror.Abort(c, err)
+		return
+	}
+	defer unsubscribe()
+
+	results, err := v.tallyStoredVotes(ctx, poll)
+	if err != nil {
+		requestid.Logger(ctx).Println("An error occurred getting Votes from Redis.", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("results", results)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+
+		case <-v.closing:
+			// the server is shutting down
+			return
+
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+
+		case results, ok := <-updates:
+			if !ok {
+				return
+			}
+			c.SSEvent("results", results)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// tallyStoredVotes tallies the stored Votes cast in the Poll
+func (v *VotesAPI) tallyStoredVotes(ctx context.Context, poll schema.Poll) (schema.PollResults, error) {
+	votes, err := v.getStoredVotesWhere(ctx, poll.PollID, nil)
+	if err != nil {
+		return schema.PollResults{}, err
+	}
+	return tallyPollResults(poll, votes), nil
+}
+
+// tallyPoll looks the Poll up again, its PollOptions may have changed, and
+// tallies its stored Votes
+func (v *VotesAPI) tallyPoll(ctx context.Context, pollID string) (schema.PollResults, error) {
+	poll, err := v.getPoll(ctx, pollID)
+	if err != nil {
+		return schema.PollResults{}, fmt.Errorf("getting Poll %v: %w", pollID, err)
+	}
+	return v.tallyStoredVotes(ctx, poll)
+}
+
+// resultsStreams tallies the results of each Poll that has results streams
+// once per burst of its VoteEvents, however many streams there are, and
+// hands them to every stream of the Poll
+type resultsStreams struct {
+	events VoteEvents
+	tally  func(ctx context.Context, pollID string) (schema.PollResults, error)
+	// timeout and retryInterval are TallyTimeout and TallyRetryInterval
+	timeout       time.Duration
+	retryInterval time.Duration
+
+	mu    sync.Mutex
+	polls map[string]*pollStreams
+}
+
+// pollStreams are the results streams of a Poll, each gets the PollResults
+// on its own channel. stop ends the subscription to the VoteEvents of the
+// Poll.
+type pollStreams struct {
+	streams map[chan schema.PollResults]bool
+	done    chan struct{}
+	stop    func()
+}
+
+func newResultsStreams(events VoteEvents, tally func(ctx context.Context, pollID string) (schema.PollResults, error)) *resultsStreams {
+	return &resultsStreams{
+		events:        events,
+		tally:         tally,
+		timeout:       TallyTimeout,
+		retryInterval: TallyRetryInterval,
+		polls:         make(map[string]*pollStreams),
+	}
+}
+
+// subscribe returns the PollResults of the Poll pollID after every burst of
+// changes to its Votes, until the returned func is called. The channel is
+// closed once the Poll is deleted.
+func (s *resultsStreams) subscribe(ctx context.Context, pollID string) (<-chan schema.PollResults, func(), error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.polls[pollID]
+	if !ok {
+		events, unsubscribe, err := s.events.Subscribe(ctx, pollID)
+		if err != nil {
+			return nil, nil, err
+		}
+		p = &pollStreams{streams: make(map[chan schema.PollResults]bool), done: make(chan struct{})}
+		var once sync.Once
+		p.stop = func() {
+			once.Do(func() {
+				close(p.done)
+				unsubscribe()
+			})
+		}
+		s.polls[pollID] = p
+		go s.run(pollID, p, events)
+	}
+
+	results := make(chan schema.PollResults, 1)
+	p.streams[results] = true
+
+	unsubscribe := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if !p.streams[results] {
+			// run has ended the stream already
+			return
+		}
+		delete(p.streams, results)
+		if len(p.streams) == 0 {
+			delete(s.polls, pollID)
+			p.stop()
+		}
+	}
+
+	return results, unsubscribe, nil
+}
+
+// run tallies the Poll after every burst of VoteEvents until its last stream
+// unsubscribes. A tally that fails, e.g. because the Poll API is down, is
+// tried again after the retryInterval, and the streams are only ended once
+// the Poll no longer exists.
+func (s *resultsStreams) run(pollID string, p *pollStreams, events <-chan VoteEvent) {
+
+	defer s.end(pollID, p)
+
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-p.done:
+			return
+
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+			// a burst of changes is tallied once
+			drainEvents(events)
+
+		case <-retry:
+		}
+		retry = nil
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		results, err := s.tally(ctx, pollID)
+		cancel()
+
+		if errors.Is(err, apierror.ErrNotFound) {
+			log.Printf("Poll %v no longer exists, ending its results streams", pollID)
+			return
+		}
+		if err != nil {
+			log.Println(fmt.Sprintf("Could not tally the results of Poll %v, trying again in %v: ", pollID, s.retryInterval), err)
+			retry = time.After(s.retryInterval)
+			continue
+		}
+
+		s.mu.Lock()
+		for stream := range p.streams {
+			// a stream that is behind only gets the latest results
+			select {
+			case <-stream:
+			default:
+			}
+			stream <- results
+		}
+		s.mu.Unlock()
+	}
+}
+
+// end closes the streams that are still subscribed to the Poll
+func (s *resultsStreams) end(pollID string, p *pollStreams) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.polls[pollID] == p {
+		delete(s.polls, pollID)
+	}
+	for stream := range p.streams {
+		delete(p.streams, stream)
+		close(stream)
+	}
+	p.stop()
+}
+
+func drainEvents(events <-chan VoteEvent) {
+	for {
+		select {
+		case <-events:
+		default:
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------
+// REDIS
+//------------------------------------------------------------
+
+// redisVoteEvents publishes the VoteEvents as JSON on redis Pub/Sub, so that
+// the results streams of every replica of the Votes API receive them
+type redisVoteEvents struct {
+	client *redis.Client
+}
+
+func newRedisVoteEvents(client *redis.Client) *redisVoteEvents {
+	return &redisVoteEvents{client: client}
+}
+
+func (e *redisVoteEvents) Publish(ctx context.Context, event VoteEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return e.client.Publish(ctx, RedisVoteEventsPrefix+event.PollID, data).Err()
+}
+
+func (e *redisVoteEvents) Subscribe(ctx context.Context, pollID string) (<-chan VoteEvent, func(), error) {
+
+	pubsub := e.client.Subscribe(ctx, RedisVoteEventsPrefix+pollID)
+
+	// wait for the subscription to be confirmed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, nil, err
+	}
+
+	events := make(chan VoteEvent, 16)
+	go func() {
+		defer close(events)
+		for msg := range pubsub.Channel() {
+			var event VoteEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Println(fmt.Sprintf("Error reading a VoteEvent from %v: ", msg.Channel), err)
+				continue
+			}
+			select {
+			case events <- event:
+			default:
+				// the Poll is behind, it tallies all its Votes anyway
+			}
+		}
+	}()
+
+	return events, func() { pubsub.Close() }, nil
+}
+
+//------------------------------------------------------------
+// MEMORY
+//------------------------------------------------------------
+
+// memoryVoteEvents hands the VoteEvents to the results streams of this
+// process only
+type memoryVoteEvents struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan VoteEvent]bool
+}
+
+func newMemoryVoteEvents() *memoryVoteEvents {
+	return &memoryVoteEvents{subscribers: make(map[string]map[chan VoteEvent]bool)}
+}
+
+func (e *memoryVoteEvents) Publish(ctx context.Context, event VoteEvent) error {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for events := range e.subscribers[event.PollID] {
+		select {
+		case events <- event:
+		default:
+			// the Poll is behind, it tallies all its Votes anyway
+		}
+	}
+	return nil
+}
+
+func (e *memoryVoteEvents) Subscribe(ctx context.Context, pollID string) (<-chan VoteEvent, func(), error) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	events := make(chan VoteEvent, 16)
+	if e.subscribers[pollID] == nil {
+		e.subscribers[pollID] = make(map[chan VoteEvent]bool)
+	}
+	e.subscribers[pollID][events] = true
+
+	unsubscribe := func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		delete(e.subscribers[pollID], events)
+		if len(e.subscribers[pollID]) == 0 {
+			delete(e.subscribers, pollID)
+		}
+	}
+
+	return events, unsubscribe, nil
+}
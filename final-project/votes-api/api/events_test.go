@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/votes-api/schema"
+)
+
+// fakeTally is what the Poll API and the stored Votes would tally to for
+// resultsStreams, one answer per call
+type fakeTally struct {
+	answers chan func(ctx context.Context) (schema.PollResults, error)
+}
+
+func (f *fakeTally) tally(ctx context.Context, pollID string) (schema.PollResults, error) {
+	return (<-f.answers)(ctx)
+}
+
+func (f *fakeTally) answer(results schema.PollResults, err error) {
+	f.answers <- func(context.Context) (schema.PollResults, error) { return results, err }
+}
+
+func newTestStreams(t *testing.T) (*resultsStreams, *memoryVoteEvents, *fakeTally) {
+	t.Helper()
+	events := newMemoryVoteEvents()
+	f := &fakeTally{answers: make(chan func(ctx context.Context) (schema.PollResults, error), 10)}
+	s := newResultsStreams(events, f.tally)
+	s.timeout, s.retryInterval = 50*time.Millisecond, 10*time.Millisecond
+	return s, events, f
+}
+
+func subscribe(t *testing.T, s *resultsStreams, pollID string) <-chan schema.PollResults {
+	t.Helper()
+	results, unsubscribe, err := s.subscribe(context.Background(), pollID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(unsubscribe)
+	return results
+}
+
+func publish(t *testing.T, events *memoryVoteEvents, pollID string) {
+	t.Helper()
+	if err := events.Publish(context.Background(), VoteEvent{Action: "add", VoteID: "/votes/1", PollID: pollID}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, stream <-chan schema.PollResults) (schema.PollResults, bool) {
+	t.Helper()
+	select {
+	case results, ok := <-stream:
+		return results, ok
+	case <-time.After(time.Second):
+		t.Fatal("no results after a second")
+		return schema.PollResults{}, false
+	}
+}
+
+// Every stream of the Poll gets the results of one tally
+func TestResultsStreamsShareATally(t *testing.T) {
+
+	s, events, f := newTestStreams(t)
+	first, second := subscribe(t, s, "/polls/1"), subscribe(t, s, "/polls/1")
+
+	f.answer(schema.PollResults{PollID: "/polls/1", TotalVotes: 1}, nil)
+	publish(t, events, "/polls/1")
+
+	for _, stream := range []<-chan schema.PollResults{first, second} {
+		if results, ok := receive(t, stream); !ok || results.TotalVotes != 1 {
+			t.Errorf("got %+v, %v, want the one tally", results, ok)
+		}
+	}
+	if len(f.answers) != 0 {
+		t.Errorf("the tally wasn't asked for")
+	}
+}
+
+// A tally that fails, or hangs until its deadline, is tried again and the
+// streams stay open
+func TestResultsStreamsRetryAFailedTally(t *testing.T) {
+
+	tests := []struct {
+		name string
+		fail func(f *fakeTally)
+	}{
+		{"the Poll API is down", func(f *fakeTally) {
+			f.answer(schema.PollResults{}, apierror.Upstream("PollID", "Could not get /polls/1"))
+		}},
+		{"the Poll API hangs", func(f *fakeTally) {
+			f.answers <- func(ctx context.Context) (schema.PollResults, error) {
+				if _, ok := ctx.Deadline(); !ok {
+					return schema.PollResults{}, errors.New("the tally has no deadline")
+				}
+				<-ctx.Done()
+				return schema.PollResults{}, ctx.Err()
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s, events, f := newTestStreams(t)
+			stream := subscribe(t, s, "/polls/1")
+
+			tt.fail(f)
+			f.answer(schema.PollResults{PollID: "/polls/1", TotalVotes: 2}, nil)
+			publish(t, events, "/polls/1")
+
+			if results, ok := receive(t, stream); !ok || results.TotalVotes != 2 {
+				t.Errorf("got %+v, %v, want the results of the retry", results, ok)
+			}
+		})
+	}
+}
+
+// Once the Poll is gone its streams are ended, and those of other Polls go on
+func TestResultsStreamsEndWhenThePollIsDeleted(t *testing.T) {
+
+	s, events, f := newTestStreams(t)
+	deleted, other := subscribe(t, s, "/polls/1"), subscribe(t, s, "/polls/2")
+
+	f.answer(schema.PollResults{}, apierror.NotFound("PollID", "/polls/1 does not exist."))
+	publish(t, events, "/polls/1")
+
+	if _, ok := receive(t, deleted); ok {
+		t.Errorf("the stream of the deleted Poll is still open")
+	}
+
+	f.answer(schema.PollResults{PollID: "/polls/2", TotalVotes: 3}, nil)
+	publish(t, events, "/polls/2")
+	if results, ok := receive(t, other); !ok || results.TotalVotes != 3 {
+		t.Errorf("the stream of another Poll got %+v, %v", results, ok)
+	}
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/votes-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// fakeAPIs stands in for the Poll API and the Voter API: it serves the Polls
+// and Voters it is given, and keeps the VoteHistory the Votes API writes
+type fakeAPIs struct {
+	mu     sync.Mutex
+	polls  map[string]schema.Poll
+	voters map[string]schema.Voter
+	// failHistory makes the writes of a voterPoll fail with a 500
+	failHistory bool
+	// gets counts the GETs of each Poll and Voter
+	gets map[string]int
+
+	server *httptest.Server
+}
+
+func newFakeAPIs(t *testing.T, polls []schema.Poll, voters []schema.Voter) *fakeAPIs {
+
+	f := &fakeAPIs{polls: make(map[string]schema.Poll), voters: make(map[string]schema.Voter), gets: make(map[string]int)}
+	for _, poll := range polls {
+		f.polls[poll.PollID] = poll
+	}
+	for _, voter := range voters {
+		f.voters[voter.VoterID] = voter
+	}
+
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+var fakeVoterPollPath = regexp.MustCompile(`^(/voters/\d+)(/polls/\d+)$`)
+
+func (f *fakeAPIs) serve(w http.ResponseWriter, r *http.Request) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.Path
+	if m := fakeVoterPollPath.FindStringSubmatch(path); m != nil {
+		f.serveVoterPoll(w, r, m[1], m[2])
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		f.gets[path]++
+		if poll, ok := f.polls[path]; ok {
+			writeJSON(w, http.StatusOK, poll)
+			return
+		}
+		if voter, ok := f.voters[path]; ok {
+			writeJSON(w, http.StatusOK, voter)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, path+" does not exist.")
+}
+
+func (f *fakeAPIs) serveVoterPoll(w http.ResponseWriter, r *http.Request, voterID, pollID string) {
+
+	voter, ok := f.voters[voterID]
+	if !ok {
+		writeError(w, http.StatusNotFound, voterID+" does not exist.")
+		return
+	}
+
+	index := -1
+	for i, voterPoll := range voter.VoteHistory {
+		if voterPoll.PollID == pollID {
+			index = i
+		}
+	}
+
+	if r.Method == http.MethodGet {
+		if index < 0 {
+			writeError(w, http.StatusNotFound, voterID+pollID+" does not exist.")
+			return
+		}
+		writeJSON(w, http.StatusOK, voter.VoteHistory[index])
+		return
+	}
+
+	if f.failHistory {
+		writeError(w, http.StatusInternalServerError, "the VoteHistory can't be written")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if index >= 0 {
+			writeError(w, http.StatusConflict, voterID+pollID+" already exists.")
+			return
+		}
+		voter.VoteHistory = append(voter.VoteHistory, schema.VoterPoll{PollID: pollID})
+	case http.MethodPut:
+		if index < 0 {
+			writeError(w, http.StatusNotFound, voterID+pollID+" does not exist.")
+			return
+		}
+	case http.MethodDelete:
+		if index < 0 {
+			writeError(w, http.StatusNotFound, voterID+pollID+" does not exist.")
+			return
+		}
+		voter.VoteHistory = append(voter.VoteHistory[:index], voter.VoteHistory[index+1:]...)
+	}
+	f.voters[voterID] = voter
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeAPIs) setFailHistory(fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failHistory = fail
+}
+
+// fetched returns how many times the Poll or Voter id was fetched
+func (f *fakeAPIs) fetched(id string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets[id]
+}
+
+// setPoll changes the Poll, as a PUT to the Poll API would
+func (f *fakeAPIs) setPoll(poll schema.Poll) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.polls[poll.PollID] = poll
+}
+
+// history returns the PollIDs of the VoteHistory of the Voter voterID
+func (f *fakeAPIs) history(voterID string) []string {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pollIDs := []string{}
+	for _, voterPoll := range f.voters[voterID].VoteHistory {
+		pollIDs = append(pollIDs, voterPoll.PollID)
+	}
+	return pollIDs
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apierror.ErrorResponse{Status: status, Message: message})
+}
+
+// newTestVotesAPI returns an in-memory VotesAPI that calls f, and a router
+// with its routes, without authorization
+func newTestVotesAPI(t *testing.T, f *fakeAPIs) (*VotesAPI, *gin.Engine) {
+
+	gin.SetMode(gin.TestMode)
+
+	v := NewInMemoryVotesAPI(f.server.URL, f.server.URL)
+	t.Cleanup(func() { v.Close() })
+
+	r := gin.New()
+	r.GET("/votes", v.GetAllVotes)
+	r.GET("/votes/:voteid", v.GetVote)
+	r.POST("/votes/:voteid", v.AddVote)
+	r.PUT("/votes/:voteid", v.UpdateVote)
+	r.DELETE("/votes/:voteid", v.DeleteVote)
+	r.GET("/votes/voters/:voterid/polls/:pollid/vote", v.GetVoterPollVote)
+	r.GET("/votes/polls/:pollid", v.GetPoll)
+	r.GET("/votes/polls/:pollid/votes", v.GetPollVotes)
+	r.GET("/votes/polls/:pollid/votes/count", v.GetPollVoteCount)
+	r.GET("/votes/polls/:pollid/options/:optionid/votes/count", v.GetPollOptionVoteCount)
+	r.GET("/votes/polls/:pollid/results", v.GetPollResults)
+	return v, r
+}
+
+// serve sends the request to r, with body as JSON unless it is empty
+func serve(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func openPoll(pollID string, pollType string, optionIDs ...string) schema.Poll {
+	poll := schema.Poll{PollID: pollID, PollType: pollType, Status: schema.PollStatusOpen}
+	for _, optionID := range optionIDs {
+		poll.PollOptions = append(poll.PollOptions, schema.PollOption{PollOptionID: optionID, PollOptionText: optionID})
+	}
+	return poll
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+// The voterPoll index (a redis hash, or a map in memory) maps VoterID+PollID (which is
+// also the voterPoll's endpoint, e.g. /voters/1/polls/2) to the VoteID of
+// the single Vote the Voter is allowed to cast in that Poll. It is kept up
+// to date by AddVote, UpdateVote and DeleteVote.
+const RedisVoterPollIndexKey = "index:voterpoll"
+
+// The pollVotes index (a redis set per Poll, e.g. index:pollvotes:/polls/1,
+// or a map in memory) holds the VoteIDs of the Votes cast in each Poll, so
+// that the results of a Poll are tallied without reading every Vote. The
+// VoteStore keeps it up to date as Votes are set and deleted, and
+// RedisPollVotesIndexBuiltKey is set once the Votes stored before the index
+// existed were added to it.
+const (
+	RedisPollVotesIndexPrefix   = "index:pollvotes:"
+	RedisPollVotesIndexBuiltKey = "index:pollvotes:built"
+)
+
+func voterPollIndexField(voterID, pollID string) string {
+	return voterID + pollID
+}
+
+// claimVoterPoll records voteID as the Vote for the voterPoll. It returns
+// false if the Voter already has a Vote in the Poll.
+func (v *VotesAPI) claimVoterPoll(ctx context.Context, voterID, pollID, voteID string) (bool, error) {
+	field := voterPollIndexField(voterID, pollID)
+	return v.store.ClaimVoterPoll(ctx, field, voteID)
+}
+
+// releaseVoterPoll removes the voterPoll from the index so that the Voter
+// can vote in the Poll again
+func (v *VotesAPI) releaseVoterPoll(ctx context.Context, voterID, pollID string) error {
+	field := voterPollIndexField(voterID, pollID)
+	return v.store.ReleaseVoterPoll(ctx, field)
+}
+
+// setVoterPoll (re)writes the index entry for the voterPoll, used when a
+// Vote is updated so that Votes cast before the index existed are picked up
+func (v *VotesAPI) setVoterPoll(ctx context.Context, voterID, pollID, voteID string) error {
+	field := voterPollIndexField(voterID, pollID)
+	return v.store.SetVoterPoll(ctx, field, voteID)
+}
+
+// lookupVoterPoll returns the VoteID of the Vote the Voter cast in the Poll,
+// or docstore.ErrNotFound if they have not voted in it
+func (v *VotesAPI) lookupVoterPoll(ctx context.Context, voterID, pollID string) (string, error) {
+	field := voterPollIndexField(voterID, pollID)
+	return v.store.LookupVoterPoll(ctx, field)
+}
+
+// buildVoterPollIndex populates the index from the Votes already stored in
+// the store. It only runs when the index does not exist yet, so Votes added
+// before the index was introduced are still counted against their Voter. If
+// a Voter has more than one Vote in a Poll nothing is written, the Votes
+// have to be repaired first.
+func (v *VotesAPI) buildVoterPollIndex(ctx context.Context) error {
+
+	exists, err := v.store.VoterPollIndexExists(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	votes, err := v.getAllStoredVotes(ctx)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]string, len(votes))
+	for _, vote := range votes {
+		field := voterPollIndexField(vote.VoterID, vote.PollID)
+		if voteID, ok := index[field]; ok {
+			return fmt.Errorf("Voter %v has more than one Vote in Poll %v (%v and %v)", vote.VoterID, vote.PollID, voteID, vote.VoteID)
+		}
+		index[field] = vote.VoteID
+	}
+
+	return v.store.CreateVoterPollIndex(ctx, index)
+}
+
+// buildPollVotesIndex adds the Votes already stored to the pollVotes index.
+// It only runs when the index was not built yet.
+func (v *VotesAPI) buildPollVotesIndex(ctx context.Context) error {
+
+	exists, err := v.store.PollVotesIndexExists(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	votes, err := v.getAllStoredVotes(ctx)
+	if err != nil {
+		return err
+	}
+
+	return v.store.CreatePollVotesIndex(ctx, votes)
+}
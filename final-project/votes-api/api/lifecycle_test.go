@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"drexel.edu/votes-api/schema"
+)
+
+// Votes are only cast while their Poll is open
+func TestVotesOnlyWhileThePollIsOpen(t *testing.T) {
+
+	justClosed := time.Now().Add(-time.Millisecond)
+	later := time.Now().Add(time.Hour)
+
+	open := openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1")
+	closing := openPoll("/polls/2", schema.PollTypeSingle, "/polls/2/options/1")
+	closing.ClosesAt = &later
+	draft := openPoll("/polls/3", schema.PollTypeSingle, "/polls/3/options/1")
+	draft.Status = schema.PollStatusDraft
+	closed := openPoll("/polls/4", schema.PollTypeSingle, "/polls/4/options/1")
+	closed.Status = schema.PollStatusClosed
+	pastWindow := openPoll("/polls/5", schema.PollTypeSingle, "/polls/5/options/1")
+	pastWindow.ClosesAt = &justClosed
+
+	f := newFakeAPIs(t, []schema.Poll{open, closing, draft, closed, pastWindow}, votersOf("/voters/1"))
+
+	tests := []struct {
+		name       string
+		pollID     string
+		wantStatus int
+	}{
+		{"open", "/polls/1", http.StatusOK},
+		{"open until later", "/polls/2", http.StatusOK},
+		{"draft", "/polls/3", http.StatusForbidden},
+		{"closed", "/polls/4", http.StatusForbidden},
+		{"open but past its ClosesAt", "/polls/5", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, r := newTestVotesAPI(t, f)
+			w := serve(r, http.MethodPost, "/votes/1", voteBody("/voters/1", tt.pollID, tt.pollID+"/options/1"))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v: %v", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateVoteInAClosedPoll(t *testing.T) {
+
+	poll := openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1", "/polls/1/options/2")
+	f := newFakeAPIs(t, []schema.Poll{poll}, votersOf("/voters/1"))
+	_, r := newTestVotesAPI(t, f)
+
+	if w := serve(r, http.MethodPost, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/1")); w.Code != http.StatusOK {
+		t.Fatalf("POST /votes/1 = %v: %v", w.Code, w.Body)
+	}
+
+	poll.Status = schema.PollStatusClosed
+	f.setPoll(poll)
+
+	if w := serve(r, http.MethodPut, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/2")); w.Code != http.StatusForbidden {
+		t.Errorf("PUT /votes/1 = %v, want 403: %v", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The Votes API's own metrics, next to the ones of the metrics package
+
+var voteChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "votes_total",
+	Help: "Votes added (cast), updated, deleted and imported, by Poll and action.",
+}, []string{"poll", "action"})
+
+var cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_lookups_total",
+	Help: "Lookups of Polls and Voters in the cache, by cache (poll or voter) and result (hit or miss).",
+}, []string{"cache", "result"})
+
+var cacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_evictions_total",
+	Help: "Polls and Voters dropped from the cache to make room, by cache (poll or voter).",
+}, []string{"cache"})
+
+var cacheEntries = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cache_entries",
+	Help: "Polls and Voters in the cache, by cache (poll or voter).",
+}, []string{"cache"})
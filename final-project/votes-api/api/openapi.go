@@ -0,0 +1,230 @@
+package api
+
+import (
+	"net/http"
+
+	"drexel.edu/common/audit"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/openapi"
+	"drexel.edu/votes-api/schema"
+)
+
+// OpenAPI returns the OpenAPI document of the Votes API, with an operation
+// for every route registered in main.go
+func OpenAPI(version string) *openapi.Document {
+
+	doc := openapi.New("Votes API", version,
+		"The Votes cast by the Voters of the Voter API in the Polls of the Poll API, and their results. The ID of a Vote is its path, e.g. /votes/1. The /votes/voters and /votes/polls routes pass the Voters and Polls along from the other APIs.")
+
+	voter := openapi.Require(auth.RoleVoter)
+	admin := openapi.Require()
+	adminOrPollAPI := openapi.RequireAdminOrService(auth.ServicePollAPI)
+	adminOrVoterAPI := openapi.RequireAdminOrService(auth.ServiceVoterAPI)
+
+	doc.Add(http.MethodGet, "/votes", openapi.Operation{
+		ID:          "getAllVotes",
+		Summary:     "Every Vote, or a page of them",
+		Description: "Without cursor and limit every Vote is returned, with either of them a VotePage.",
+		Tag:         "votes",
+		Query:       openapi.PageParams,
+		Response:    openapi.OneOf([]schema.Vote{}, schema.VotePage{}),
+	})
+	doc.Add(http.MethodGet, "/votes/:voteid", openapi.Operation{
+		ID:       "getVote",
+		Summary:  "A Vote",
+		Tag:      "votes",
+		Response: schema.Vote{},
+	})
+	doc.Add(http.MethodPost, "/votes/:voteid", openapi.Operation{
+		ID:          "addVote",
+		Summary:     "Casts a Vote",
+		Description: "The VoteID is taken from the path. A voter can only cast their own Vote, in an open Poll, and only one per Poll. The Vote is added to the VoteHistory of the Voter as well.",
+		Tag:         "votes",
+		Auth:        voter,
+		Body:        schema.Vote{},
+	})
+	doc.Add(http.MethodDelete, "/votes/:voteid", openapi.Operation{
+		ID:          "deleteVote",
+		Summary:     "Deletes a Vote",
+		Description: "A voter can only delete their own Vote. The Vote is removed from the VoteHistory of the Voter as well.",
+		Tag:         "votes",
+		Auth:        voter,
+	})
+	doc.Add(http.MethodPut, "/votes/:voteid", openapi.Operation{
+		ID:          "updateVote",
+		Summary:     "Changes the VoteValue or VoteValues of a Vote",
+		Description: "A voter can only change their own Vote, in an open Poll. The other fields are ignored.",
+		Tag:         "votes",
+		Auth:        voter,
+		Body:        schema.Vote{},
+	})
+
+	doc.Add(http.MethodGet, "/votes/voters", openapi.Operation{
+		ID:          "getAllVoters",
+		Summary:     "Every Voter of the Voter API, or a page of them",
+		Description: "Without cursor and limit every Voter is returned, with either of them a VoterPage.",
+		Tag:         "voters",
+		Query:       openapi.PageParams,
+		Response:    openapi.OneOf([]schema.Voter{}, schema.VoterPage{}),
+	})
+	doc.Add(http.MethodGet, "/votes/voters/:voterid", openapi.Operation{
+		ID:       "getVoter",
+		Summary:  "A Voter of the Voter API",
+		Tag:      "voters",
+		Response: schema.Voter{},
+	})
+	doc.Add(http.MethodGet, "/votes/voters/:voterid/polls", openapi.Operation{
+		ID:       "getVoterPolls",
+		Summary:  "The VoteHistory of a Voter",
+		Tag:      "voters",
+		Response: []schema.VoterPoll{},
+	})
+	doc.Add(http.MethodGet, "/votes/voters/:voterid/polls/:pollid", openapi.Operation{
+		ID:       "getVoterPoll",
+		Summary:  "The VoterPoll of a Voter for a Poll",
+		Tag:      "voters",
+		Response: schema.VoterPoll{},
+	})
+	doc.Add(http.MethodGet, "/votes/voters/:voterid/polls/:pollid/vote", openapi.Operation{
+		ID:       "getVoterPollVote",
+		Summary:  "The Vote a Voter cast in a Poll",
+		Tag:      "voters",
+		Response: schema.Vote{},
+	})
+	doc.Add(http.MethodGet, "/votes/voters/:voterid/votes", openapi.Operation{
+		ID:       "getVoterVotes",
+		Summary:  "The Votes cast by a Voter",
+		Tag:      "voters",
+		Response: []schema.Vote{},
+	})
+	doc.Add(http.MethodDelete, "/votes/voters/:voterid/votes", openapi.Operation{
+		ID:          "deleteVoterVotes",
+		Summary:     "Deletes the Votes cast by a Voter",
+		Description: "The Voter API calls it to cascade the deletion of a Voter, the deleted Votes are returned.",
+		Tag:         "voters",
+		Auth:        adminOrVoterAPI,
+		Response:    []schema.Vote{},
+	})
+
+	doc.Add(http.MethodGet, "/votes/polls", openapi.Operation{
+		ID:          "getAllPolls",
+		Summary:     "Every Poll of the Poll API, or a page of them",
+		Description: "Without cursor and limit every Poll is returned, with either of them a PollPage.",
+		Tag:         "polls",
+		Query:       openapi.PageParams,
+		Response:    openapi.OneOf([]schema.Poll{}, schema.PollPage{}),
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid", openapi.Operation{
+		ID:       "getPoll",
+		Summary:  "A Poll of the Poll API",
+		Tag:      "polls",
+		Response: schema.Poll{},
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid/options", openapi.Operation{
+		ID:       "getPollOptions",
+		Summary:  "The PollOptions of a Poll",
+		Tag:      "polls",
+		Response: []schema.PollOption{},
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid/options/:optionid", openapi.Operation{
+		ID:       "getPollOption",
+		Summary:  "A PollOption of a Poll",
+		Tag:      "polls",
+		Response: schema.PollOption{},
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid/options/:optionid/votes", openapi.Operation{
+		ID:       "getPollOptionVotes",
+		Summary:  "The Votes that select (or rank) a PollOption",
+		Tag:      "polls",
+		Response: []schema.Vote{},
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid/options/:optionid/votes/count", openapi.Operation{
+		ID:          "countPollOptionVotes",
+		Summary:     "The number of Votes that select (or rank) a PollOption",
+		Description: "The Poll API calls it before it changes or deletes a PollOption.",
+		Tag:         "polls",
+		Response:    schema.VoteCount{},
+	})
+	doc.Add(http.MethodDelete, "/votes/polls/:pollid/options/:optionid/votes", openapi.Operation{
+		ID:          "deletePollOptionVotes",
+		Summary:     "Deletes the Votes that select (or rank) a PollOption",
+		Description: "The Poll API calls it to cascade the deletion of a PollOption, the deleted Votes are returned.",
+		Tag:         "polls",
+		Auth:        adminOrPollAPI,
+		Response:    []schema.Vote{},
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid/votes", openapi.Operation{
+		ID:       "getPollVotes",
+		Summary:  "The Votes cast in a Poll",
+		Tag:      "polls",
+		Response: []schema.Vote{},
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid/votes/count", openapi.Operation{
+		ID:          "countPollVotes",
+		Summary:     "The number of Votes cast in a Poll",
+		Description: "The Poll API calls it before it deletes a Poll.",
+		Tag:         "polls",
+		Response:    schema.VoteCount{},
+	})
+	doc.Add(http.MethodDelete, "/votes/polls/:pollid/votes", openapi.Operation{
+		ID:          "deletePollVotes",
+		Summary:     "Deletes the Votes cast in a Poll",
+		Description: "The Poll API calls it to cascade the deletion of a Poll, the deleted Votes are returned.",
+		Tag:         "polls",
+		Auth:        adminOrPollAPI,
+		Response:    []schema.Vote{},
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid/results", openapi.Operation{
+		ID:          "getPollResults",
+		Summary:     "The results of a Poll",
+		Description: "The count and percentage of the Votes of every PollOption, and the rounds of the instant runoff of a ranked Poll.",
+		Tag:         "results",
+		Response:    schema.PollResults{},
+	})
+	doc.Add(http.MethodGet, "/votes/polls/:pollid/results/stream", openapi.Operation{
+		ID:          "streamPollResults",
+		Summary:     "The results of a Poll as Server-Sent Events",
+		Description: "A results event with the PollResults is sent right away and again after every change to the Votes cast in the Poll, with a heartbeat comment in between, until the client disconnects.",
+		Tag:         "results",
+		ContentType: "text/event-stream",
+	})
+
+	doc.Add(http.MethodPost, "/votes/admin/reconcile", openapi.Operation{
+		ID:          "reconcileVotes",
+		Summary:     "Finds, and repairs, the Votes and VoteHistory that don't match",
+		Description: "A Vote without a VoterPoll in the VoteHistory of its Voter, or a VoterPoll without a Vote, is repaired unless dryRun is true.",
+		Tag:         "admin",
+		Auth:        admin,
+		Query:       []openapi.Parameter{{Name: "dryRun", Type: "boolean", Description: "Only report what doesn't match, without repairing it."}},
+		Response:    schema.ReconcileReport{},
+	})
+	doc.Add(http.MethodGet, "/votes/admin/audit", openapi.Operation{
+		ID:       "getAuditLog",
+		Summary:  "A page of the audit log, oldest first",
+		Tag:      "admin",
+		Auth:     admin,
+		Query:    openapi.AuditParams,
+		Response: audit.Page{},
+	})
+	doc.Add(http.MethodGet, "/admin/export", openapi.Operation{
+		ID:       "exportVotes",
+		Summary:  "A snapshot of every Vote",
+		Tag:      "admin",
+		Auth:     admin,
+		Response: docstore.Snapshot[schema.Vote]{},
+	})
+	doc.Add(http.MethodPost, "/admin/import", openapi.Operation{
+		ID:          "importVotes",
+		Summary:     "Adds every Vote of a snapshot",
+		Description: "The Votes with the same VoteID are overwritten.",
+		Tag:         "admin",
+		Auth:        admin,
+		Body:        docstore.Snapshot[schema.Vote]{},
+		Response:    docstore.ImportResult{},
+	})
+
+	doc.AddHealth()
+
+	return doc
+}
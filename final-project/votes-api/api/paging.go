@@ -0,0 +1,10 @@
+package api
+
+// relayPageLink turns the next link of a page returned by the Voter API or
+// the Poll API into the matching link of the Votes API relay endpoint
+func relayPageLink(next string) string {
+	if next == "" {
+		return ""
+	}
+	return "/votes" + next
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/votes-api/schema"
+)
+
+// The ballots of these tests are written as strings of the letters of the
+// PollOptions they rank, "BA" ranks B first and A second
+
+func letterPoll(pollType string) schema.Poll {
+	return openPoll("/polls/1", pollType, "/polls/1/options/A", "/polls/1/options/B", "/polls/1/options/C")
+}
+
+func letterBallots(ballots ...string) []schema.Vote {
+	var votes []schema.Vote
+	for i, ballot := range ballots {
+		vote := schema.Vote{VoteID: fmt.Sprintf("/votes/%v", i+1), PollID: "/polls/1"}
+		for _, letter := range ballot {
+			vote.VoteValues = append(vote.VoteValues, "/polls/1/options/"+string(letter))
+		}
+		votes = append(votes, vote)
+	}
+	return votes
+}
+
+func letter(optionID string) string {
+	return strings.TrimPrefix(optionID, "/polls/1/options/")
+}
+
+// describe writes a RunoffRound as e.g. "A=2 B=1 C=1 exhausted=0 out=B,C"
+func describe(round schema.RunoffRound) string {
+	var parts []string
+	for _, result := range round.Results {
+		parts = append(parts, fmt.Sprintf("%v=%v", letter(result.PollOptionID), result.Votes))
+	}
+	parts = append(parts, fmt.Sprintf("exhausted=%v", round.Exhausted))
+	if len(round.Eliminated) > 0 {
+		var out []string
+		for _, optionID := range round.Eliminated {
+			out = append(out, letter(optionID))
+		}
+		parts = append(parts, "out="+strings.Join(out, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestInstantRunoff(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		ballots    []string
+		wantRounds []string
+		wantWinner string
+	}{
+		{
+			name:       "a majority in the first round ends the count",
+			ballots:    []string{"AB", "AB", "AC", "B", "C"},
+			wantRounds: []string{"A=3 B=1 C=1 exhausted=0"},
+			wantWinner: "A",
+		},
+		{
+			name:    "all the options tied for last are eliminated together",
+			ballots: []string{"A", "A", "BA", "CB"},
+			wantRounds: []string{
+				"A=2 B=1 C=1 exhausted=0 out=B,C",
+				"A=3 exhausted=1",
+			},
+			wantWinner: "A",
+		},
+		{
+			name:    "a ballot that ranks no continuing option is exhausted",
+			ballots: []string{"A", "A", "A", "B", "C", "C"},
+			wantRounds: []string{
+				"A=3 B=1 C=2 exhausted=0 out=B",
+				"A=3 C=2 exhausted=1",
+			},
+			wantWinner: "A",
+		},
+		{
+			name:    "a majority of the ballots still counting wins, not of all ballots",
+			ballots: []string{"AC", "B", "BA", "C"},
+			wantRounds: []string{
+				"A=1 B=2 C=1 exhausted=0 out=A,C",
+				"B=2 exhausted=2",
+			},
+			wantWinner: "B",
+		},
+		{
+			name:       "everyone tied in the first round has no winner",
+			ballots:    []string{"A", "A", "B", "B", "C", "C"},
+			wantRounds: []string{"A=2 B=2 C=2 exhausted=0 out=A,B,C"},
+		},
+		{
+			name:    "everyone left tied after an elimination has no winner",
+			ballots: []string{"A", "A", "B", "B", "C"},
+			wantRounds: []string{
+				"A=2 B=2 C=1 exhausted=0 out=C",
+				"A=2 B=2 exhausted=1 out=A,B",
+			},
+		},
+		{
+			name:       "no ballots",
+			wantRounds: []string{"A=0 B=0 C=0 exhausted=0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			results := tallyPollResults(letterPoll(schema.PollTypeRanked), letterBallots(tt.ballots...))
+
+			var rounds []string
+			for i, round := range results.Rounds {
+				if round.Round != i+1 {
+					t.Errorf("round %v is numbered %v", i+1, round.Round)
+				}
+				rounds = append(rounds, describe(round))
+			}
+			if !reflect.DeepEqual(rounds, tt.wantRounds) {
+				t.Errorf("rounds =\n%v\nwant\n%v", strings.Join(rounds, "\n"), strings.Join(tt.wantRounds, "\n"))
+			}
+			if letter(results.Winner) != tt.wantWinner {
+				t.Errorf("Winner = %q, want %q", letter(results.Winner), tt.wantWinner)
+			}
+		})
+	}
+}
+
+func TestTallyCountsBySelection(t *testing.T) {
+
+	// a multi Poll counts every selection, percentages are of the Votes so
+	// they add up to more than 100
+	multi := tallyPollResults(letterPoll(schema.PollTypeMulti), letterBallots("AB", "A", "BC", "A"))
+	if got := describe(schema.RunoffRound{Results: multi.Results}); got != "A=3 B=2 C=1 exhausted=0" {
+		t.Errorf("multi counts = %v", got)
+	}
+	if got := percentages(multi.Results); !reflect.DeepEqual(got, []float64{75, 50, 25}) {
+		t.Errorf("multi percentages = %v", got)
+	}
+	if multi.TotalVotes != 4 || multi.Rounds != nil || multi.Winner != "" {
+		t.Errorf("multi TotalVotes, Rounds, Winner = %v, %v, %q", multi.TotalVotes, multi.Rounds, multi.Winner)
+	}
+
+	// a ranked Poll's Results are the first preferences
+	ranked := tallyPollResults(letterPoll(schema.PollTypeRanked), letterBallots("BA", "CA", "B"))
+	if got := describe(schema.RunoffRound{Results: ranked.Results}); got != "A=0 B=2 C=1 exhausted=0" {
+		t.Errorf("ranked counts = %v", got)
+	}
+}
+
+func percentages(results []schema.PollOptionResult) []float64 {
+	p := make([]float64, 0, len(results))
+	for _, result := range results {
+		p = append(p, result.Percentage)
+	}
+	return p
+}
+
+func TestValidateSelections(t *testing.T) {
+
+	multi := letterPoll(schema.PollTypeMulti)
+	multi.MaxSelections = 2
+
+	tests := []struct {
+		name string
+		poll schema.Poll
+		vote string
+		// wantStatus is the status the error maps to, 0 for none
+		wantStatus int
+		wantValue  string
+		wantValues string
+	}{
+		{name: "single", poll: letterPoll(schema.PollTypeSingle), vote: "A", wantValue: "A"},
+		{name: "single with two", poll: letterPoll(schema.PollTypeSingle), vote: "AB", wantStatus: 400},
+		{name: "nothing selected", poll: letterPoll(schema.PollTypeSingle), vote: "", wantStatus: 400},
+		{name: "an unknown option", poll: letterPoll(schema.PollTypeSingle), vote: "D", wantStatus: 404},
+		{name: "multi up to MaxSelections", poll: multi, vote: "CA", wantValue: "C", wantValues: "CA"},
+		{name: "multi over MaxSelections", poll: multi, vote: "ABC", wantStatus: 400},
+		{name: "multi selecting twice", poll: multi, vote: "AA", wantStatus: 400},
+		{name: "ranked every option", poll: letterPoll(schema.PollTypeRanked), vote: "CBA", wantValue: "C", wantValues: "CBA"},
+		{name: "ranked one option", poll: letterPoll(schema.PollTypeRanked), vote: "B", wantValue: "B", wantValues: "B"},
+		{name: "ranked twice", poll: letterPoll(schema.PollTypeRanked), vote: "ABA", wantStatus: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var vote schema.Vote
+			if tt.vote != "" {
+				vote = letterBallots(tt.vote)[0]
+			}
+
+			err := validateSelections(tt.poll, &vote)
+
+			if tt.wantStatus != 0 {
+				if status, _ := apierror.Status(err); err == nil || status != tt.wantStatus {
+					t.Fatalf("validateSelections() error = %v, want a %v", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateSelections() error = %v", err)
+			}
+			var values string
+			for _, value := range vote.VoteValues {
+				values += letter(value)
+			}
+			if letter(vote.VoteValue) != tt.wantValue || values != tt.wantValues {
+				t.Errorf("VoteValue, VoteValues = %v, %v, want %v, %v", letter(vote.VoteValue), values, tt.wantValue, tt.wantValues)
+			}
+		})
+	}
+}
+
+// A multi Vote over the MaxSelections of its Poll is refused before anything
+// is stored
+func TestAddVoteOverMaxSelections(t *testing.T) {
+
+	poll := letterPoll(schema.PollTypeMulti)
+	poll.MaxSelections = 2
+	f := newFakeAPIs(t, []schema.Poll{poll}, votersOf("/voters/1"))
+	_, r := newTestVotesAPI(t, f)
+
+	body := `{"VoterID": "/voters/1", "PollID": "/polls/1", "VoteValues": ["/polls/1/options/A", "/polls/1/options/B", "/polls/1/options/C"]}`
+	if w := serve(r, http.MethodPost, "/votes/1", body); w.Code != http.StatusBadRequest {
+		t.Fatalf("POST /votes/1 = %v, want 400: %v", w.Code, w.Body)
+	}
+	if w := serve(r, http.MethodGet, "/votes/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /votes/1 = %v, want 404", w.Code)
+	}
+
+	body = `{"VoterID": "/voters/1", "PollID": "/polls/1", "VoteValues": ["/polls/1/options/A", "/polls/1/options/B"]}`
+	if w := serve(r, http.MethodPost, "/votes/1", body); w.Code != http.StatusOK {
+		t.Errorf("POST /votes/1 = %v, want 200: %v", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/requestid"
+	"drexel.edu/votes-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// Votes (in redis) and the Voters' VoteHistory (in the Voter API) are
+// written by separate calls, so they can drift apart. Reconcile walks all of
+// the Votes and all of the Voters and reports (and unless dryRun is set,
+// repairs) the two kinds of drift:
+//   - a Vote without a voterPoll in its Voter's VoteHistory, repaired by
+//     adding the voterPoll to the VoteHistory
+//   - a voterPoll in a VoteHistory without a Vote, repaired by removing the
+//     voterPoll from the VoteHistory
+//
+// The Votes are treated as the source of truth.
+func (v *VotesAPI) Reconcile(ctx context.Context, dryRun bool) (schema.ReconcileReport, error) {
+
+	report := schema.ReconcileReport{
+		DryRun:              dryRun,
+		VotesWithoutHistory: make([]schema.ReconcileIssue, 0),
+		HistoryWithoutVotes: make([]schema.ReconcileIssue, 0),
+	}
+
+	votes, err := v.getAllStoredVotes(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	voters, err := v.getAllVotersFromVoterAPI(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	report.VotesChecked = len(votes)
+	report.VotersChecked = len(voters)
+
+	// voterPolls indexed by VoterID+PollID, like the voterPoll index
+	history := make(map[string]bool)
+	votersByID := make(map[string]bool, len(voters))
+	for _, voter := range voters {
+		votersByID[voter.VoterID] = true
+		for _, voterPoll := range voter.VoteHistory {
+			history[voterPollIndexField(voter.VoterID, voterPoll.PollID)] = true
+		}
+	}
+
+	voted := make(map[string]bool, len(votes))
+	for _, vote := range votes {
+		field := voterPollIndexField(vote.VoterID, vote.PollID)
+		voted[field] = true
+		if history[field] {
+			continue
+		}
+
+		issue := schema.ReconcileIssue{
+			VoteID:  vote.VoteID,
+			VoterID: vote.VoterID,
+			PollID:  vote.PollID,
+			Problem: "Vote has no voterPoll in the Voter's VoteHistory",
+		}
+		if !votersByID[vote.VoterID] {
+			// Nothing to add the voterPoll to
+			issue.Problem = "Vote belongs to a Voter that does not exist"
+		} else if !dryRun {
+			v.repair(ctx, &issue, func() error {
+				return v.voterPollRequest(ctx, http.MethodPost, vote.VoterID, vote.PollID, time.Now().UTC())
+			})
+		}
+		report.VotesWithoutHistory = append(report.VotesWithoutHistory, issue)
+	}
+
+	for _, voter := range voters {
+		for _, voterPoll := range voter.VoteHistory {
+			if voted[voterPollIndexField(voter.VoterID, voterPoll.PollID)] {
+				continue
+			}
+
+			issue := schema.ReconcileIssue{
+				VoterID: voter.VoterID,
+				PollID:  voterPoll.PollID,
+				Problem: "voterPoll in the Voter's VoteHistory has no Vote",
+			}
+			if !dryRun {
+				voterID, pollID := voter.VoterID, voterPoll.PollID
+				v.repair(ctx, &issue, func() error {
+					return v.voterPollRequest(ctx, http.MethodDelete, voterID, pollID, time.Now().UTC())
+				})
+			}
+			report.HistoryWithoutVotes = append(report.HistoryWithoutVotes, issue)
+		}
+	}
+
+	return report, nil
+}
+
+// getAllVotersFromVoterAPI pages through GET /voters of the Voter API
+func (v *VotesAPI) getAllVotersFromVoterAPI(ctx context.Context) ([]schema.Voter, error) {
+
+	voters, err := v.apis.Voters.Pages(RedisScanCount).All(ctx)
+	if err != nil {
+		return nil, apierror.Call(err, "", "Could not get the Voters: %v", err)
+	}
+
+	return voters, nil
+}
+
+func (v *VotesAPI) repair(ctx context.Context, issue *schema.ReconcileIssue, fix func() error) {
+	if err := fix(); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not repair voterPoll %v%v: ", issue.VoterID, issue.PollID), err)
+		issue.Error = err.Error()
+		return
+	}
+	requestid.Logger(ctx).Println(fmt.Sprintf("Repaired voterPoll %v%v: %v", issue.VoterID, issue.PollID, issue.Problem))
+	issue.Repaired = true
+}
+
+// implementation for POST /votes/admin/reconcile?dryRun=true
+// reports (and unless dryRun is true, repairs) drift between the Votes and
+// the Voters' VoteHistory
+func (v *VotesAPI) ReconcileVotes(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dryRun", "false"))
+	if err != nil {
+		requestid.Logger(ctx).Println("Error parsing dryRun: ", err)
+		apierror.Abort(c, apierror.Validation("dryRun", "dryRun must be true or false, %q given.", c.Query("dryRun")))
+		return
+	}
+
+	report, err := v.Reconcile(ctx, dryRun)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error reconciling Votes and VoteHistory: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
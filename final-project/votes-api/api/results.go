@@ -0,0 +1,172 @@
+package api
+
+import (
+	"math"
+
+	"drexel.edu/votes-api/schema"
+)
+
+// tallyPollResults counts the votes cast in poll. Every one of the Poll's
+// PollOptions gets an entry, in the order they appear in the Poll, even if
+// nobody has voted for it yet. Votes for other Polls, and selections that do
+// not match one of the Poll's PollOptions, are not counted.
+//
+// For single and multi Polls every selection counts once and the percentage
+// is of the number of Votes, so in a multi Poll they can add up to more than
+// 100. For ranked Polls Results holds the first preferences, and the Rounds
+// of the instant-runoff count are worked out to find the Winner.
+func tallyPollResults(poll schema.Poll, votes []schema.Vote) schema.PollResults {
+
+	options := make(map[string]bool, len(poll.PollOptions))
+	for _, option := range poll.PollOptions {
+		options[option.PollOptionID] = true
+	}
+
+	// The selections of each counted Vote, restricted to the Poll's options
+	var ballots [][]string
+	for _, vote := range votes {
+		if vote.PollID != poll.PollID {
+			continue
+		}
+		var ballot []string
+		for _, selection := range vote.Selections() {
+			if options[selection] {
+				ballot = append(ballot, selection)
+			}
+		}
+		if len(ballot) == 0 {
+			continue
+		}
+		ballots = append(ballots, ballot)
+	}
+
+	results := schema.PollResults{
+		PollID:       poll.PollID,
+		PollTitle:    poll.PollTitle,
+		PollQuestion: poll.PollQuestion,
+		PollType:     poll.PollType,
+		TotalVotes:   len(ballots),
+	}
+
+	counts := make(map[string]int, len(poll.PollOptions))
+	for _, ballot := range ballots {
+		if poll.PollType == schema.PollTypeRanked {
+			counts[ballot[0]]++
+			continue
+		}
+		for _, selection := range ballot {
+			counts[selection]++
+		}
+	}
+	results.Results = optionResults(poll.PollOptions, counts, len(ballots))
+
+	if poll.PollType == schema.PollTypeRanked {
+		results.Rounds, results.Winner = instantRunoff(poll.PollOptions, ballots)
+	}
+
+	return results
+}
+
+// instantRunoff counts the ranked ballots in rounds. In each round every
+// ballot counts for its highest ranked PollOption that is still in the
+// count. A PollOption with more than half of those votes wins, otherwise the
+// PollOption(s) with the fewest votes are eliminated and the next round is
+// counted. If all of the remaining PollOptions are tied there is no Winner.
+func instantRunoff(pollOptions []schema.PollOption, ballots [][]string) ([]schema.RunoffRound, string) {
+
+	continuing := make(map[string]bool, len(pollOptions))
+	for _, option := range pollOptions {
+		continuing[option.PollOptionID] = true
+	}
+
+	rounds := make([]schema.RunoffRound, 0)
+	for len(continuing) > 0 {
+
+		counts := make(map[string]int, len(continuing))
+		exhausted := 0
+		for _, ballot := range ballots {
+			counted := false
+			for _, selection := range ballot {
+				if continuing[selection] {
+					counts[selection]++
+					counted = true
+					break
+				}
+			}
+			if !counted {
+				exhausted++
+			}
+		}
+		active := len(ballots) - exhausted
+
+		var remaining []schema.PollOption
+		for _, option := range pollOptions {
+			if continuing[option.PollOptionID] {
+				remaining = append(remaining, option)
+			}
+		}
+
+		round := schema.RunoffRound{
+			Round:     len(rounds) + 1,
+			Results:   optionResults(remaining, counts, active),
+			Exhausted: exhausted,
+		}
+
+		if active == 0 {
+			rounds = append(rounds, round)
+			return rounds, ""
+		}
+
+		fewest := -1
+		for _, option := range remaining {
+			count := counts[option.PollOptionID]
+			if count*2 > active {
+				rounds = append(rounds, round)
+				return rounds, option.PollOptionID
+			}
+			if fewest == -1 || count < fewest {
+				fewest = count
+			}
+		}
+
+		for _, option := range remaining {
+			if counts[option.PollOptionID] == fewest {
+				round.Eliminated = append(round.Eliminated, option.PollOptionID)
+			}
+		}
+		rounds = append(rounds, round)
+
+		// Everyone left is tied, there is no way to pick a Winner
+		if len(round.Eliminated) == len(remaining) {
+			return rounds, ""
+		}
+		for _, optionID := range round.Eliminated {
+			delete(continuing, optionID)
+		}
+	}
+
+	return rounds, ""
+}
+
+// optionResults returns the count and percentage of each of the options
+func optionResults(options []schema.PollOption, counts map[string]int, total int) []schema.PollOptionResult {
+	results := make([]schema.PollOptionResult, 0, len(options))
+	for _, option := range options {
+		results = append(results, schema.PollOptionResult{
+			PollOptionID:   option.PollOptionID,
+			PollOptionText: option.PollOptionText,
+			Votes:          counts[option.PollOptionID],
+			Percentage:     percentage(counts[option.PollOptionID], total),
+		})
+	}
+	return results
+}
+
+// percentage returns count as a percentage of total rounded to two decimal
+// places, or 0 when there are no votes at all
+func percentage(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(count)*10000/float64(total)) / 100
+}
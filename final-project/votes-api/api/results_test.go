@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"drexel.edu/votes-api/schema"
+)
+
+// GET /votes/polls/:pollid/results counts the stored Votes of the Poll by
+// VoteValue, over every PollOption of the Poll
+func TestGetPollResults(t *testing.T) {
+
+	poll := openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1", "/polls/1/options/2", "/polls/1/options/3")
+	other := openPoll("/polls/2", schema.PollTypeSingle, "/polls/2/options/1")
+	f := newFakeAPIs(t, []schema.Poll{poll, other}, nil)
+
+	tests := []struct {
+		name string
+		// voteValues are the VoteValues of the stored Votes, by PollID
+		voteValues map[string][]string
+		wantTotal  int
+		wantVotes  map[string]int
+		wantShares map[string]float64
+	}{
+		{
+			name:       "a poll without votes lists every option",
+			wantVotes:  map[string]int{"/polls/1/options/1": 0, "/polls/1/options/2": 0, "/polls/1/options/3": 0},
+			wantShares: map[string]float64{"/polls/1/options/1": 0, "/polls/1/options/2": 0, "/polls/1/options/3": 0},
+		},
+		{
+			name: "votes are counted by option",
+			voteValues: map[string][]string{
+				"/polls/1": {"/polls/1/options/1", "/polls/1/options/1", "/polls/1/options/2"},
+			},
+			wantTotal:  3,
+			wantVotes:  map[string]int{"/polls/1/options/1": 2, "/polls/1/options/2": 1, "/polls/1/options/3": 0},
+			wantShares: map[string]float64{"/polls/1/options/1": 66.67, "/polls/1/options/2": 33.33, "/polls/1/options/3": 0},
+		},
+		{
+			name: "votes in other polls and for unknown options are left out",
+			voteValues: map[string][]string{
+				"/polls/1": {"/polls/1/options/3", "/polls/1/options/9"},
+				"/polls/2": {"/polls/2/options/1", "/polls/2/options/1"},
+			},
+			wantTotal:  1,
+			wantVotes:  map[string]int{"/polls/1/options/1": 0, "/polls/1/options/2": 0, "/polls/1/options/3": 1},
+			wantShares: map[string]float64{"/polls/1/options/1": 0, "/polls/1/options/2": 0, "/polls/1/options/3": 100},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			v, r := newTestVotesAPI(t, f)
+			id := 0
+			for pollID, values := range tt.voteValues {
+				for _, value := range values {
+					id++
+					vote := schema.Vote{VoteID: fmt.Sprintf("/votes/%v", id), VoterID: fmt.Sprintf("/voters/%v", id), PollID: pollID, VoteValue: value}
+					if err := v.store.Set(context.Background(), vote.VoteID, vote); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			w := serve(r, http.MethodGet, "/votes/polls/1/results", "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %v, want 200: %v", w.Code, w.Body)
+			}
+			var results schema.PollResults
+			if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+				t.Fatal(err)
+			}
+
+			if results.PollID != poll.PollID {
+				t.Errorf("PollID = %v, want %v", results.PollID, poll.PollID)
+			}
+			if results.TotalVotes != tt.wantTotal {
+				t.Errorf("TotalVotes = %v, want %v", results.TotalVotes, tt.wantTotal)
+			}
+			if len(results.Results) != len(poll.PollOptions) {
+				t.Fatalf("%v results, want one per option: %+v", len(results.Results), results.Results)
+			}
+			for _, result := range results.Results {
+				if result.Votes != tt.wantVotes[result.PollOptionID] {
+					t.Errorf("%v has %v votes, want %v", result.PollOptionID, result.Votes, tt.wantVotes[result.PollOptionID])
+				}
+				if result.Percentage != tt.wantShares[result.PollOptionID] {
+					t.Errorf("%v has %v%%, want %v%%", result.PollOptionID, result.Percentage, tt.wantShares[result.PollOptionID])
+				}
+			}
+		})
+	}
+
+	t.Run("an unknown poll is not found", func(t *testing.T) {
+		_, r := newTestVotesAPI(t, f)
+		if w := serve(r, http.MethodGet, "/votes/polls/9/results", ""); w.Code != http.StatusNotFound {
+			t.Errorf("status = %v, want 404: %v", w.Code, w.Body)
+		}
+	})
+}
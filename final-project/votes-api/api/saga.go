@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"drexel.edu/common/requestid"
+
+	"drexel.edu/votes-api/schema"
+)
+
+// A Vote lives in two places: the vote:* key in redis and the voterPoll in
+// the Voter's VoteHistory (in the Voter API). A saga runs the writes to both
+// as a list of steps, and if one of the steps fails the steps that already
+// completed are compensated (undone) in reverse order, so that the two
+// stores do not drift apart.
+
+type sagaStep struct {
+	name       string
+	action     func() error
+	compensate func() error
+}
+
+type saga struct {
+	name  string
+	steps []sagaStep
+}
+
+func newSaga(name string) *saga {
+	return &saga{name: name}
+}
+
+// addStep appends a step to the saga. compensate may be nil if there is
+// nothing to undo (e.g. for the last step).
+func (s *saga) addStep(name string, action func() error, compensate func() error) {
+	s.steps = append(s.steps, sagaStep{name: name, action: action, compensate: compensate})
+}
+
+// sagaError is returned by run when one of the steps fails. It carries the
+// outcome of the rollback so it can be returned to the caller.
+type sagaError struct {
+	cause  error
+	result schema.SagaResult
+}
+
+func (e *sagaError) Error() string {
+	return fmt.Sprintf("saga %v failed at step %q: %v", e.result.Saga, e.result.FailedStep, e.cause)
+}
+
+func (e *sagaError) Unwrap() error {
+	return e.cause
+}
+
+// run executes the steps in order. If a step fails, every step that already
+// completed is compensated in reverse order and a *sagaError is returned.
+func (s *saga) run(ctx context.Context) error {
+
+	for i, step := range s.steps {
+		err := step.action()
+		if err == nil {
+			continue
+		}
+
+		requestid.Logger(ctx).Println(fmt.Sprintf("Saga %v: step %q failed, rolling back.", s.name, step.name), err)
+
+		result := schema.SagaResult{
+			Saga:          s.name,
+			Error:         err.Error(),
+			FailedStep:    step.name,
+			RolledBack:    true,
+			Compensations: make([]schema.SagaCompensation, 0, i),
+		}
+
+		for j := i - 1; j >= 0; j-- {
+			done := s.steps[j]
+			if done.compensate == nil {
+				continue
+			}
+
+			compensation := schema.SagaCompensation{Step: done.name, Succeeded: true}
+			if cerr := done.compensate(); cerr != nil {
+				requestid.Logger(ctx).Println(fmt.Sprintf("Saga %v: could not compensate step %q.", s.name, done.name), cerr)
+				compensation.Succeeded = false
+				compensation.Error = cerr.Error()
+				result.RolledBack = false
+			} else {
+				requestid.Logger(ctx).Println(fmt.Sprintf("Saga %v: compensated step %q.", s.name, done.name))
+			}
+			result.Compensations = append(result.Compensations, compensation)
+		}
+
+		return &sagaError{cause: err, result: result}
+	}
+
+	return nil
+}
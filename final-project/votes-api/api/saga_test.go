@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/votes-api/schema"
+)
+
+func TestSagaCompensatesCompletedStepsInReverse(t *testing.T) {
+
+	var calls []string
+	step := func(name string, err error) (func() error, func() error) {
+		return func() error { calls = append(calls, name); return err },
+			func() error { calls = append(calls, "undo "+name); return nil }
+	}
+
+	s := newSaga("test")
+	do, undo := step("first", nil)
+	s.addStep("first", do, undo)
+	do, _ = step("second", nil)
+	s.addStep("second", do, nil)
+	do, undo = step("third", nil)
+	s.addStep("third", do, undo)
+	failed := errors.New("fourth failed")
+	do, undo = step("fourth", failed)
+	s.addStep("fourth", do, undo)
+
+	err := s.run(context.Background())
+
+	if want := []string{"first", "second", "third", "fourth", "undo third", "undo first"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	var sagaErr *sagaError
+	if !errors.As(err, &sagaErr) || !errors.Is(err, failed) {
+		t.Fatalf("run() error = %v, want a *sagaError caused by %v", err, failed)
+	}
+	if sagaErr.result.FailedStep != "fourth" || !sagaErr.result.RolledBack || len(sagaErr.result.Compensations) != 2 {
+		t.Errorf("result = %+v", sagaErr.result)
+	}
+}
+
+// sagaResponse is the ErrorResponse of a failed saga
+type sagaResponse struct {
+	apierror.ErrorResponse
+	Details schema.SagaResult
+}
+
+func decodeSagaResponse(t *testing.T, body []byte) sagaResponse {
+	t.Helper()
+	var resp sagaResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// When the VoteHistory can't be written the Vote is removed from redis again
+// and the Voter's Vote in the Poll is released
+func TestAddVoteRollsBackWhenTheVoterAPIFails(t *testing.T) {
+
+	f := newFakeAPIs(t, []schema.Poll{openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1")}, votersOf("/voters/1"))
+	v, r := newTestVotesAPI(t, f)
+
+	f.setFailHistory(true)
+	w := serve(r, http.MethodPost, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/1"))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %v, want 502: %v", w.Code, w.Body)
+	}
+
+	resp := decodeSagaResponse(t, w.Body.Bytes())
+	if resp.Details.FailedStep != "add voterPoll to VoteHistory" || !resp.Details.RolledBack {
+		t.Errorf("Details = %+v, want a rolled back failure of the VoteHistory", resp.Details)
+	}
+	undone := []string{}
+	for _, compensation := range resp.Details.Compensations {
+		undone = append(undone, compensation.Step)
+	}
+	if want := []string{"add Vote to Redis", "reserve voterPoll"}; !reflect.DeepEqual(undone, want) {
+		t.Errorf("compensated %v, want %v", undone, want)
+	}
+
+	if _, err := v.store.Get(context.Background(), "/votes/1"); err == nil {
+		t.Errorf("the Vote is still stored")
+	}
+	if w := serve(r, http.MethodGet, "/votes/voters/1/polls/1/vote", ""); w.Code != http.StatusNotFound {
+		t.Errorf("the Voter's Vote in the Poll is still reserved: %v %v", w.Code, w.Body)
+	}
+
+	f.setFailHistory(false)
+	if w := serve(r, http.MethodPost, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/1")); w.Code != http.StatusOK {
+		t.Errorf("retrying the Vote = %v, want 200: %v", w.Code, w.Body)
+	}
+	if history := f.history("/voters/1"); !reflect.DeepEqual(history, []string{"/polls/1"}) {
+		t.Errorf("VoteHistory = %v, want [/polls/1]", history)
+	}
+}
+
+// When the VoteHistory can't be written the Vote is changed back
+func TestUpdateVoteRollsBackWhenTheVoterAPIFails(t *testing.T) {
+
+	f := newFakeAPIs(t, []schema.Poll{openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1", "/polls/1/options/2")}, votersOf("/voters/1"))
+	_, r := newTestVotesAPI(t, f)
+
+	if w := serve(r, http.MethodPost, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/1")); w.Code != http.StatusOK {
+		t.Fatalf("POST /votes/1 = %v: %v", w.Code, w.Body)
+	}
+
+	f.setFailHistory(true)
+	if w := serve(r, http.MethodPut, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/2")); w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %v, want 502: %v", w.Code, w.Body)
+	}
+
+	w := serve(r, http.MethodGet, "/votes/1", "")
+	var vote schema.Vote
+	if err := json.Unmarshal(w.Body.Bytes(), &vote); err != nil {
+		t.Fatal(err)
+	}
+	if vote.VoteValue != "/polls/1/options/1" {
+		t.Errorf("VoteValue = %v, want the one from before the update", vote.VoteValue)
+	}
+}
+
+// The Message of a failed saga is the error of the step, its outcome is only
+// in the Details
+func TestAddVoteConflictMessage(t *testing.T) {
+
+	f := newFakeAPIs(t, []schema.Poll{openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1")}, votersOf("/voters/1", "/voters/2"))
+	_, r := newTestVotesAPI(t, f)
+
+	if w := serve(r, http.MethodPost, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/1")); w.Code != http.StatusOK {
+		t.Fatalf("POST /votes/1 = %v: %v", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name        string
+		path, body  string
+		wantField   string
+		wantMessage string
+	}{
+		{
+			name:        "a second vote of the voter in the poll",
+			path:        "/votes/2",
+			body:        voteBody("/voters/1", "/polls/1", "/polls/1/options/1"),
+			wantField:   "VoterID",
+			wantMessage: "Voter /voters/1 already has a Vote in Poll /polls/1.",
+		},
+		{
+			name:        "a vote that exists",
+			path:        "/votes/1",
+			body:        voteBody("/voters/2", "/polls/1", "/polls/1/options/1"),
+			wantField:   "VoteID",
+			wantMessage: "Vote /votes/1 already exists, to update a vote use the PUT method.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodPost, tt.path, tt.body)
+			if w.Code != http.StatusConflict {
+				t.Fatalf("status = %v, want 409: %v", w.Code, w.Body)
+			}
+			resp := decodeSagaResponse(t, w.Body.Bytes())
+			if resp.Message != tt.wantMessage || resp.Field != tt.wantField {
+				t.Errorf("Field, Message = %v, %q, want %v, %q", resp.Field, resp.Message, tt.wantField, tt.wantMessage)
+			}
+			if !strings.HasPrefix(resp.Details.Saga, "AddVote") || resp.Details.Error != tt.wantMessage {
+				t.Errorf("Details = %+v, want the outcome of the AddVote saga", resp.Details)
+			}
+		})
+	}
+}
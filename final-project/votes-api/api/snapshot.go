@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/audit"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/requestid"
+	"drexel.edu/votes-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// A snapshot only holds the Votes, the Voters' VoteHistory is exported and
+// imported by the Voter API. Importing both snapshots (and the Poll API's)
+// restores the whole voting system, and POST /votes/admin/reconcile can be
+// used afterwards to check that they agree.
+
+// Export returns a snapshot of every Vote
+func (v *VotesAPI) Export(ctx context.Context) (docstore.Snapshot[schema.Vote], error) {
+
+	votes, err := v.getAllStoredVotes(ctx)
+	if err != nil {
+		return docstore.Snapshot[schema.Vote]{}, err
+	}
+
+	return docstore.NewSnapshot(docstore.KindVotes, votes), nil
+}
+
+// Empty reports whether there are no Votes at all, the snapshot of -restore
+// is only imported then
+func (v *VotesAPI) Empty(ctx context.Context) (bool, error) {
+	votes, _, err := v.getStoredVotes(ctx, 0, 1)
+	return len(votes) == 0, err
+}
+
+// Import adds every Vote in the snapshot, overwriting the Votes that have the
+// same VoteID, and records them in the voterPoll index and (as imported by
+// actor) in the audit log. It returns how many were imported. Nothing is
+// imported if a Vote has no VoteID, or if it would give a Voter a second Vote
+// in a Poll.
+func (v *VotesAPI) Import(ctx context.Context, snapshot docstore.Snapshot[schema.Vote], actor audit.Actor) (int, error) {
+
+	if err := snapshot.Check(docstore.KindVotes); err != nil {
+		return 0, apierror.Validation("", "Invalid snapshot: %v", err)
+	}
+
+	voterPolls := make(map[string]string, len(snapshot.Items))
+	for i, vote := range snapshot.Items {
+		if vote.VoteID == "" {
+			return 0, apierror.Validation("VoteID", "Vote %v in the snapshot has no VoteID.", i)
+		}
+
+		field := voterPollIndexField(vote.VoterID, vote.PollID)
+		if other, ok := voterPolls[field]; ok {
+			return 0, apierror.Wrap(ErrVoterAlreadyVoted, "VoterID", "Votes %v and %v in the snapshot are both Voter %v's Vote in Poll %v.", other, vote.VoteID, vote.VoterID, vote.PollID)
+		}
+		voterPolls[field] = vote.VoteID
+
+		existing, err := v.lookupVoterPoll(ctx, vote.VoterID, vote.PollID)
+		if err != nil && !errors.Is(err, docstore.ErrNotFound) {
+			return 0, err
+		}
+		if err == nil && existing != vote.VoteID {
+			return 0, apierror.Wrap(ErrVoterAlreadyVoted, "VoterID", "Voter %v already has Vote %v in Poll %v, so Vote %v can't be imported.", vote.VoterID, existing, vote.PollID, vote.VoteID)
+		}
+	}
+
+	for i, vote := range snapshot.Items {
+		// a Vote that is overwritten may have been cast by another Voter
+		// or in another Poll, which then can vote again
+		var before *schema.Vote
+		if old, err := v.store.Get(ctx, vote.VoteID); err == nil {
+			before = &old
+			if old.VoterID != vote.VoterID || old.PollID != vote.PollID {
+				if err := v.releaseVoterPoll(ctx, old.VoterID, old.PollID); err != nil {
+					return i, err
+				}
+			}
+		}
+		if err := v.store.Set(ctx, vote.VoteID, vote); err != nil {
+			return i, err
+		}
+		if err := v.setVoterPoll(ctx, vote.VoterID, vote.PollID, vote.VoteID); err != nil {
+			return i, err
+		}
+		v.changed(ctx, actor, audit.ActionImport, before, &vote)
+	}
+
+	return len(snapshot.Items), nil
+}
+
+// /admin/export
+// returns a snapshot of every Vote, which can be restored with
+// POST /admin/import
+func (v *VotesAPI) ExportVotes(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	snapshot, err := v.Export(ctx)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error exporting Votes: ", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// /admin/import
+// adds every Vote in a snapshot from GET /admin/export, overwriting the
+// Votes with the same VoteID
+func (v *VotesAPI) ImportVotes(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	var snapshot docstore.Snapshot[schema.Vote]
+	if err := c.ShouldBindJSON(&snapshot); err != nil {
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
+		return
+	}
+
+	imported, err := v.Import(ctx, snapshot, audit.ActorFrom(c))
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Error importing Votes, %v were imported: ", imported), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, docstore.ImportResult{Kind: snapshot.Kind, Imported: imported})
+}
@@ -0,0 +1,451 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"drexel.edu/common/docstore"
+	"drexel.edu/votes-api/schema"
+	"github.com/go-redis/redis/v8"
+	"github.com/nitishm/go-rejson/v4"
+)
+
+// VoteStore is where the VotesAPI keeps its Votes, by VoteID, along with the
+// voterPoll and pollVotes indexes (see index.go). Get and LookupVoterPoll
+// return docstore.ErrNotFound when there is nothing stored. Set, SetNX and
+// Delete keep the pollVotes index up to date themselves.
+type VoteStore interface {
+	Get(ctx context.Context, voteID string) (schema.Vote, error)
+	Set(ctx context.Context, voteID string, vote schema.Vote) error
+	// SetNX adds the Vote unless a Vote with the VoteID exists, and reports
+	// whether it did
+	SetNX(ctx context.Context, voteID string, vote schema.Vote) (bool, error)
+	Delete(ctx context.Context, voteID string) (bool, error)
+	List(ctx context.Context, cursor uint64, limit int64) ([]schema.Vote, uint64, error)
+	// ListPoll returns the Votes cast in the Poll pollID, read through the
+	// pollVotes index rather than from every Vote
+	ListPoll(ctx context.Context, pollID string) ([]schema.Vote, error)
+
+	ClaimVoterPoll(ctx context.Context, field, voteID string) (bool, error)
+	ReleaseVoterPoll(ctx context.Context, field string) error
+	SetVoterPoll(ctx context.Context, field, voteID string) error
+	LookupVoterPoll(ctx context.Context, field string) (string, error)
+	VoterPollIndexExists(ctx context.Context) (bool, error)
+	// CreateVoterPollIndex writes index as the whole voterPoll index at
+	// once, unless the index exists by then. Either all of it is written or
+	// none of it.
+	CreateVoterPollIndex(ctx context.Context, index map[string]string) error
+	PollVotesIndexExists(ctx context.Context) (bool, error)
+	// CreatePollVotesIndex adds every Vote of votes to the pollVotes index
+	CreatePollVotesIndex(ctx context.Context, votes []schema.Vote) error
+}
+
+//------------------------------------------------------------
+// REDIS
+//------------------------------------------------------------
+
+// redisVoteStore keeps the Votes under vote:<VoteID>, the voterPoll index in
+// the redis hash RedisVoterPollIndexKey and the pollVotes index in a redis set
+// per Poll
+type redisVoteStore struct {
+	*docstore.Redis[schema.Vote]
+	client *redis.Client
+}
+
+func newRedisVoteStore(client *redis.Client, helper *rejson.Handler) *redisVoteStore {
+	return &redisVoteStore{
+		Redis:  docstore.NewRedis[schema.Vote](client, helper, RedisKeyPrefix),
+		client: client,
+	}
+}
+
+func pollVotesKey(pollID string) string {
+	return RedisPollVotesIndexPrefix + pollID
+}
+
+// Set also adds the Vote to the pollVotes index of its Poll, and removes it
+// from that of the Poll it was cast in before if it changed (only an import
+// can do that), in the same transaction as the Vote
+func (s *redisVoteStore) Set(ctx context.Context, voteID string, vote schema.Vote) error {
+
+	data, err := json.Marshal(vote)
+	if err != nil {
+		return err
+	}
+
+	key := RedisKeyPrefix + voteID
+	return s.watch(ctx, key, func(tx *redis.Tx) error {
+
+		before, err := s.Redis.Get(ctx, voteID)
+		existed := err == nil
+		if err != nil && !errors.Is(err, docstore.ErrNotFound) {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Do(ctx, "JSON.SET", key, ".", string(data))
+			if existed && before.PollID != vote.PollID {
+				pipe.SRem(ctx, pollVotesKey(before.PollID), voteID)
+			}
+			pipe.SAdd(ctx, pollVotesKey(vote.PollID), voteID)
+			return nil
+		})
+		return err
+	})
+}
+
+// SetNX adds the Vote and adds it to the pollVotes index of its Poll, in one
+// transaction, unless a Vote with the VoteID exists. It reports whether the
+// Vote was added.
+func (s *redisVoteStore) SetNX(ctx context.Context, voteID string, vote schema.Vote) (bool, error) {
+
+	data, err := json.Marshal(vote)
+	if err != nil {
+		return false, err
+	}
+
+	key := RedisKeyPrefix + voteID
+	added := false
+	err = s.client.Watch(ctx, func(tx *redis.Tx) error {
+
+		exists, err := tx.Exists(ctx, key).Result()
+		if err != nil || exists > 0 {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Do(ctx, "JSON.SET", key, ".", string(data), "NX")
+			pipe.SAdd(ctx, pollVotesKey(vote.PollID), voteID)
+			return nil
+		})
+		added = err == nil
+		return err
+	}, key)
+
+	// the Vote was written by someone else in between
+	if errors.Is(err, redis.TxFailedErr) {
+		return false, nil
+	}
+	return added, err
+}
+
+// Delete also removes the Vote from the pollVotes index of its Poll, in the
+// same transaction
+func (s *redisVoteStore) Delete(ctx context.Context, voteID string) (bool, error) {
+
+	key := RedisKeyPrefix + voteID
+	deleted := false
+	err := s.watch(ctx, key, func(tx *redis.Tx) error {
+
+		vote, err := s.Redis.Get(ctx, voteID)
+		if errors.Is(err, docstore.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var del *redis.IntCmd
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			del = pipe.Del(ctx, key)
+			pipe.SRem(ctx, pollVotesKey(vote.PollID), voteID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		deleted = del.Val() > 0
+		return nil
+	})
+	return deleted, err
+}
+
+// watch runs fn in a transaction that fails if the key changes before it
+// commits, and runs it again (up to RedisTxRetries times) when it does
+func (s *redisVoteStore) watch(ctx context.Context, key string, fn func(tx *redis.Tx) error) error {
+
+	var err error
+	for i := 0; i < RedisTxRetries; i++ {
+		err = s.client.Watch(ctx, fn, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("%v kept changing: %w", key, err)
+}
+
+// ListPoll skips the VoteIDs of the index whose Vote is gone, or was moved to
+// another Poll, which the Votes that were stored before the index was built
+// can be (see CreatePollVotesIndex)
+func (s *redisVoteStore) ListPoll(ctx context.Context, pollID string) ([]schema.Vote, error) {
+
+	voteIDs, err := s.client.SMembers(ctx, pollVotesKey(pollID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make([]schema.Vote, 0, len(voteIDs))
+	for _, voteID := range voteIDs {
+		vote, err := s.Redis.Get(ctx, voteID)
+		if errors.Is(err, docstore.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("getting Vote %v: %w", voteID, err)
+		}
+		if vote.PollID == pollID {
+			votes = append(votes, vote)
+		}
+	}
+	return votes, nil
+}
+
+func (s *redisVoteStore) ClaimVoterPoll(ctx context.Context, field, voteID string) (bool, error) {
+	return s.client.HSetNX(ctx, RedisVoterPollIndexKey, field, voteID).Result()
+}
+
+func (s *redisVoteStore) ReleaseVoterPoll(ctx context.Context, field string) error {
+	return s.client.HDel(ctx, RedisVoterPollIndexKey, field).Err()
+}
+
+func (s *redisVoteStore) SetVoterPoll(ctx context.Context, field, voteID string) error {
+	return s.client.HSet(ctx, RedisVoterPollIndexKey, field, voteID).Err()
+}
+
+func (s *redisVoteStore) LookupVoterPoll(ctx context.Context, field string) (string, error) {
+	voteID, err := s.client.HGet(ctx, RedisVoterPollIndexKey, field).Result()
+	if err != nil && isRedisNilError(err) {
+		return "", docstore.ErrNotFound
+	}
+	return voteID, err
+}
+
+func (s *redisVoteStore) VoterPollIndexExists(ctx context.Context) (bool, error) {
+	exists, err := s.client.Exists(ctx, RedisVoterPollIndexKey).Result()
+	return exists > 0, err
+}
+
+// CreateVoterPollIndex writes the index to a temporary key and then renames
+// it, so that an index that was only partly written is never used
+func (s *redisVoteStore) CreateVoterPollIndex(ctx context.Context, index map[string]string) error {
+
+	if len(index) == 0 {
+		return nil
+	}
+
+	building := RedisVoterPollIndexKey + ":building"
+	if err := s.client.Del(ctx, building).Err(); err != nil {
+		return err
+	}
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		fields := make([]interface{}, 0, 2*RedisScanCount)
+		for field, voteID := range index {
+			fields = append(fields, field, voteID)
+			if len(fields) == cap(fields) {
+				pipe.HSet(ctx, building, fields...)
+				fields = fields[:0]
+			}
+		}
+		if len(fields) > 0 {
+			pipe.HSet(ctx, building, fields...)
+		}
+		return nil
+	})
+	if err != nil {
+		s.client.Del(ctx, building)
+		return err
+	}
+
+	// another Votes API may have created the index in the meantime, its
+	// index is kept
+	renamed, err := s.client.RenameNX(ctx, building, RedisVoterPollIndexKey).Result()
+	if err != nil || !renamed {
+		s.client.Del(ctx, building)
+	}
+	return err
+}
+
+func (s *redisVoteStore) PollVotesIndexExists(ctx context.Context) (bool, error) {
+	exists, err := s.client.Exists(ctx, RedisPollVotesIndexBuiltKey).Result()
+	return exists > 0, err
+}
+
+// CreatePollVotesIndex marks the index as built once every Vote was added.
+// A Vote deleted in the meantime may be added back to the index, ListPoll
+// skips it.
+func (s *redisVoteStore) CreatePollVotesIndex(ctx context.Context, votes []schema.Vote) error {
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, vote := range votes {
+			pipe.SAdd(ctx, pollVotesKey(vote.PollID), vote.VoteID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(ctx, RedisPollVotesIndexBuiltKey, 1, 0).Err()
+}
+
+//------------------------------------------------------------
+// MEMORY
+//------------------------------------------------------------
+
+// memoryVoteStore keeps the Votes and both indexes in memory, they are lost
+// when the process exits
+type memoryVoteStore struct {
+	*docstore.Memory[schema.Vote]
+	mu        sync.Mutex
+	index     map[string]string
+	pollVotes map[string]map[string]bool
+}
+
+func newMemoryVoteStore() *memoryVoteStore {
+	return &memoryVoteStore{
+		Memory:    docstore.NewMemory[schema.Vote](),
+		index:     make(map[string]string),
+		pollVotes: make(map[string]map[string]bool),
+	}
+}
+
+// Set also adds the Vote to the pollVotes index of its Poll, and removes it
+// from that of the Poll it was cast in before
+func (s *memoryVoteStore) Set(ctx context.Context, voteID string, vote schema.Vote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if before, err := s.Memory.Get(ctx, voteID); err == nil {
+		s.removePollVote(before.PollID, voteID)
+	}
+	if err := s.Memory.Set(ctx, voteID, vote); err != nil {
+		return err
+	}
+	s.addPollVote(vote.PollID, voteID)
+	return nil
+}
+
+// SetNX adds the Vote, and adds it to the pollVotes index of its Poll, unless
+// a Vote with the VoteID exists
+func (s *memoryVoteStore) SetNX(ctx context.Context, voteID string, vote schema.Vote) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Memory.Get(ctx, voteID); err == nil {
+		return false, nil
+	}
+	if err := s.Memory.Set(ctx, voteID, vote); err != nil {
+		return false, err
+	}
+	s.addPollVote(vote.PollID, voteID)
+	return true, nil
+}
+
+// Delete also removes the Vote from the pollVotes index of its Poll
+func (s *memoryVoteStore) Delete(ctx context.Context, voteID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if before, err := s.Memory.Get(ctx, voteID); err == nil {
+		s.removePollVote(before.PollID, voteID)
+	}
+	return s.Memory.Delete(ctx, voteID)
+}
+
+func (s *memoryVoteStore) addPollVote(pollID, voteID string) {
+	if s.pollVotes[pollID] == nil {
+		s.pollVotes[pollID] = make(map[string]bool)
+	}
+	s.pollVotes[pollID][voteID] = true
+}
+
+func (s *memoryVoteStore) removePollVote(pollID, voteID string) {
+	delete(s.pollVotes[pollID], voteID)
+	if len(s.pollVotes[pollID]) == 0 {
+		delete(s.pollVotes, pollID)
+	}
+}
+
+// ListPoll returns the Votes ordered by VoteID, like List
+func (s *memoryVoteStore) ListPoll(ctx context.Context, pollID string) ([]schema.Vote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	voteIDs := make([]string, 0, len(s.pollVotes[pollID]))
+	for voteID := range s.pollVotes[pollID] {
+		voteIDs = append(voteIDs, voteID)
+	}
+	sort.Strings(voteIDs)
+
+	votes := make([]schema.Vote, 0, len(voteIDs))
+	for _, voteID := range voteIDs {
+		vote, err := s.Memory.Get(ctx, voteID)
+		if err != nil {
+			return nil, fmt.Errorf("getting Vote %v: %w", voteID, err)
+		}
+		votes = append(votes, vote)
+	}
+	return votes, nil
+}
+
+func (s *memoryVoteStore) ClaimVoterPoll(ctx context.Context, field, voteID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[field]; ok {
+		return false, nil
+	}
+	s.index[field] = voteID
+	return true, nil
+}
+
+func (s *memoryVoteStore) ReleaseVoterPoll(ctx context.Context, field string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.index, field)
+	return nil
+}
+
+func (s *memoryVoteStore) SetVoterPoll(ctx context.Context, field, voteID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.index[field] = voteID
+	return nil
+}
+
+func (s *memoryVoteStore) LookupVoterPoll(ctx context.Context, field string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	voteID, ok := s.index[field]
+	if !ok {
+		return "", docstore.ErrNotFound
+	}
+	return voteID, nil
+}
+
+// The in-memory index always exists, it starts out empty just like the Votes
+func (s *memoryVoteStore) VoterPollIndexExists(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (s *memoryVoteStore) CreateVoterPollIndex(ctx context.Context, index map[string]string) error {
+	return nil
+}
+
+// The in-memory pollVotes index always exists, the writes keep it from
+// the first Vote on
+func (s *memoryVoteStore) PollVotesIndexExists(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (s *memoryVoteStore) CreatePollVotesIndex(ctx context.Context, votes []schema.Vote) error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"drexel.edu/votes-api/schema"
+)
+
+func votersOf(voterIDs ...string) []schema.Voter {
+	voters := make([]schema.Voter, 0, len(voterIDs))
+	for _, voterID := range voterIDs {
+		voters = append(voters, schema.Voter{VoterID: voterID})
+	}
+	return voters
+}
+
+func voteBody(voterID, pollID, voteValue string) string {
+	return fmt.Sprintf(`{"VoterID": %q, "PollID": %q, "VoteValue": %q}`, voterID, pollID, voteValue)
+}
+
+// A Voter has at most one Vote in each Poll, and a VoteID is only added once
+func TestAddVoteOneVotePerVoterPerPoll(t *testing.T) {
+
+	polls := []schema.Poll{
+		openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1"),
+		openPoll("/polls/2", schema.PollTypeSingle, "/polls/2/options/1"),
+	}
+	f := newFakeAPIs(t, polls, votersOf("/voters/1", "/voters/2"))
+	_, r := newTestVotesAPI(t, f)
+
+	steps := []struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		{http.MethodPost, "/votes/1", voteBody("/voters/1", "/polls/1", "/polls/1/options/1"), http.StatusOK},
+		// a second Vote of the Voter in the Poll
+		{http.MethodPost, "/votes/2", voteBody("/voters/1", "/polls/1", "/polls/1/options/1"), http.StatusConflict},
+		{http.MethodGet, "/votes/2", "", http.StatusNotFound},
+		// a Vote of the Voter in another Poll
+		{http.MethodPost, "/votes/3", voteBody("/voters/1", "/polls/2", "/polls/2/options/1"), http.StatusOK},
+		// another Voter reusing a VoteID, which must not hold their Vote in the Poll
+		{http.MethodPost, "/votes/1", voteBody("/voters/2", "/polls/1", "/polls/1/options/1"), http.StatusConflict},
+		{http.MethodPost, "/votes/4", voteBody("/voters/2", "/polls/1", "/polls/1/options/1"), http.StatusOK},
+		// deleting the Vote frees the Voter's Vote in the Poll
+		{http.MethodDelete, "/votes/1", "", http.StatusOK},
+		{http.MethodPost, "/votes/5", voteBody("/voters/1", "/polls/1", "/polls/1/options/1"), http.StatusOK},
+	}
+
+	for i, step := range steps {
+		if w := serve(r, step.method, step.path, step.body); w.Code != step.wantStatus {
+			t.Fatalf("step %v: %v %v = %v, want %v: %v", i, step.method, step.path, w.Code, step.wantStatus, w.Body)
+		}
+	}
+}
+
+// Of concurrent Votes of one Voter in one Poll exactly one is added
+func TestAddVoteConcurrent(t *testing.T) {
+
+	f := newFakeAPIs(t, []schema.Poll{openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1")}, votersOf("/voters/1"))
+	v, r := newTestVotesAPI(t, f)
+
+	const n = 10
+	statuses := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := serve(r, http.MethodPost, fmt.Sprintf("/votes/%v", i+1), voteBody("/voters/1", "/polls/1", "/polls/1/options/1"))
+			statuses <- w.Code
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	added := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			added++
+		case http.StatusConflict:
+		default:
+			t.Errorf("status = %v, want 200 or 409", status)
+		}
+	}
+	if added != 1 {
+		t.Errorf("%v Votes were added, want 1", added)
+	}
+
+	votes, err := v.store.ListPoll(context.Background(), "/polls/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(votes) != 1 {
+		t.Errorf("%v Votes are stored, want 1", len(votes))
+	}
+}
+
+func TestGetVoterPollVote(t *testing.T) {
+
+	f := newFakeAPIs(t, []schema.Poll{openPoll("/polls/1", schema.PollTypeSingle, "/polls/1/options/1")}, votersOf("/voters/1", "/voters/2"))
+	_, r := newTestVotesAPI(t, f)
+
+	if w := serve(r, http.MethodPost, "/votes/7", voteBody("/voters/1", "/polls/1", "/polls/1/options/1")); w.Code != http.StatusOK {
+		t.Fatalf("POST /votes/7 = %v: %v", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantVoteID string
+	}{
+		{"the vote of the voter", "/votes/voters/1/polls/1/vote", http.StatusOK, "/votes/7"},
+		{"a voter who has not voted", "/votes/voters/2/polls/1/vote", http.StatusNotFound, ""},
+		{"a poll the voter has not voted in", "/votes/voters/1/polls/2/vote", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(r, http.MethodGet, tt.path, "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v: %v", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantVoteID == "" {
+				return
+			}
+			var vote schema.Vote
+			if err := json.Unmarshal(w.Body.Bytes(), &vote); err != nil {
+				t.Fatal(err)
+			}
+			if vote.VoteID != tt.wantVoteID || vote.VoterID != "/voters/1" {
+				t.Errorf("Vote = %+v, want %v of /voters/1", vote, tt.wantVoteID)
+			}
+		})
+	}
+}
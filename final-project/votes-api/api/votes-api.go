@@ -2,101 +2,208 @@ package api
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
+	"drexel.edu/client"
+	"drexel.edu/common/apierror"
+	"drexel.edu/common/audit"
+	"drexel.edu/common/auth"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/health"
+	"drexel.edu/common/metrics"
+	"drexel.edu/common/paging"
+	"drexel.edu/common/requestid"
+	"drexel.edu/common/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
 
 	"drexel.edu/votes-api/schema"
-	"github.com/go-resty/resty/v2"
 )
 
 const (
 	RedisNilError        = "redis: nil"
 	RedisDefaultLocation = "0.0.0.0:6379"
 	RedisKeyPrefix       = "vote:"
+	RedisScanCount       = 100
+	// RedisTxRetries is how many times a write of a Vote is tried when the
+	// Vote changes in the middle of its transaction
+	RedisTxRetries = 3
 )
 
-type cache struct {
-	client  *redis.Client
-	helper  *rejson.Handler
-	context context.Context
-}
+// The errors returned by the VotesAPI, each wraps the apierror kind that
+// says which HTTP status it maps to
+var (
+	ErrVoteNotFound      = fmt.Errorf("Vote %w", apierror.ErrNotFound)
+	ErrVoteExists        = fmt.Errorf("Vote %w", apierror.ErrConflict)
+	ErrVoterAlreadyVoted = fmt.Errorf("Vote of the Voter in the Poll %w", apierror.ErrConflict)
+	ErrPollNotOpen       = fmt.Errorf("Poll is not open, voting is %w", apierror.ErrForbidden)
+)
 
 type VotesAPI struct {
-	cache
-	voterAPIURL string
-	pollAPIURL  string
-	apiClient   *resty.Client
+	store  VoteStore
+	audit  audit.Log
+	events VoteEvents
+	// apis calls the Voter API and the Poll API
+	apis   *client.Client
+	checks []health.Check
+	nonces auth.Nonces
+	polls  *cache[schema.Poll]
+	voters *cache[schema.Voter]
+	// streams tallies the results of the Polls with results streams
+	streams *resultsStreams
+
+	// client is nil when the Votes are kept in memory, stopChanges ends the
+	// subscription to the changes to Polls and Voters and closing is closed
+	// to end the results streams
+	client      *redis.Client
+	stopChanges context.CancelFunc
+	closing     chan struct{}
+	closeOnce   sync.Once
 }
 
-func NewVotesAPI(location string, voterAPIurl string, pollAPIurl string) (*VotesAPI, error) {
-
-	apiClient := resty.New()
+// NewVotesAPI returns a VotesAPI that keeps its Votes (and its audit log) in
+// redis, connected to with redisOptions
+func NewVotesAPI(redisOptions *redis.Options, voterAPIurl string, pollAPIurl string) (*VotesAPI, error) {
 
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
+	client := redis.NewClient(redisOptions)
+	client.AddHook(metrics.RedisHook{})
+	client.AddHook(tracing.RedisHook{})
 
-	ctx := context.Background()
-
-	err := client.Ping(ctx).Err()
+	err := client.Ping(context.Background()).Err()
 	if err != nil {
 		log.Println("Error connecting to redis" + err.Error())
 		return nil, err
 	}
 
 	jsonHelper := rejson.NewReJSONHandler()
-	jsonHelper.SetGoRedisClientWithContext(ctx, client)
+	jsonHelper.SetGoRedisClient(client)
 
-	return &VotesAPI{
-		cache: cache{
-			client:  client,
-			helper:  jsonHelper,
-			context: ctx,
-		},
-		voterAPIURL: voterAPIurl,
-		pollAPIURL:  pollAPIurl,
-		apiClient:   apiClient,
-	}, nil
-}
-
-func (v *VotesAPI) NewVoterVoteHistoryString(pollID string) string {
-	newVoter := schema.Voter{
-		VoteHistory: []schema.VoterPoll{
-			schema.VoterPoll{
-				PollID:   pollID,
-				VoteDate: time.Now().UTC(),
-			},
+	votesAPI := NewVotesAPIWithStore(newRedisVoteStore(client, jsonHelper), audit.NewRedis(client), newRedisVoteEvents(client), voterAPIurl, pollAPIurl)
+
+	// the API can't keep Voters to one Vote per Poll without the index
+	if err := votesAPI.buildVoterPollIndex(context.Background()); err != nil {
+		log.Println("Error building the voterPoll index: " + err.Error())
+		client.Close()
+		return nil, err
+	}
+	if err := votesAPI.buildPollVotesIndex(context.Background()); err != nil {
+		log.Println("Error building the pollVotes index: " + err.Error())
+		client.Close()
+		return nil, err
+	}
+
+	votesAPI.checks = append(votesAPI.checks, health.Redis(client))
+	votesAPI.nonces = auth.NewRedisNonces(client)
+	votesAPI.client = client
+	votesAPI.invalidateOnChanges(client)
+	return votesAPI, nil
+}
+
+// NewInMemoryVotesAPI returns a VotesAPI that keeps its Votes (and its audit
+// log) in memory rather than in redis, they are lost when the process exits.
+// Its VoteEvents only reach the results streams of this process.
+func NewInMemoryVotesAPI(voterAPIurl string, pollAPIurl string) *VotesAPI {
+	return NewVotesAPIWithStore(newMemoryVoteStore(), audit.NewMemory(), newMemoryVoteEvents(), voterAPIurl, pollAPIurl)
+}
+
+// NewVotesAPIWithStore returns a VotesAPI that keeps its Votes in store,
+// records every change to them in auditLog and publishes it to events. The
+// voterPoll and pollVotes indexes of store are not built, see
+// buildVoterPollIndex and buildPollVotesIndex.
+func NewVotesAPIWithStore(store VoteStore, auditLog audit.Log, events VoteEvents, voterAPIurl string, pollAPIurl string) *VotesAPI {
+
+	votesAPI := &VotesAPI{
+		store:  store,
+		audit:  auditLog,
+		events: events,
+		apis: client.New(client.Config{
+			VoterAPI: voterAPIurl,
+			PollAPI:  pollAPIurl,
+			HTTP:     auth.ServiceClient(auth.ServiceVotesAPI),
+		}),
+		checks: []health.Check{
+			health.API(auth.ServiceVoterAPI, voterAPIurl),
+			health.API(auth.ServicePollAPI, pollAPIurl),
 		},
+		nonces:      auth.NewMemoryNonces(),
+		polls:       newCache[schema.Poll](audit.EntityPoll, 0, CacheSize),
+		voters:      newCache[schema.Voter](audit.EntityVoter, 0, CacheSize),
+		stopChanges: func() {},
+		closing:     make(chan struct{}),
 	}
-	bvoter, _ := json.Marshal(&newVoter)
-	return string(bvoter)
+	votesAPI.streams = newResultsStreams(events, votesAPI.tallyPoll)
+
+	return votesAPI
+}
+
+// HealthChecks returns the checks of the dependencies of the VotesAPI for
+// GET /readyz: the Voter API, the Poll API and redis (unless the Votes are
+// kept in memory)
+func (v *VotesAPI) HealthChecks() []health.Check {
+	return v.checks
 }
 
+// Nonces returns where the nonces of the signed requests are remembered, in
+// redis unless the Votes are kept in memory
+func (v *VotesAPI) Nonces() auth.Nonces {
+	return v.nonces
+}
+
+// StopStreams ends every results stream, they would otherwise keep the
+// server from shutting down
+func (v *VotesAPI) StopStreams() {
+	v.closeOnce.Do(func() { close(v.closing) })
+}
+
+// Close ends the results streams and the subscription to the changes to
+// Polls and Voters, and closes the redis client of the VotesAPI, once it has
+// stopped serving requests
+func (v *VotesAPI) Close() error {
+	v.StopStreams()
+	v.stopChanges()
+	if v.client == nil {
+		return nil
+	}
+	return v.client.Close()
+}
+
+// /votes
+// returns all Votes, or with ?limit= and/or ?cursor= a VotePage
 func (v *VotesAPI) GetAllVotes(c *gin.Context) {
 
-	var votes []schema.Vote
+	ctx := c.Request.Context()
+
+	paged, cursor, limit, err := paging.Params(c)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error reading the page parameters: ", err)
+		apierror.Abort(c, err)
+		return
+	}
 
-	key := RedisKeyPrefix + "*"
-	ks, _ := v.cache.client.Keys(v.context, key).Result()
-	for _, key := range ks {
-		var vote schema.Vote
-		err := v.getVoteFromRedis(key, &vote)
+	if paged {
+		votes, next, err := v.getStoredVotes(ctx, cursor, limit)
 		if err != nil {
-			log.Println(fmt.Sprintf("An error occurred getting Vote %v from Redis.", key), err)
-			// TODO: Error type?
-			c.AbortWithStatus(http.StatusInternalServerError)
+			requestid.Logger(ctx).Println("An error occurred getting a page of Votes from Redis.", err)
+			apierror.Abort(c, err)
 			return
 		}
-		votes = append(votes, vote)
+		c.JSON(http.StatusOK, schema.VotePage{Items: votes, Next: paging.NextLink(c.Request.URL.Path, next, limit)})
+		return
+	}
+
+	votes, err := v.getAllStoredVotes(ctx)
+	if err != nil {
+		requestid.Logger(ctx).Println("An error occurred getting Votes from Redis.", err)
+		apierror.Abort(c, err)
+		return
 	}
 
 	if votes == nil {
@@ -108,14 +215,15 @@ func (v *VotesAPI) GetAllVotes(c *gin.Context) {
 
 func (v *VotesAPI) GetVote(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	voteid := c.Request.URL.String()
 
 	var vote schema.Vote
-	key := redisKeyFromId(voteid)
-	err := v.getVoteFromRedis(key, &vote)
+	err := v.getStoredVote(ctx, voteid, &vote)
 	if err != nil {
-		log.Println(fmt.Sprintf("Vote %v does not exist.", voteid), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Vote %v does not exist.", voteid), err)
+		apierror.Abort(c, err)
 		return
 	}
 
@@ -124,76 +232,102 @@ func (v *VotesAPI) GetVote(c *gin.Context) {
 
 func (v *VotesAPI) AddVote(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	voteid := c.Request.URL.String()
 
 	var vote schema.Vote
 	if err := c.ShouldBindJSON(&vote); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
 		return
 	}
 
 	vote.VoteID = voteid
 
-	var existingVote schema.Vote
-	key := redisKeyFromId(vote.VoteID)
-	if err := v.getVoteFromRedis(key, &existingVote); err == nil {
-		log.Println(fmt.Sprintf("Vote %v already exists, to update a vote use the PUT method.", vote.VoterID), err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+	// a voter can only cast their own Vote
+	if err := checkVoterAllowed(c, vote.VoterID); err != nil {
+		requestid.Logger(ctx).Println(err)
+		apierror.Abort(c, err)
+		return
 	}
 
-	vote.VoteID = c.Request.URL.String()
-
-	voterURL := v.voterAPIURL + vote.VoterID
-	var voter schema.Voter
-
 	// checks if the Voter with VoterID exists
-	resp, err := v.apiClient.R().SetResult(&voter).Get(voterURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get Voter %v from Voter API", vote.VoterID))
-		c.AbortWithStatus(http.StatusNotFound)
+	if _, err := v.getVoter(ctx, vote.VoterID); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get Voter %v from Voter API", vote.VoterID), err)
+		apierror.Abort(c, err)
 		return
 	}
 
 	// checks if the Poll with PollID exists
-	pollURL := v.pollAPIURL + vote.PollID
-	var poll schema.Poll
-	resp, err = v.apiClient.R().SetResult(&poll).Get(pollURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get Poll %v from Poll API", vote.PollID))
-		c.AbortWithStatus(http.StatusNotFound)
+	poll, err := v.getPollFresh(ctx, vote.PollID)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get Poll %v from Poll API", vote.PollID), err)
+		apierror.Abort(c, err)
 		return
 	}
-
-	// checks if the PollOption with PollOptionID (Vote.VoteValue) exists
-	pollOptionURL := v.pollAPIURL + vote.VoteValue
-	var option schema.PollOption
-	_, err = v.apiClient.R().SetResult(&option).Get(pollOptionURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get PollOption %v from Poll API", vote.VoteValue))
-		c.AbortWithStatus(http.StatusNotFound)
+	if err := checkPollOpen(poll); err != nil {
+		requestid.Logger(ctx).Println(err)
+		apierror.Abort(c, err)
 		return
 	}
 
-	// Add the Vote to Redis
-	if _, err := v.cache.helper.JSONSet(key, ".", vote); err != nil {
-		log.Println(fmt.Sprintf("An error occurred when trying to add Vote %v to Redis.", vote.VoteID) + err.Error())
-		c.AbortWithStatus(http.StatusInternalServerError)
+	// checks if the selected PollOptions (Vote.VoteValue or Vote.VoteValues)
+	// exist in the Poll and fit its PollType
+	if err := validateSelections(poll, &vote); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Invalid selections %v for Poll %v", vote.Selections(), poll.PollID), err)
+		apierror.Abort(c, err)
 		return
 	}
 
-	// Add the voterPoll to Voter.VoteHistory
-	resp, err = v.apiClient.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(v.NewVoterVoteHistoryString(vote.PollID)).
-		Post(v.voterAPIURL + vote.VoterID + vote.PollID)
+	s := newSaga("AddVote " + vote.VoteID)
+
+	// Only one Vote per Voter per Poll
+	s.addStep("reserve voterPoll",
+		func() error {
+			claimed, err := v.claimVoterPoll(ctx, vote.VoterID, vote.PollID, vote.VoteID)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				return apierror.Wrap(ErrVoterAlreadyVoted, "VoterID", "Voter %v already has a Vote in Poll %v.", vote.VoterID, vote.PollID)
+			}
+			return nil
+		},
+		func() error { return v.releaseVoterPoll(ctx, vote.VoterID, vote.PollID) })
+
+	// Add the Vote to Redis, unless a Vote with its VoteID exists
+	s.addStep("add Vote to Redis",
+		func() error {
+			added, err := v.store.SetNX(ctx, vote.VoteID, vote)
+			if err != nil {
+				return err
+			}
+			if !added {
+				return apierror.Wrap(ErrVoteExists, "VoteID", "Vote %v already exists, to update a vote use the PUT method.", vote.VoteID)
+			}
+			return nil
+		},
+		func() error {
+			_, err := v.store.Delete(ctx, vote.VoteID)
+			return err
+		})
+
+	// Add the voterPoll to Voter.VoteHistory, the last step so there is
+	// nothing after it that could fail and need it undone
+	s.addStep("add voterPoll to VoteHistory",
+		func() error {
+			return v.voterPollRequest(ctx, http.MethodPost, vote.VoterID, vote.PollID, time.Now().UTC())
+		},
+		nil)
 
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not add voterPoll %v to Voter %v's VoteHistory", vote.PollID, vote.VoterID))
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := s.run(ctx); err != nil {
+		abortWithSagaError(c, err)
 		return
 	}
 
+	v.changed(ctx, audit.ActorFrom(c), audit.ActionAdd, nil, &vote)
+
 	c.Status(http.StatusOK)
 
 }
@@ -202,111 +336,127 @@ func (v *VotesAPI) AddVote(c *gin.Context) {
 // /votes/:voteid
 func (v *VotesAPI) DeleteVote(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	voteid := c.Request.URL.String()
 
 	var vote schema.Vote
-	key := redisKeyFromId(voteid)
-	err := v.getVoteFromRedis(key, &vote)
+	err := v.getStoredVote(ctx, voteid, &vote)
 	if err != nil {
-		log.Println(fmt.Sprintf("Vote %v does not exist.", voteid), err)
-		c.AbortWithStatus(http.StatusNotFound)
+		requestid.Logger(ctx).Println(fmt.Sprintf("Vote %v does not exist.", voteid), err)
+		apierror.Abort(c, err)
 		return
 	}
 
-	numDeleted, err := v.client.Del(v.context, key).Result()
-	if err != nil {
-		log.Println(fmt.Sprintf("An error occurred deleting Vote %v from Redis.", voteid) + err.Error())
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := checkVoterAllowed(c, vote.VoterID); err != nil {
+		requestid.Logger(ctx).Println(err)
+		apierror.Abort(c, err)
 		return
 	}
-	if numDeleted == 0 {
-		log.Println(fmt.Sprintf("Vote %v does not exist, thus it can't be deleted from Redis.", voteid))
-		c.AbortWithStatus(http.StatusNotFound)
-		return
-	}
-
-	// Delete the voterPoll from Voter.VoteHistory
-	resp, err := v.apiClient.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(v.NewVoterVoteHistoryString(vote.PollID)).
-		Delete(v.voterAPIURL + vote.VoterID + vote.PollID)
 
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not delete voterPoll %v from Voter %v's VoteHistory", vote.PollID, vote.VoterID))
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := v.deleteVote(ctx, vote); err != nil {
+		abortWithSagaError(c, err)
 		return
 	}
 
+	v.changed(ctx, audit.ActorFrom(c), audit.ActionDelete, &vote, nil)
+
 	c.Status(http.StatusOK)
 }
 
 // Update Vote
 // /votes/:voteid
-// The only field that is allowed to be updated is VoteValue (PollOptionID)
+// The only fields that are allowed to be updated are VoteValue (PollOptionID)
+// and VoteValues (PollOptionIDs of a multi or ranked Poll)
 // All other fields (VoterID, PollID) that are provided are ignored
 func (v *VotesAPI) UpdateVote(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	voteid := c.Request.URL.String()
 
 	var vote schema.Vote
 	if err := c.ShouldBindJSON(&vote); err != nil {
-		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		requestid.Logger(ctx).Println("Error binding JSON: ", err)
+		apierror.Abort(c, apierror.Validation("", "Error binding JSON: %v", err))
 		return
 	}
 
 	vote.VoteID = voteid
 
-	key := redisKeyFromId(vote.VoteID)
 	var existingVote schema.Vote
-	if err := v.getVoteFromRedis(key, &existingVote); err != nil {
-		errors.New(fmt.Sprintf("The vote to be updated Vote %v, does not exist.", vote.VoterID) + err.Error())
-		c.AbortWithStatus(http.StatusNotFound)
+	if err := v.getStoredVote(ctx, vote.VoteID, &existingVote); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("The vote to be updated Vote %v, does not exist.", vote.VoteID), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	if err := checkVoterAllowed(c, existingVote.VoterID); err != nil {
+		requestid.Logger(ctx).Println(err)
+		apierror.Abort(c, err)
 		return
 	}
 
 	// Default value, did not provide new VoteValue to update
-	if vote.VoteValue == "" {
-		log.Println(fmt.Sprintf("Did not provide a VoteValue to update Vote %v.", vote.VoteID))
-		c.AbortWithStatus(http.StatusBadRequest)
+	if len(vote.Selections()) == 0 {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Did not provide a VoteValue to update Vote %v.", vote.VoteID))
+		apierror.Abort(c, apierror.Validation("VoteValue", "Did not provide a VoteValue to update Vote %v.", vote.VoteID))
 		return
 	}
 
-	// checks if the PollOption with PollOptionID (Vote.VoteValue) exists
-	pollOptionURL := v.pollAPIURL + vote.VoteValue
-	var option schema.PollOption
-	resp, err := v.apiClient.R().SetResult(&option).Get(pollOptionURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get PollOption %v from Poll API", vote.VoteValue))
-		c.AbortWithStatus(http.StatusNotFound)
+	// checks if the Poll is still open
+	poll, err := v.getPollFresh(ctx, existingVote.PollID)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get Poll %v from Poll API", existingVote.PollID), err)
+		apierror.Abort(c, err)
+		return
+	}
+	if err := checkPollOpen(poll); err != nil {
+		requestid.Logger(ctx).Println(err)
+		apierror.Abort(c, err)
 		return
 	}
 
-	// oldVoteValue := existingVote.VoteValue
+	// checks if the selected PollOptions (Vote.VoteValue or Vote.VoteValues)
+	// exist in the Poll and fit its PollType
+	if err := validateSelections(poll, &vote); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Invalid selections %v for Poll %v", vote.Selections(), poll.PollID), err)
+		apierror.Abort(c, err)
+		return
+	}
 
+	oldVote := existingVote
 	existingVote.VoteValue = vote.VoteValue
+	existingVote.VoteValues = vote.VoteValues
+
+	s := newSaga("UpdateVote " + vote.VoteID)
 
 	// Finally update Vote
-	if _, err := v.helper.JSONSet(key, ".", existingVote); err != nil {
-		log.Println(fmt.Sprintf("An error occurred while updating Vote %v", vote.VoteValue) + err.Error())
-		c.AbortWithStatus(http.StatusInternalServerError)
-		return
-	}
+	s.addStep("update Vote in Redis",
+		func() error { return v.store.Set(ctx, vote.VoteID, existingVote) },
+		func() error { return v.store.Set(ctx, vote.VoteID, oldVote) })
 
-	// update Voter.VoteHistory's voterPoll
+	s.addStep("update voterPoll index",
+		func() error {
+			return v.setVoterPoll(ctx, existingVote.VoterID, existingVote.PollID, existingVote.VoteID)
+		},
+		nil)
 
-	// Add the voterPoll to Voter.VoteHistory
-	resp, err = v.apiClient.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(v.NewVoterVoteHistoryString(existingVote.PollID)).
-		Put(v.voterAPIURL + existingVote.VoterID + existingVote.PollID)
+	// update Voter.VoteHistory's voterPoll
+	s.addStep("update voterPoll in VoteHistory",
+		func() error {
+			return v.voterPollRequest(ctx, http.MethodPut, existingVote.VoterID, existingVote.PollID, time.Now().UTC())
+		},
+		nil)
 
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not update voterPoll %v to Voter %v's VoteHistory", vote.PollID, vote.VoterID))
-		c.AbortWithStatus(http.StatusInternalServerError)
+	if err := s.run(ctx); err != nil {
+		abortWithSagaError(c, err)
 		return
 	}
 
+	v.changed(ctx, audit.ActorFrom(c), audit.ActionUpdate, &oldVote, &existingVote)
+
+	c.Status(http.StatusOK)
 }
 
 // Additonal Handlers
@@ -316,19 +466,34 @@ func (v *VotesAPI) UpdateVote(c *gin.Context) {
 // from the original endpoints (in a different API)
 
 // /votes/polls
+// the ?limit= and ?cursor= page parameters are passed along to the Poll API
 func (v *VotesAPI) GetAllPolls(c *gin.Context) {
 
-	url := c.Request.URL.String()
-	re := regexp.MustCompile(`/polls$`)
-	pollsS := string(re.Find([]byte(url)))
+	ctx := c.Request.Context()
+
+	paged, cursor, limit, err := paging.Params(c)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error reading the page parameters: ", err)
+		apierror.Abort(c, err)
+		return
+	}
 
-	pollURL := v.pollAPIURL + pollsS
-	polls := []schema.Poll{}
+	if paged {
+		page, err := v.apis.Polls.Page(ctx, client.PageOptions{Cursor: cursor, Limit: limit})
+		if err != nil {
+			requestid.Logger(ctx).Println("Could not get a page of Polls from Poll API", err)
+			apierror.Abort(c, apierror.Call(err, "", "Could not get a page of Polls: %v", err))
+			return
+		}
+		page.Next = relayPageLink(page.Next)
+		c.JSON(http.StatusOK, page)
+		return
+	}
 
-	resp, err := v.apiClient.R().SetResult(&polls).Get(pollURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get Polls from Poll API", pollsS))
-		c.AbortWithStatus(http.StatusNotFound)
+	polls, err := v.apis.Polls.List(ctx)
+	if err != nil {
+		requestid.Logger(ctx).Println("Could not get Polls from Poll API", err)
+		apierror.Abort(c, apierror.Call(err, "", "Could not get the Polls: %v", err))
 		return
 	}
 	c.JSON(http.StatusOK, polls)
@@ -338,16 +503,16 @@ func (v *VotesAPI) GetAllPolls(c *gin.Context) {
 // /votes/polls/:pollid
 func (v *VotesAPI) GetPoll(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re := regexp.MustCompile(`/polls/\d+`)
 	pollidS := string(re.Find([]byte(url)))
 
-	pollURL := v.pollAPIURL + pollidS
-	var poll schema.Poll
-	resp, err := v.apiClient.R().SetResult(&poll).Get(pollURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get Poll %v from Poll API", pollidS))
-		c.AbortWithStatus(http.StatusNotFound)
+	poll, err := v.getPoll(ctx, pollidS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get Poll %v from Poll API", pollidS), err)
+		apierror.Abort(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, poll)
@@ -357,18 +522,23 @@ func (v *VotesAPI) GetPoll(c *gin.Context) {
 // /votes/polls/:pollid/options
 func (v *VotesAPI) GetPollOptions(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
-	re := regexp.MustCompile(`/polls/\d+/options$`)
-	optionsidS := string(re.Find([]byte(url)))
+	re := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re.Find([]byte(url)))
 
-	pollURL := v.pollAPIURL + optionsidS
-	options := []schema.PollOption{}
-	resp, err := v.apiClient.R().SetResult(&options).Get(pollURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get pollOptions from Poll API", optionsidS))
-		c.AbortWithStatus(http.StatusNotFound)
+	poll, err := v.getPoll(ctx, pollidS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get pollOptions %v/options from Poll API", pollidS), err)
+		apierror.Abort(c, err)
 		return
 	}
+
+	options := poll.PollOptions
+	if options == nil {
+		options = []schema.PollOption{}
+	}
 	c.JSON(http.StatusOK, options)
 
 }
@@ -376,36 +546,181 @@ func (v *VotesAPI) GetPollOptions(c *gin.Context) {
 // /votes/polls/:pollid/options/:optionid
 func (v *VotesAPI) GetPollOption(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
+	re_pollid := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re_pollid.Find([]byte(url)))
 	re := regexp.MustCompile(`/polls/\d+/options/\d+$`)
 	optionidS := string(re.Find([]byte(url)))
 
-	pollURL := v.pollAPIURL + optionidS
-	var pollOption schema.PollOption
-	resp, err := v.apiClient.R().SetResult(&pollOption).Get(pollURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get pollOption %v from Poll API", optionidS))
-		c.AbortWithStatus(http.StatusNotFound)
+	poll, err := v.getPoll(ctx, pollidS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get pollOption %v from Poll API", optionidS), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	for _, pollOption := range poll.PollOptions {
+		if pollOption.PollOptionID == optionidS {
+			c.JSON(http.StatusOK, pollOption)
+			return
+		}
+	}
+	requestid.Logger(ctx).Println(fmt.Sprintf("pollOption %v does not exist.", optionidS))
+	apierror.Abort(c, apierror.NotFound("PollOptionID", "%v does not exist.", optionidS))
+
+}
+
+// /votes/polls/:pollid/results
+// tallies every Vote cast in the Poll by VoteValue (PollOptionID) and
+// returns the count and percentage for each of the Poll's PollOptions,
+// including the ones that have not received any Votes
+func (v *VotesAPI) GetPollResults(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	url := c.Request.URL.String()
+	re := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re.Find([]byte(url)))
+
+	poll, err := v.getPoll(ctx, pollidS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get Poll %v from Poll API", pollidS), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	results, err := v.tallyStoredVotes(ctx, poll)
+	if err != nil {
+		requestid.Logger(ctx).Println("An error occurred getting Votes from Redis.", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// /votes/polls/:pollid/options/:optionid/votes
+// returns the Votes that select (or rank) the PollOption
+func (v *VotesAPI) GetPollOptionVotes(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	url := c.Request.URL.Path
+	re_pollid := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re_pollid.Find([]byte(url)))
+	re_optionid := regexp.MustCompile(`/polls/\d+/options/\d+`)
+	optionidS := string(re_optionid.Find([]byte(url)))
+
+	optionVotes, err := v.getStoredVotesWhere(ctx, pollidS, selectsPollOption(optionidS))
+	if err != nil {
+		requestid.Logger(ctx).Println("An error occurred getting Votes from Redis.", err)
+		apierror.Abort(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, pollOption)
 
+	c.JSON(http.StatusOK, optionVotes)
+}
+
+// /votes/polls/:pollid/options/:optionid/votes/count
+// returns how many Votes select (or rank) the PollOption, for the Poll API to
+// check before it changes or deletes the PollOption
+func (v *VotesAPI) GetPollOptionVoteCount(c *gin.Context) {
+
+	url := c.Request.URL.Path
+	re_pollid := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re_pollid.Find([]byte(url)))
+	re_optionid := regexp.MustCompile(`/polls/\d+/options/\d+`)
+	optionidS := string(re_optionid.Find([]byte(url)))
+
+	v.countVotesWhere(c, pollidS, selectsPollOption(optionidS))
+}
+
+// /votes/polls/:pollid/options/:optionid/votes (DELETE)
+// deletes every Vote that selects (or ranks) the PollOption, so the Poll API
+// can cascade the deletion of the PollOption, and returns the deleted Votes
+func (v *VotesAPI) DeletePollOptionVotes(c *gin.Context) {
+
+	url := c.Request.URL.Path
+	re_pollid := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re_pollid.Find([]byte(url)))
+	re_optionid := regexp.MustCompile(`/polls/\d+/options/\d+`)
+	optionidS := string(re_optionid.Find([]byte(url)))
+
+	v.deleteVotesWhere(c, pollidS, selectsPollOption(optionidS))
+}
+
+// /votes/polls/:pollid/votes
+// returns the Votes cast in the Poll
+func (v *VotesAPI) GetPollVotes(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	re_pollid := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re_pollid.Find([]byte(c.Request.URL.Path)))
+
+	pollVotes, err := v.getStoredVotesWhere(ctx, pollidS, nil)
+	if err != nil {
+		requestid.Logger(ctx).Println("An error occurred getting Votes from Redis.", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pollVotes)
+}
+
+// /votes/polls/:pollid/votes/count
+// returns how many Votes were cast in the Poll, for the Poll API to check
+// before it deletes the Poll
+func (v *VotesAPI) GetPollVoteCount(c *gin.Context) {
+
+	re_pollid := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re_pollid.Find([]byte(c.Request.URL.Path)))
+
+	v.countVotesWhere(c, pollidS, nil)
+}
+
+// /votes/polls/:pollid/votes (DELETE)
+// deletes every Vote cast in the Poll, so the Poll API can cascade the
+// deletion of the Poll, and returns the deleted Votes
+func (v *VotesAPI) DeletePollVotes(c *gin.Context) {
+
+	re_pollid := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re_pollid.Find([]byte(c.Request.URL.Path)))
+
+	v.deleteVotesWhere(c, pollidS, nil)
 }
 
 // /votes/voters
+// the ?limit= and ?cursor= page parameters are passed along to the Voter API
 func (v *VotesAPI) GetAllVoters(c *gin.Context) {
 
-	url := c.Request.URL.String()
-	re := regexp.MustCompile(`/voters$`)
-	votersS := string(re.Find([]byte(url)))
+	ctx := c.Request.Context()
+
+	paged, cursor, limit, err := paging.Params(c)
+	if err != nil {
+		requestid.Logger(ctx).Println("Error reading the page parameters: ", err)
+		apierror.Abort(c, err)
+		return
+	}
 
-	voterURL := v.voterAPIURL + votersS
-	voters := []schema.Voter{}
+	if paged {
+		page, err := v.apis.Voters.Page(ctx, client.PageOptions{Cursor: cursor, Limit: limit})
+		if err != nil {
+			requestid.Logger(ctx).Println("Could not get a page of Voters from Voter API", err)
+			apierror.Abort(c, apierror.Call(err, "", "Could not get a page of Voters: %v", err))
+			return
+		}
+		page.Next = relayPageLink(page.Next)
+		c.JSON(http.StatusOK, page)
+		return
+	}
 
-	resp, err := v.apiClient.R().SetResult(&voters).Get(voterURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println("Could not get Voters from Voter API")
-		c.AbortWithStatus(http.StatusNotFound)
+	voters, err := v.apis.Voters.List(ctx)
+	if err != nil {
+		requestid.Logger(ctx).Println("Could not get Voters from Voter API", err)
+		apierror.Abort(c, apierror.Call(err, "", "Could not get the Voters: %v", err))
 		return
 	}
 	c.JSON(http.StatusOK, voters)
@@ -415,16 +730,16 @@ func (v *VotesAPI) GetAllVoters(c *gin.Context) {
 // /votes/voters/:voterid
 func (v *VotesAPI) GetVoter(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
 	re := regexp.MustCompile(`/voters/\d+`)
 	voteridS := string(re.Find([]byte(url)))
 
-	voterURL := v.voterAPIURL + voteridS
-	var voter schema.Voter
-	resp, err := v.apiClient.R().SetResult(&voter).Get(voterURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get Voter %v from Poll API", voteridS))
-		c.AbortWithStatus(http.StatusNotFound)
+	voter, err := v.getVoter(ctx, voteridS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get Voter %v from Voter API", voteridS), err)
+		apierror.Abort(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, voter)
@@ -434,18 +749,23 @@ func (v *VotesAPI) GetVoter(c *gin.Context) {
 // /votes/voters/:voterid/polls
 func (v *VotesAPI) GetVoterPolls(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
-	re := regexp.MustCompile(`/voters/\d+/polls$`)
-	voterpollidS := string(re.Find([]byte(url)))
+	re := regexp.MustCompile(`/voters/\d+`)
+	voteridS := string(re.Find([]byte(url)))
 
-	voterURL := v.voterAPIURL + voterpollidS
-	voterPolls := []schema.VoterPoll{}
-	resp, err := v.apiClient.R().SetResult(&voterPolls).Get(voterURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println("Could not get voterPolls from Voter API")
-		c.AbortWithStatus(http.StatusNotFound)
+	voter, err := v.getVoter(ctx, voteridS)
+	if err != nil {
+		requestid.Logger(ctx).Println("Could not get voterPolls from Voter API", err)
+		apierror.Abort(c, err)
 		return
 	}
+
+	voterPolls := voter.VoteHistory
+	if voterPolls == nil {
+		voterPolls = []schema.VoterPoll{}
+	}
 	c.JSON(http.StatusOK, voterPolls)
 
 }
@@ -453,24 +773,343 @@ func (v *VotesAPI) GetVoterPolls(c *gin.Context) {
 // /votes/voters/:voterid/polls/:pollid
 func (v *VotesAPI) GetVoterPoll(c *gin.Context) {
 
+	ctx := c.Request.Context()
+
 	url := c.Request.URL.String()
+	re_voterid := regexp.MustCompile(`/voters/\d+`)
+	voteridS := string(re_voterid.Find([]byte(url)))
 	re := regexp.MustCompile(`/voters/\d+/polls/\d+$`)
 	voterpollidS := string(re.Find([]byte(url)))
+	pollidS := strings.TrimPrefix(voterpollidS, voteridS)
 
-	voterURL := v.voterAPIURL + voterpollidS
-	var voterPoll schema.VoterPoll
-	resp, err := v.apiClient.R().SetResult(&voterPoll).Get(voterURL)
-	if err != nil || resp.Status() != "200 OK" {
-		log.Println(fmt.Sprintf("Could not get voterPoll %v from Voter API", voterpollidS))
-		c.AbortWithStatus(http.StatusNotFound)
+	voter, err := v.getVoter(ctx, voteridS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Could not get voterPoll %v from Voter API", voterpollidS), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	for _, voterPoll := range voter.VoteHistory {
+		if voterPoll.PollID == pollidS {
+			c.JSON(http.StatusOK, voterPoll)
+			return
+		}
+	}
+	requestid.Logger(ctx).Println(fmt.Sprintf("voterPoll %v does not exist.", voterpollidS))
+	apierror.Abort(c, apierror.NotFound("PollID", "%v does not exist.", voterpollidS))
+
+}
+
+// /votes/voters/:voterid/polls/:pollid/vote
+// returns the Vote the Voter cast in the Poll
+func (v *VotesAPI) GetVoterPollVote(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	url := c.Request.URL.String()
+	re_voterid := regexp.MustCompile(`/voters/\d+`)
+	voteridS := string(re_voterid.Find([]byte(url)))
+	re_pollid := regexp.MustCompile(`/polls/\d+`)
+	pollidS := string(re_pollid.Find([]byte(url)))
+
+	voteID, err := v.lookupVoterPoll(ctx, voteridS, pollidS)
+	if err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Voter %v has not voted in Poll %v.", voteridS, pollidS), err)
+		if errors.Is(err, docstore.ErrNotFound) {
+			err = apierror.NotFound("PollID", "Voter %v has not voted in Poll %v.", voteridS, pollidS)
+		}
+		apierror.Abort(c, err)
+		return
+	}
+
+	var vote schema.Vote
+	if err := v.getStoredVote(ctx, voteID, &vote); err != nil {
+		requestid.Logger(ctx).Println(fmt.Sprintf("Vote %v does not exist.", voteID), err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, vote)
+}
+
+// /votes/voters/:voterid/votes
+// returns the Votes cast by the Voter
+func (v *VotesAPI) GetVoterVotes(c *gin.Context) {
+
+	ctx := c.Request.Context()
+
+	re_voterid := regexp.MustCompile(`/voters/\d+`)
+	voteridS := string(re_voterid.Find([]byte(c.Request.URL.Path)))
+
+	voterVotes, err := v.getStoredVotesWhere(ctx, "", castByVoter(voteridS))
+	if err != nil {
+		requestid.Logger(ctx).Println("An error occurred getting Votes from Redis.", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, voterVotes)
+}
+
+// /votes/voters/:voterid/votes (DELETE)
+// deletes every Vote cast by the Voter, so the Voter API can cascade the
+// deletion of the Voter, and returns the deleted Votes
+func (v *VotesAPI) DeleteVoterVotes(c *gin.Context) {
+
+	re_voterid := regexp.MustCompile(`/voters/\d+`)
+	voteridS := string(re_voterid.Find([]byte(c.Request.URL.Path)))
+
+	v.deleteVotesWhere(c, "", castByVoter(voteridS))
+}
+
+// deleteVotesWhere deletes every Vote that getStoredVotesWhere returns, one
+// at a time with deleteVote, and responds with the deleted Votes. It stops at
+// the first Vote that can't be deleted, the Votes deleted before it stay
+// deleted.
+func (v *VotesAPI) deleteVotesWhere(c *gin.Context, pollID string, match func(schema.Vote) bool) {
+
+	ctx := c.Request.Context()
+
+	votes, err := v.getStoredVotesWhere(ctx, pollID, match)
+	if err != nil {
+		requestid.Logger(ctx).Println("An error occurred getting Votes from Redis.", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	for i, vote := range votes {
+		if err := v.deleteVote(ctx, vote); err != nil {
+			requestid.Logger(ctx).Println(fmt.Sprintf("Could not delete Vote %v, %v of %v Votes were deleted.", vote.VoteID, i, len(votes)), err)
+			abortWithSagaError(c, err)
+			return
+		}
+		v.changed(ctx, audit.ActorFrom(c), audit.ActionDelete, &vote, nil)
+	}
+
+	c.JSON(http.StatusOK, votes)
+}
+
+// castByVoter matches the Votes cast by the Voter voterID
+// countVotesWhere responds with the number of Votes cast in the Poll pollID
+// that match, read through the pollVotes index
+func (v *VotesAPI) countVotesWhere(c *gin.Context, pollID string, match func(schema.Vote) bool) {
+
+	ctx := c.Request.Context()
+
+	votes, err := v.getStoredVotesWhere(ctx, pollID, match)
+	if err != nil {
+		requestid.Logger(ctx).Println("An error occurred getting Votes from Redis.", err)
+		apierror.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schema.VoteCount{Count: len(votes)})
+}
+
+func castByVoter(voterID string) func(schema.Vote) bool {
+	return func(vote schema.Vote) bool {
+		return vote.VoterID == voterID
+	}
+}
+
+// selectsPollOption matches the Votes that select (or rank) the PollOption
+// pollOptionID
+func selectsPollOption(pollOptionID string) func(schema.Vote) bool {
+	return func(vote schema.Vote) bool {
+		for _, selection := range vote.Selections() {
+			if selection == pollOptionID {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//------------------------------------------------------------
+// VOTER API AND POLL API HELPERS
+//------------------------------------------------------------
+
+// checkVoterAllowed returns a forbidden error if the caller is a voter other
+// than the Voter voterID, a voter can only cast, change or delete their own
+// Vote
+func checkVoterAllowed(c *gin.Context, voterID string) error {
+
+	principal, ok := auth.PrincipalFrom(c)
+	if !ok || !principal.HasRole(auth.RoleVoter) || principal.Subject == voterID {
+		return nil
+	}
+	return apierror.Forbidden("VoterID", "Voter %v is not allowed to act on a Vote of Voter %v.", principal.Subject, voterID)
+}
+
+// checkPollOpen returns a forbidden error unless Votes can be cast in the Poll
+func checkPollOpen(poll schema.Poll) error {
+	if poll.IsOpen(time.Now()) {
+		return nil
+	}
+	status := poll.Status
+	if status == schema.PollStatusOpen {
+		status = schema.PollStatusClosed
+	}
+	return apierror.Wrap(ErrPollNotOpen, "PollID", "Poll %v is %v, Votes can only be cast while it is open.", poll.PollID, status)
+}
+
+// validateSelections checks that the PollOptions the Vote selects belong to
+// the Poll and that their number fits the PollType: exactly one for single
+// Polls, at most MaxSelections for multi Polls and at least one for ranked
+// Polls. A single Vote is stored in VoteValue, the others in VoteValues with
+// VoteValue set to the first selection.
+func validateSelections(poll schema.Poll, vote *schema.Vote) error {
+
+	selections := vote.Selections()
+	if len(selections) == 0 {
+		return apierror.Validation("VoteValues", "A Vote must select at least one PollOption.")
+	}
+
+	switch poll.PollType {
+	case schema.PollTypeMulti:
+		if len(selections) > poll.MaxSelections {
+			return apierror.Validation("VoteValues", "Poll %v allows at most %v selections, %v given.", poll.PollID, poll.MaxSelections, len(selections))
+		}
+	case schema.PollTypeRanked:
+	default:
+		if len(selections) != 1 {
+			return apierror.Validation("VoteValues", "Poll %v allows exactly one selection, %v given.", poll.PollID, len(selections))
+		}
+	}
+
+	options := make(map[string]bool, len(poll.PollOptions))
+	for _, option := range poll.PollOptions {
+		options[option.PollOptionID] = true
+	}
+	seen := make(map[string]bool, len(selections))
+	for _, selection := range selections {
+		if !options[selection] {
+			return apierror.NotFound("VoteValues", "PollOption %v does not exist in Poll %v.", selection, poll.PollID)
+		}
+		if seen[selection] {
+			return apierror.Validation("VoteValues", "PollOption %v is selected more than once.", selection)
+		}
+		seen[selection] = true
+	}
+
+	vote.VoteValue = selections[0]
+	vote.VoteValues = nil
+	if poll.PollType == schema.PollTypeMulti || poll.PollType == schema.PollTypeRanked {
+		vote.VoteValues = selections
+	}
+	return nil
+}
+
+// getError is the error for a failed GET of id from the Voter API or the
+// Poll API: a 404 means that id, which field refers to, does not exist
+func getError(err error, field string, id string) error {
+	if errors.Is(err, client.ErrNotFound) {
+		return apierror.NotFound(field, "%v does not exist.", id)
+	}
+	return apierror.Call(err, field, "Could not get %v: %v", id, err)
+}
+
+// voterPollRequest sends a POST, PUT or DELETE for the voterPoll of the
+// Voter voterID in the Poll pollID to the Voter API
+func (v *VotesAPI) voterPollRequest(ctx context.Context, method, voterID, pollID string, voteDate time.Time) error {
+
+	// the Voter's VoteHistory changes (or might have, if the request fails),
+	// the Voter API also publishes the change but that arrives later
+	defer v.voters.invalidate(voterID)
+
+	voterPoll := schema.VoterPoll{PollID: pollID, VoteDate: voteDate}
+
+	var err error
+	switch method {
+	case http.MethodPost:
+		err = v.apis.Voters.AddHistory(ctx, voterID, voterPoll)
+	case http.MethodPut:
+		err = v.apis.Voters.UpdateHistory(ctx, voterID, voterPoll)
+	case http.MethodDelete:
+		err = v.apis.Voters.DeleteHistory(ctx, voterID, pollID)
+	default:
+		return fmt.Errorf("no voterPoll request for method %v", method)
+	}
+	if err != nil {
+		return apierror.Call(err, "VoterID", "Could not %v voterPoll %v%v: %v", method, voterID, pollID, err)
+	}
+	return nil
+}
+
+// abortWithSagaError responds with the error of the step that failed, and
+// the outcome of the saga (including whether the steps that had already
+// completed were rolled back) as its Details
+func abortWithSagaError(c *gin.Context, err error) {
+
+	var sagaErr *sagaError
+	if !errors.As(err, &sagaErr) {
+		apierror.Abort(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, voterPoll)
 
+	apierror.AbortWithDetails(c, sagaErr.cause, sagaErr.result)
+}
+
+// deleteVote removes the Vote from redis and its voterPoll from the Voter's
+// VoteHistory, and releases the Voter's one Vote in the Poll
+func (v *VotesAPI) deleteVote(ctx context.Context, vote schema.Vote) error {
+
+	s := newSaga("DeleteVote " + vote.VoteID)
+
+	s.addStep("delete Vote from Redis",
+		func() error {
+			deleted, err := v.store.Delete(ctx, vote.VoteID)
+			if err != nil {
+				return err
+			}
+			if !deleted {
+				return fmt.Errorf("Vote %v does not exist, thus it can't be deleted from Redis", vote.VoteID)
+			}
+			return nil
+		},
+		func() error {
+			err := v.store.Set(ctx, vote.VoteID, vote)
+			return err
+		})
+
+	// Delete the voterPoll from Voter.VoteHistory, remembering its VoteDate
+	// so that it can be put back if a later step fails. A voterPoll that is
+	// already gone has nothing to delete, so the Vote is still removed.
+	var voterPoll schema.VoterPoll
+	removed := false
+	s.addStep("delete voterPoll from VoteHistory",
+		func() error {
+			var err error
+			voterPoll, err = v.apis.Voters.GetHistory(ctx, vote.VoterID, vote.PollID)
+			if errors.Is(err, client.ErrNotFound) {
+				requestid.Logger(ctx).Println(fmt.Sprintf("voterPoll %v of Voter %v is already gone.", vote.PollID, vote.VoterID), err)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := v.voterPollRequest(ctx, http.MethodDelete, vote.VoterID, vote.PollID, voterPoll.VoteDate); err != nil {
+				return err
+			}
+			removed = true
+			return nil
+		},
+		func() error {
+			if !removed {
+				return nil
+			}
+			return v.voterPollRequest(ctx, http.MethodPost, vote.VoterID, vote.PollID, voterPoll.VoteDate)
+		})
+
+	s.addStep("release voterPoll",
+		func() error { return v.releaseVoterPoll(ctx, vote.VoterID, vote.PollID) },
+		nil)
+
+	return s.run(ctx)
 }
 
 //------------------------------------------------------------
-// REDIS HELPERS
+// STORE HELPERS
 //------------------------------------------------------------
 
 // We will use this later, you can ignore for now
@@ -478,32 +1117,70 @@ func isRedisNilError(err error) bool {
 	return errors.Is(err, redis.Nil) || err.Error() == RedisNilError
 }
 
-// In redis, our keys will be strings, they will look like
-// poll:<number>.  This function will take an unsigned integer and
-// return a string that can be used as a key in redis
-func redisKeyFromId(id string) string {
-	return fmt.Sprintf("%s%s", RedisKeyPrefix, id)
+// Helper to return all the stored Votes. It pages through them rather than
+// reading them all at once, in redis with SCAN rather than KEYS, which
+// blocks redis while it walks the whole keyspace.
+func (v *VotesAPI) getAllStoredVotes(ctx context.Context) ([]schema.Vote, error) {
+
+	var votes []schema.Vote
+
+	var cursor uint64
+	for {
+		page, next, err := v.getStoredVotes(ctx, cursor, RedisScanCount)
+		if err != nil {
+			return nil, err
+		}
+		votes = append(votes, page...)
+		if next == 0 {
+			return votes, nil
+		}
+		cursor = next
+	}
 }
 
-// Helper to return a Vote from redis provided a key
-func (v *VotesAPI) getVoteFromRedis(key string, vote *schema.Vote) error {
+// Helper to return the stored Votes that match (every one if match is nil).
+// With a pollID only the Votes cast in that Poll are read, through the
+// pollVotes index, otherwise every stored Vote is.
+func (v *VotesAPI) getStoredVotesWhere(ctx context.Context, pollID string, match func(schema.Vote) bool) ([]schema.Vote, error) {
 
-	//Lets query redis for the item, note we can return parts of the
-	//json structure, the second parameter "." means return the entire
-	//json structure
-	itemObject, err := v.cache.helper.JSONGet(key, ".")
+	var votes []schema.Vote
+	var err error
+	if pollID != "" {
+		votes, err = v.store.ListPoll(ctx, pollID)
+	} else {
+		votes, err = v.getAllStoredVotes(ctx)
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	//JSONGet returns an "any" object, or empty interface,
-	//we need to convert it to a byte array, which is the
-	//underlying type of the object, then we can unmarshal
-	//it into our ToDoItem struct
-	err = json.Unmarshal(itemObject.([]byte), vote)
+	matching := make([]schema.Vote, 0)
+	for _, vote := range votes {
+		if match == nil || match(vote) {
+			matching = append(matching, vote)
+		}
+	}
+	return matching, nil
+}
+
+// Helper to return a page of Votes starting at cursor, along with the cursor
+// of the next page (0 when there are no more Votes). In redis, like SCAN, a
+// page can hold slightly more or fewer than limit Votes.
+func (v *VotesAPI) getStoredVotes(ctx context.Context, cursor uint64, limit int64) ([]schema.Vote, uint64, error) {
+	return v.store.List(ctx, cursor, limit)
+}
+
+// Helper to return a stored Vote provided its VoteID
+func (v *VotesAPI) getStoredVote(ctx context.Context, voteID string, vote *schema.Vote) error {
+
+	stored, err := v.store.Get(ctx, voteID)
 	if err != nil {
+		if errors.Is(err, docstore.ErrNotFound) {
+			return apierror.Wrap(ErrVoteNotFound, "VoteID", "Vote %v does not exist.", voteID)
+		}
 		return err
 	}
 
+	*vote = stored
 	return nil
 }
@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag votes-api:v1 -f ./dockerfile .
+docker build --build-arg COMMIT=$(git rev-parse --short HEAD) --tag votes-api:v1 -f ./dockerfile ..
@@ -1,93 +1,157 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
-
+	"time"
+
+	"drexel.edu/common/auth"
+	"drexel.edu/common/config"
+	"drexel.edu/common/docstore"
+	"drexel.edu/common/health"
+	"drexel.edu/common/metrics"
+	"drexel.edu/common/openapi"
+	"drexel.edu/common/requestid"
+	"drexel.edu/common/resilience"
+	"drexel.edu/common/server"
+	"drexel.edu/common/tracing"
 	"drexel.edu/votes-api/api"
+	"drexel.edu/votes-api/schema"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// version and commit are reported by GET /livez and GET /readyz, the
+// dockerfile sets them with -ldflags "-X main.version=... -X main.commit=..."
 var (
-	hostFlag    string
-	portFlag    uint
-	cacheURL    string
-	voterAPIURL string
-	pollAPIURL  string
+	version = "1.0.0"
+	commit  = ""
 )
 
-func processCmdLineFlags() {
-
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.StringVar(&voterAPIURL, "voterapi", "http://localhost:1080", "Default endpoint for the Voter API")
-	flag.StringVar(&pollAPIURL, "pollapi", "http://localhost:2080", "Default endpoint for the Poll API")
-	flag.StringVar(&cacheURL, "c", "0.0.0.0:6379", "Default cache location")
-	flag.UintVar(&portFlag, "p", 3080, "Default Port")
-
-	flag.Parse()
-}
-
-func envVarOrDefault(envVar string, defaultVal string) string {
-	envVal := os.Getenv(envVar)
-	if envVal != "" {
-		return envVal
+func main() {
+	cfg, err := config.Load(auth.ServiceVotesAPI)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
-	return defaultVal
-}
-
-func setupParms() {
-	processCmdLineFlags()
+	resilience.DefaultOptions.Timeout = time.Duration(cfg.Timeouts.Call)
 
-	cacheURL = envVarOrDefault("VOTESAPI_CACHE_URL", cacheURL)
-	voterAPIURL = envVarOrDefault("VOTESAPI_VOTER_API_URL", voterAPIURL)
-	pollAPIURL = envVarOrDefault("VOTESAPI_POLL_API_URL", pollAPIURL)
-	hostFlag = envVarOrDefault("VOTESAPI_HOST", hostFlag)
-
-	pfNew, err := strconv.Atoi(envVarOrDefault("VOTESAPI_PORT", fmt.Sprintf("%d", portFlag)))
-
-	if err == nil {
-		portFlag = uint(pfNew)
+	var apiHandler *api.VotesAPI
+	if cfg.Store == docstore.BackendMemory {
+		apiHandler = api.NewInMemoryVotesAPI(cfg.APIs.Voter, cfg.APIs.Poll)
+	} else {
+		apiHandler, err = api.NewVotesAPI(cfg.RedisOptions(), cfg.APIs.Voter, cfg.APIs.Poll)
 	}
 
-}
+	if err != nil {
+		panic(err)
+	}
 
-func main() {
-	setupParms()
-	log.Println("Init/cacheURL: " + cacheURL)
-	log.Println("Init/voterAPIURL: " + voterAPIURL)
-	log.Println("Init/pollAPIURL: " + pollAPIURL)
-	log.Println("Init/hostFlag: " + hostFlag)
-	log.Printf("Init/portFlag: %d", portFlag)
+	snapshots := docstore.SnapshotCommands[schema.Vote]{Service: "votes-api", Kind: docstore.KindVotes, API: apiHandler}
+	switch flag.Arg(0) {
+	case "reconcile":
+		runReconcile(apiHandler, flag.Args()[1:])
+		return
+	case "export":
+		if err := snapshots.Export(flag.Args()[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	case "import":
+		if err := snapshots.Import(flag.Args()[1:]); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	apiHandler, err := api.NewVotesAPI(cacheURL, voterAPIURL, pollAPIURL)
+	if cfg.RestoreFile != "" {
+		snapshots.Restore(cfg.RestoreFile)
+	}
 
+	authn, err := auth.FromEnv(apiHandler.Nonces())
 	if err != nil {
 		panic(err)
 	}
 
-	r := gin.Default()
-	r.Use(cors.Default())
+	shutdownTracing, err := tracing.Init(auth.ServiceVotesAPI)
+	if err != nil {
+		panic(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(requestid.LogFormatter), gin.Recovery())
+	r.Use(cors.New(cfg.CORS()))
+	r.Use(requestid.Middleware())
+	r.Use(tracing.Middleware(auth.ServiceVotesAPI))
+	r.Use(metrics.Middleware())
+
+	// Every request, and its response, is validated against the OpenAPI
+	// document, which has an operation for every route below (see Check)
+	doc := api.OpenAPI(version)
+	r.Use(doc.Middleware(cfg.OpenAPIValidation))
+
+	// The GET routes are open to everyone. Votes are cast, changed and
+	// deleted by voters (their own, see checkVoterAllowed) or admins, and
+	// everything else needs an admin. The Poll API and Voter API delete the
+	// Votes of what they delete with signed requests.
+	voter := authn.Require(auth.RoleVoter)
+	admin := authn.Require()
+	adminOrPollAPI := authn.RequireAdminOrService(auth.ServicePollAPI)
+	adminOrVoterAPI := authn.RequireAdminOrService(auth.ServiceVoterAPI)
 
 	r.GET("/votes", apiHandler.GetAllVotes)
 	r.GET("/votes/:voteid", apiHandler.GetVote)
-	r.POST("/votes/:voteid", apiHandler.AddVote)
-	r.DELETE("/votes/:voteid", apiHandler.DeleteVote)
-	r.PUT("/votes/:voteid", apiHandler.UpdateVote)
+	r.POST("/votes/:voteid", voter, apiHandler.AddVote)
+	r.DELETE("/votes/:voteid", voter, apiHandler.DeleteVote)
+	r.PUT("/votes/:voteid", voter, apiHandler.UpdateVote)
 
 	r.GET("/votes/voters", apiHandler.GetAllVoters)
 	r.GET("/votes/voters/:voterid", apiHandler.GetVoter)
 	r.GET("/votes/voters/:voterid/polls", apiHandler.GetVoterPolls)
 	r.GET("/votes/voters/:voterid/polls/:pollid", apiHandler.GetVoterPoll)
+	r.GET("/votes/voters/:voterid/polls/:pollid/vote", apiHandler.GetVoterPollVote)
+	r.GET("/votes/voters/:voterid/votes", apiHandler.GetVoterVotes)
+	r.DELETE("/votes/voters/:voterid/votes", adminOrVoterAPI, apiHandler.DeleteVoterVotes)
 
 	r.GET("/votes/polls", apiHandler.GetAllPolls)
 	r.GET("/votes/polls/:pollid", apiHandler.GetPoll)
 	r.GET("/votes/polls/:pollid/options", apiHandler.GetPollOptions)
 	r.GET("/votes/polls/:pollid/options/:optionid", apiHandler.GetPollOption)
+	r.GET("/votes/polls/:pollid/options/:optionid/votes", apiHandler.GetPollOptionVotes)
+	r.GET("/votes/polls/:pollid/options/:optionid/votes/count", apiHandler.GetPollOptionVoteCount)
+	r.DELETE("/votes/polls/:pollid/options/:optionid/votes", adminOrPollAPI, apiHandler.DeletePollOptionVotes)
+	r.GET("/votes/polls/:pollid/votes", apiHandler.GetPollVotes)
+	r.GET("/votes/polls/:pollid/votes/count", apiHandler.GetPollVoteCount)
+	r.DELETE("/votes/polls/:pollid/votes", adminOrPollAPI, apiHandler.DeletePollVotes)
+	r.GET("/votes/polls/:pollid/results", apiHandler.GetPollResults)
+	r.GET("/votes/polls/:pollid/results/stream", apiHandler.GetPollResultsStream)
+
+	r.POST("/votes/admin/reconcile", admin, apiHandler.ReconcileVotes)
+	r.GET("/votes/admin/audit", admin, apiHandler.GetAuditLog)
+
+	r.GET("/admin/export", admin, apiHandler.ExportVotes)
+	r.POST("/admin/import", admin, apiHandler.ImportVotes)
+
+	// /livez and /readyz are open, like /metrics, for compose and kubernetes
+	checker := health.New(auth.ServiceVotesAPI, version, commit, apiHandler.HealthChecks()...).
+		SetTimeout(time.Duration(cfg.Timeouts.Health)).
+		SetCircuits(resilience.States)
+	r.GET("/livez", checker.Livez)
+	r.GET("/readyz", checker.Readyz)
+	r.GET("/metrics", metrics.Handler())
+
+	// The OpenAPI document and its Swagger UI page are open to everyone
+	r.GET("/openapi.json", doc.Handler())
+	r.GET("/docs", openapi.SwaggerUI)
+	r.GET("/docs/*file", openapi.SwaggerUI)
 
 	// EXTRA CREDIT
 
@@ -100,6 +164,44 @@ func main() {
 
 	// r.GET("/crash", apiHandler.CrashSim)
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	if err := doc.Check(r.Routes()); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// On SIGTERM /readyz fails first, then the requests in flight are given
+	// time to finish before redis is closed
+	err = server.Run(r, checker, server.Options{
+		Addr:       cfg.Addr(),
+		Delay:      time.Duration(cfg.Timeouts.ShutdownDelay),
+		Timeout:    time.Duration(cfg.Timeouts.Shutdown),
+		OnShutdown: []func(){apiHandler.StopStreams},
+	})
+	if err != nil {
+		log.Println("Error serving: ", err)
+	}
+	if err := apiHandler.Close(); err != nil {
+		log.Println("Error closing redis: ", err)
+	}
+}
+
+// runReconcile implements the reconcile subcommand, e.g.
+//
+//	votes-api reconcile -dryRun
+//
+// it prints the report as JSON and exits with a non-zero status on error
+func runReconcile(apiHandler *api.VotesAPI, args []string) {
+
+	reconcileFlags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	dryRun := reconcileFlags.Bool("dryRun", false, "Only report drift between Votes and VoteHistory, do not repair it")
+	reconcileFlags.Parse(args)
+
+	report, err := apiHandler.Reconcile(context.Background(), *dryRun)
+	if err != nil {
+		log.Println("Error reconciling Votes and VoteHistory: ", err)
+		os.Exit(1)
+	}
+
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
 }
@@ -1,36 +1,68 @@
 package schema
 
 import (
-	"time"
+	"drexel.edu/client"
 )
 
-type Vote struct {
-	VoteID    string
-	VoterID   string
-	PollID    string
-	VoteValue string
-}
+// The models the Votes API shares with the Poll API and the Voter API are
+// those of the client module, it reads them with the same client
+type (
+	Vote             = client.Vote
+	VoteCount        = client.VoteCount
+	VoterPoll        = client.VoterPoll
+	Voter            = client.Voter
+	PollOption       = client.PollOption
+	Poll             = client.Poll
+	PollOptionResult = client.PollOptionResult
+	RunoffRound      = client.RunoffRound
+	PollResults      = client.PollResults
+)
 
-type VoterPoll struct {
-	PollID   string
-	VoteDate time.Time
+const (
+	PollTypeSingle = client.PollTypeSingle
+	PollTypeMulti  = client.PollTypeMulti
+	PollTypeRanked = client.PollTypeRanked
+)
+
+const (
+	PollStatusDraft  = client.PollStatusDraft
+	PollStatusOpen   = client.PollStatusOpen
+	PollStatusClosed = client.PollStatusClosed
+)
+
+type SagaCompensation struct {
+	Step      string
+	Succeeded bool
+	Error     string `json:",omitempty"`
 }
 
-type Voter struct {
-	VoterID     string
-	FirstName   string `json:",omitempty"`
-	LastName    string `json:",omitempty"`
-	VoteHistory []VoterPoll
+type SagaResult struct {
+	Saga          string
+	Error         string
+	FailedStep    string
+	RolledBack    bool
+	Compensations []SagaCompensation
 }
 
-type PollOption struct {
-	PollOptionID   string
-	PollOptionText string
+type ReconcileIssue struct {
+	VoteID   string `json:",omitempty"`
+	VoterID  string
+	PollID   string
+	Problem  string
+	Repaired bool
+	Error    string `json:",omitempty"`
 }
 
-type Poll struct {
-	PollID       string
-	PollTitle    string `json:",omitempty"`
-	PollQuestion string `json:",omitempty"`
-	PollOptions  []PollOption
+type ReconcileReport struct {
+	DryRun              bool
+	VotesChecked        int
+	VotersChecked       int
+	VotesWithoutHistory []ReconcileIssue
+	HistoryWithoutVotes []ReconcileIssue
 }
+
+type VotePage = client.Page[Vote]
+
+type VoterPage = client.Page[Voter]
+
+type PollPage = client.Page[Poll]